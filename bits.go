@@ -0,0 +1,109 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// ProveBits generates zero knowledge proof that the value x committed in VCom = x*G + s*HVec[0] decomposes into
+// the given bits: x = sum(bits[i] * 2^i) and every bits[i] is 0 or 1. Use empty FiatShamirEngine for call.
+func ProveBits(public *BitsPublic, fs FiatShamirEngine, x *big.Int, bits []*big.Int, s *big.Int) (*ArithmeticCircuitProof, error) {
+	if len(bits) != public.Nb {
+		return nil, errors.New("invalid bits length: should be equal to Nb")
+	}
+
+	return public.proveCircuit(public.circuit(), fs, x, s, bits)
+}
+
+// VerifyBits verifies the proof that the value committed in VCom decomposes into Nb bits. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyBits(public *BitsPublic, VCom *bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	return public.verifyCircuit(public.circuit(), VCom, fs, proof)
+}
+
+// proveCircuit generates the proof for the bits circuit or its modification with the value x and its bits witness.
+// The value is committed with zero padding, so the circuit commitment is equal to the value commitment.
+func (p *BitsPublic) proveCircuit(circuit *ArithmeticCircuitPublic, fs FiatShamirEngine, x, s *big.Int, bits []*big.Int) (*ArithmeticCircuitProof, error) {
+	v := zeroVector(circuit.Nv)
+	v[0] = x
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{v, zeroVector(circuit.Nv)},
+		Sv: []*big.Int{s, bint(0)},
+		Wl: bits,
+		Wr: bits,
+		Wo: []*big.Int{bint(0)},
+	}
+
+	V, err := circuit.CommitCircuit(private.V[0], private.Sv[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return ProveCircuit(circuit, []*bn256.G1{V, ZeroCommitment()}, fs, private)
+}
+
+// verifyCircuit verifies the proof for the bits circuit or its modification and the value commitment VCom.
+func (p *BitsPublic) verifyCircuit(circuit *ArithmeticCircuitPublic, VCom *bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	return VerifyCircuit(circuit, []*bn256.G1{VCom, ZeroCommitment()}, fs, proof)
+}
+
+// circuit builds the arithmetic circuit for the bits decomposition over the value commitment v[0] and the
+// ZeroCommitment v[1]:
+// wl[i] * wr[i] = wl[i] - every bit is boolean,
+// v[0][0] - sum(wl[i] * 2^i) = 0 - the value equals to the bits sum,
+// v[0][j] = 0 for j > 0 - the value commitment has no other values,
+// wl[i] - wr[i] + v[1][i] = 0 - left and right witnesses are the same, because v[1] is the zero vector.
+// The output wo is not constrained.
+func (p *BitsPublic) circuit() *ArithmeticCircuitPublic {
+	Nm, No, Nv, K := p.Nb, 1, p.Nb+1, 2
+	Nw := Nm + Nm + No
+
+	Wm := zeroMatrix(Nm, Nw)
+	for i := 0; i < Nm; i++ {
+		Wm[i][i] = bint(1)
+	}
+
+	Wl := zeroMatrix(Nv*K, Nw)
+	for i := 0; i < Nm; i++ {
+		Wl[0][i] = minus(pow(bint(2), i))
+
+		Wl[Nv+i][i] = bint(1)
+		Wl[Nv+i][i+Nm] = bint(-1)
+	}
+
+	res := &ArithmeticCircuitPublic{
+		Nm:   Nm,
+		Nl:   Nv * K,
+		Nv:   Nv,
+		Nw:   Nw,
+		No:   No,
+		K:    K,
+		G:    p.G,
+		GVec: p.GVec,
+		HVec: p.HVec,
+		Wm:   Wm,
+		Wl:   Wl,
+		Am:   zeroVector(Nm),
+		Al:   zeroVector(Nv * K),
+		Fl:   true,
+		Fm:   false,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
+	}
+
+	res.gTable.inherit(&p.gTable)
+	return res
+}
@@ -0,0 +1,122 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestBits(t *testing.T) {
+	Nb := 4
+
+	wnlaPublic := NewWeightNormLinearPublic(16, 4)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+9],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+9:],
+		},
+		Nb: Nb,
+	}
+
+	x := bint(6)
+	bits := []*big.Int{bint(0), bint(1), bint(1), bint(0)} // bin(0110) = dec(6)
+	s := MustRandScalar()
+
+	VCom := public.CommitValue(x, s)
+
+	proof, err := ProveBits(public, NewKeccakFS(), x, bits, s)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyBits(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Bits do not sum up to the value
+	wrongSum := []*big.Int{bint(1), bint(1), bint(1), bint(0)}
+	if proof, err = ProveBits(public, NewKeccakFS(), x, wrongSum, s); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyBits(public, VCom, NewKeccakFS(), proof); err == nil {
+		panic("proof for the wrong decomposition should fail")
+	}
+
+	// Not a boolean digits: 2 + 2*2 = 6
+	notBits := []*big.Int{bint(2), bint(2), bint(0), bint(0)}
+	if proof, err = ProveBits(public, NewKeccakFS(), x, notBits, s); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyBits(public, VCom, NewKeccakFS(), proof); err == nil {
+		panic("proof for not boolean digits should fail")
+	}
+}
+
+func TestBitsForgedCommitment(t *testing.T) {
+	Nb := 4
+
+	wnlaPublic := NewWeightNormLinearPublic(16, 4)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+9],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+9:],
+		},
+		Nb: Nb,
+	}
+
+	circuit := public.circuit()
+
+	// VCom = x*G + s*HVec[0] + (1-x)*HVec[9] with wl[0] = x and wr[0] = 1: the HVec[9] slot balances wl[0] - wr[0]
+	x, s := bint(100), MustRandScalar()
+
+	v := zeroVector(circuit.Nv)
+	v[0], v[1] = x, sub(bint(1), x)
+
+	forged, err := circuit.CommitCircuit(v, s)
+	if err != nil {
+		panic(err)
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{v, zeroVector(circuit.Nv)},
+		Sv: []*big.Int{s, bint(0)},
+		Wl: []*big.Int{x, bint(0), bint(0), bint(0)},
+		Wr: []*big.Int{bint(1), bint(0), bint(0), bint(0)},
+		Wo: []*big.Int{bint(0)},
+	}
+
+	proof, err := ProveCircuit(circuit, []*bn256.G1{forged, ZeroCommitment()}, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyBits(public, forged, NewKeccakFS(), proof); err == nil {
+		panic("proof for the forged commitment should fail")
+	}
+
+	// The slack can not be moved to the zero commitment either
+	private.V[0], private.V[1] = zeroVector(circuit.Nv), zeroVector(circuit.Nv)
+	private.V[0][0], private.V[1][0] = x, sub(bint(1), x)
+
+	if proof, err = ProveCircuit(circuit, []*bn256.G1{public.CommitValue(x, s), ZeroCommitment()}, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyBits(public, public.CommitValue(x, s), NewKeccakFS(), proof); err == nil {
+		panic("proof with the nonzero values of the zero commitment should fail")
+	}
+}
@@ -5,22 +5,671 @@
 package bulletproofs
 
 import (
+	"errors"
+	"fmt"
 	"github.com/cloudflare/bn256"
 	"math/big"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 // CommitCircuit creates a commitment for v vector and blinding s.
-// Com = v[0]*G + s*H[0] + <v[1:], H[9:]>
-func (p *ArithmeticCircuitPublic) CommitCircuit(v []*big.Int, s *big.Int) *bn256.G1 {
-	res := new(bn256.G1).ScalarMult(p.G, v[0])
+// Com = v[0]*G + s*H[0] + <v[1:], H[StructuralSlots:]>
+func (p *ArithmeticCircuitPublic) CommitCircuit(v []*big.Int, s *big.Int) (*bn256.G1, error) {
+	if err := p.checkHVec(); err != nil {
+		return nil, err
+	}
+
+	res := p.precomputeG().mul(v[0])
 	res.Add(res, new(bn256.G1).ScalarMult(p.HVec[0], s))
-	res.Add(res, vectorPointScalarMul(p.HVec[9:], v[1:]))
+	res.Add(res, vectorPointScalarMul(p.HVec[StructuralSlots:], v[1:]))
+	return res, nil
+}
+
+// CommitCircuitBatch creates commitments for every vs[k] with blinding ss[k] using CommitCircuit.
+// Commitments are computed in parallel using up to GOMAXPROCS goroutines.
+func (p *ArithmeticCircuitPublic) CommitCircuitBatch(vs [][]*big.Int, ss []*big.Int) ([]*bn256.G1, error) {
+	if err := p.checkHVec(); err != nil {
+		return nil, err
+	}
+
+	if len(vs) != len(ss) {
+		return nil, errors.New("invalid length for vs and ss: should be equal")
+	}
+
+	res := make([]*bn256.G1, len(vs))
+	errs := make([]error, len(vs))
+
+	parallelFor(len(vs), 0, func(k int) {
+		res[k], errs[k] = p.CommitCircuit(vs[k], ss[k])
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// CommitValue creates a commitment for the value v and blinding s: VCom = v*G + s*HVec[0].
+func (p *ValueCommitmentPublic) CommitValue(v *big.Int, s *big.Int) *bn256.G1 {
+	res := p.precomputeG().mul(v)
+	res.Add(res, new(bn256.G1).ScalarMult(p.HVec[0], s))
+	return res
+}
+
+// ZeroCommitment returns the commitment to the zero vector with zero blinding: the point at infinity. The verifier
+// appends it to the value commitments itself, and the prover can open it only to the zero vector, so the linear rows
+// of its values constrain the witness. The rows of the prover's commitments do not constrain the witness beyond
+// defining the committed values, because the prover chooses every value it commits to.
+func ZeroCommitment() *bn256.G1 {
+	return new(bn256.G1).ScalarBaseMult(bint(0))
+}
+
+// parallelFor calls f for every i in [0, n) using up to workers goroutines. Non-positive workers means GOMAXPROCS.
+func parallelFor(n, workers int, f func(i int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers > n {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := w; i < n; i += workers {
+				f(i)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
+
+// PadCircuit returns the copy of the circuit and witness with the multiplication gates count Nm padded to the
+// power of two. The new gates have zero wl, wr inputs and zero Wm rows and Am values, so they are trivially
+// satisfied. Wm and Wl columns are moved according to the new w = wl||wr||wo layout, and the new GVec elements
+// are taken from GVec_, so it should contain at least powerOfTwo(Nm)-Nm points (always true for the power of two
+// len(GVec)+len(GVec_)). Other dimensions do not depend on Nm and stay the same.
+func PadCircuit(public *ArithmeticCircuitPublic, private *ArithmeticCircuitPrivate) (*ArithmeticCircuitPublic, *ArithmeticCircuitPrivate) {
+	Nm := powerOfTwo(public.Nm)
+	Nw := Nm + Nm + public.No
+
+	// column returns the index of the w element in the padded layout
+	column := func(j int) int {
+		switch {
+		case j < public.Nm:
+			return j
+		case j < 2*public.Nm:
+			return j - public.Nm + Nm
+		default:
+			return j - 2*public.Nm + 2*Nm
+		}
+	}
+
+	padRows := func(m [][]*big.Int, rows int) [][]*big.Int {
+		res := zeroMatrix(rows, Nw)
+		for i := range m {
+			for j := range m[i] {
+				res[i][column(j)] = m[i][j]
+			}
+		}
+		return res
+	}
+
+	F := public.F
+	oldNm := public.Nm
+
+	padded := &ArithmeticCircuitPublic{
+		Nm:    Nm,
+		Nl:    public.Nl,
+		Nv:    public.Nv,
+		Nw:    Nw,
+		No:    public.No,
+		K:     public.K,
+		G:     public.G,
+		GVec:  concatPoints(public.GVec, public.GVec_[:Nm-oldNm]),
+		HVec:  public.HVec,
+		Wm:    padRows(public.Wm, Nm),
+		Wl:    padRows(public.Wl, public.Nl),
+		Am:    vectorAdd(public.Am, zeroVector(Nm)),
+		Al:    vectorAdd(public.Al, zeroVector(public.Nl)),
+		Fl:    public.Fl,
+		Fm:    public.Fm,
+		GVec_: append([]*bn256.G1{}, public.GVec_[Nm-oldNm:]...),
+		HVec_: public.HVec_,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionNO && index >= oldNm {
+				return nil
+			}
+
+			return F(typ, index)
+		},
+		Profiler: public.Profiler,
+	}
+
+	padded.gTable.inherit(&public.gTable)
+
+	paddedPrivate := *private
+	paddedPrivate.Wl = vectorAdd(private.Wl, zeroVector(Nm))
+	paddedPrivate.Wr = vectorAdd(private.Wr, zeroVector(Nm))
+
+	return padded, &paddedPrivate
+}
+
+// Summary returns the circuit dimensions and generators requirements.
+func (p *ArithmeticCircuitPublic) Summary() CircuitSummary {
+	return CircuitSummary{
+		Nm:            p.Nm,
+		Nl:            p.Nl,
+		Nv:            p.Nv,
+		Nw:            p.Nw,
+		No:            p.No,
+		K:             p.K,
+		GVecLen:       p.Nm,
+		HVecLen:       StructuralSlots + p.Nv,
+		PaddedGVecLen: powerOfTwo(p.Nm),
+		PaddedHVecLen: powerOfTwo(StructuralSlots + p.Nv),
+	}
+}
+
+// ChallengeCount returns the number of FiatShamirEngine.GetChallenge calls made by ProveCircuit and VerifyCircuit for
+// the circuit: 4 circuit challenges (rho, lambda, beta, delta), the t challenge and one challenge per WNLA round.
+func ChallengeCount(public *ArithmeticCircuitPublic) int {
+	rounds, _, _ := wnlaShape(len(public.HVec)+len(public.HVec_), len(public.GVec)+len(public.GVec_))
+	return 4 + 1 + rounds
+}
+
+// DescribeConstraints returns the human-readable constraints encoded by the circuit matrices: Nm multiplicative
+// constraints "wl[i] * wr[i] = <Wm[i], w> + Am[i]" followed by Nl linear constraints "<Wl[i], w> + v[k][j] + Al[i] = 0",
+// where w = wl||wr||wo and i = k*Nv + j. Coefficients are printed as the signed integers in (-order/2, order/2]
+// and zero terms are omitted.
+func (p *ArithmeticCircuitPublic) DescribeConstraints() []string {
+	res := make([]string, 0, p.Nm+p.Nl)
+
+	for i := 0; i < p.Nm; i++ {
+		res = append(res, fmt.Sprintf("wl[%d] * wr[%d] = %s", i, i, p.describeLinear(p.Wm[i], nil, scalarAt(p.Am, i))))
+	}
+
+	for i := 0; i < p.Nl; i++ {
+		v := fmt.Sprintf("v[%d][%d]", i/p.Nv, i%p.Nv)
+		res = append(res, fmt.Sprintf("%s = 0", p.describeLinear(p.Wl[i], &v, scalarAt(p.Al, i))))
+	}
+
 	return res
 }
 
+// describeLinear returns the sum of the row terms, the optional extra variable and the constant.
+func (p *ArithmeticCircuitPublic) describeLinear(row []*big.Int, extra *string, constant *big.Int) string {
+	var terms []string
+
+	push := func(c *big.Int, variable string) {
+		c = signedScalar(c)
+		if c.Sign() == 0 {
+			return
+		}
+
+		sign := "+"
+		if c.Sign() < 0 {
+			sign = "-"
+			c = new(big.Int).Neg(c)
+		}
+
+		term := c.String()
+		if variable != "" && c.Cmp(bint(1)) == 0 {
+			term = variable
+		} else if variable != "" {
+			term = c.String() + "*" + variable
+		}
+
+		terms = append(terms, sign, term)
+	}
+
+	for j, c := range row {
+		switch {
+		case j < p.Nm:
+			push(c, fmt.Sprintf("wl[%d]", j))
+		case j < 2*p.Nm:
+			push(c, fmt.Sprintf("wr[%d]", j-p.Nm))
+		default:
+			push(c, fmt.Sprintf("wo[%d]", j-2*p.Nm))
+		}
+	}
+
+	if extra != nil {
+		push(bint(1), *extra)
+	}
+
+	push(constant, "")
+
+	if len(terms) == 0 {
+		return "0"
+	}
+
+	if terms[0] == "+" {
+		terms = terms[1:]
+	} else {
+		terms[1] = "-" + terms[1]
+		terms = terms[1:]
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// signedScalar returns x reduced to the range (-order/2, order/2].
+func signedScalar(x *big.Int) *big.Int {
+	res := new(big.Int).Mod(x, fieldOrder)
+	if res.Cmp(new(big.Int).Rsh(fieldOrder, 1)) > 0 {
+		res.Sub(res, fieldOrder)
+	}
+
+	return res
+}
+
+// checkProofStructure checks that all proof points are defined and the WNLA proof has the rounds count and final
+// vectors lengths corresponding to the circuit generators.
+func (p *ArithmeticCircuitPublic) checkProofStructure(proof *ArithmeticCircuitProof) error {
+	if proof == nil || proof.WNLA == nil {
+		return errors.New("invalid proof: missing values")
+	}
+
+	if proof.CL == nil || proof.CR == nil || proof.CO == nil || proof.CS == nil {
+		return errors.New("invalid proof: missing commitments")
+	}
+
+	rounds, lLen, nLen := wnlaShape(len(p.HVec)+len(p.HVec_), len(p.GVec)+len(p.GVec_))
+
+	if len(proof.WNLA.X) != rounds || len(proof.WNLA.R) != rounds {
+		return fmt.Errorf("invalid WNLA proof: expected %d rounds", rounds)
+	}
+
+	for i := 0; i < rounds; i++ {
+		if proof.WNLA.X[i] == nil || proof.WNLA.R[i] == nil {
+			return errors.New("invalid WNLA proof: missing round points")
+		}
+	}
+
+	if len(proof.WNLA.L) != lLen || len(proof.WNLA.N) != nLen {
+		return fmt.Errorf("invalid WNLA proof: expected final vectors lengths %d and %d", lLen, nLen)
+	}
+
+	return nil
+}
+
+// ValidatePartition checks that the partition function F maps every index of the NO (in [0, Nm)) and LO, LL, LR
+// (in [0, Nv)) partitions either to nothing or to the index of the output witness wo in [0, No).
+func ValidatePartition(public *ArithmeticCircuitPublic) error {
+	if public.F == nil {
+		return errors.New("invalid partition: F is not defined")
+	}
+
+	sizes := map[PartitionType]int{
+		PartitionNO: public.Nm,
+		PartitionLO: public.Nv,
+		PartitionLL: public.Nv,
+		PartitionLR: public.Nv,
+	}
+
+	for _, typ := range []PartitionType{PartitionNO, PartitionLO, PartitionLL, PartitionLR} {
+		for j := 0; j < sizes[typ]; j++ {
+			if i := public.F(typ, j); i != nil && (*i < 0 || *i >= public.No) {
+				return fmt.Errorf("invalid partition: index %d of type %d is mapped to %d, should be in [0, No = %d)", j, typ, *i, public.No)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkCommitments checks that circuit has committed values and V contains K commitments. Circuits without committed
+// values are not supported: use K = 1 with the ZeroCommitment and zero Wl rows instead.
+func (p *ArithmeticCircuitPublic) checkCommitments(V []*bn256.G1) error {
+	if p.K < 1 {
+		return errors.New("invalid circuit: K should be at least 1")
+	}
+
+	if len(V) != p.K {
+		return fmt.Errorf("invalid length for V vector: should be equal to K = %d", p.K)
+	}
+
+	return nil
+}
+
+// NewArithmeticCircuitPublic creates the circuit with Nl = Nv*K and Nw = Nm+Nm+No and validates it: the matrices and
+// vectors dimensions, GVec length Nm, HVec length StructuralSlots+Nv and the partition function.
+func NewArithmeticCircuitPublic(
+	Nm, No, Nv, K int,
+	G *bn256.G1, GVec, HVec, GVec_, HVec_ []*bn256.G1,
+	Wm, Wl [][]*big.Int, Am, Al []*big.Int,
+	Fl, Fm bool, F PartitionF,
+) (*ArithmeticCircuitPublic, error) {
+	if Nm < 0 || No < 0 || Nv < 1 || K < 1 {
+		return nil, errors.New("invalid dimensions: Nm and No should be non-negative, Nv and K should be at least 1")
+	}
+
+	public := &ArithmeticCircuitPublic{
+		Nm: Nm, Nl: Nv * K, Nv: Nv, Nw: Nm + Nm + No, No: No, K: K,
+		G: G, GVec: GVec, HVec: HVec,
+		Wm: Wm, Wl: Wl, Am: Am, Al: Al,
+		Fl: Fl, Fm: Fm, F: F,
+		GVec_: GVec_, HVec_: HVec_,
+	}
+
+	if G == nil {
+		return nil, errors.New("invalid generators: G should be defined")
+	}
+
+	if len(GVec) != Nm {
+		return nil, fmt.Errorf("invalid GVec length: should be Nm = %d, got %d", Nm, len(GVec))
+	}
+
+	if err := public.checkHVec(); err != nil {
+		return nil, err
+	}
+
+	if err := checkMatrix("Wm", Wm, public.Nm, public.Nw); err != nil {
+		return nil, err
+	}
+
+	if err := checkMatrix("Wl", Wl, public.Nl, public.Nw); err != nil {
+		return nil, err
+	}
+
+	if len(Am) != public.Nm || len(Al) != public.Nl {
+		return nil, fmt.Errorf("invalid constants: Am and Al should have lengths Nm = %d and Nl = %d", public.Nm, public.Nl)
+	}
+
+	if F == nil {
+		return nil, errors.New("invalid partition: F should be defined")
+	}
+
+	if err := ValidatePartition(public); err != nil {
+		return nil, err
+	}
+
+	return public, nil
+}
+
+// checkMatrix checks that the matrix has the rows*cols dimensions.
+func checkMatrix(name string, m [][]*big.Int, rows, cols int) error {
+	if len(m) != rows {
+		return fmt.Errorf("invalid %s: should have %d rows, got %d", name, rows, len(m))
+	}
+
+	for i := range m {
+		if len(m[i]) != cols {
+			return fmt.Errorf("invalid %s: row %d should have %d columns, got %d", name, i, cols, len(m[i]))
+		}
+	}
+
+	return nil
+}
+
+// checkHVec checks that HVec contains the StructuralSlots blinding slots (HVec[0] is also used as the blinding base
+// for the commitments) followed by Nv slots for the committed vectors.
+func (p *ArithmeticCircuitPublic) checkHVec() error {
+	if len(p.HVec) < StructuralSlots+p.Nv {
+		return fmt.Errorf("invalid HVec length: should be at least %d+Nv = %d (structural blinding slots), got %d", StructuralSlots, StructuralSlots+p.Nv, len(p.HVec))
+	}
+
+	return nil
+}
+
 // VerifyCircuit verifies BP++ arithmetic circuit zero-knowledge proof using WNLA protocol. If err is nil then proof is valid.
 // Use empty FiatShamirEngine for call.
 func VerifyCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	if err := public.checkVerify(V, fs, proof); err != nil {
+		return err
+	}
+
+	return newVerifyScratch(public).verify(V, fs, proof)
+}
+
+// VerifyCircuitAudit verifies the proof as VerifyCircuit and returns the Fiat-Shamir challenges derived by the
+// verifier, so the auditor can compare them with the challenges claimed by the prover and recompute them from the
+// transcript independently. The challenges are returned also if the proof is rejected after their derivation, and
+// are zero if the proof is rejected before. Use empty FiatShamirEngine for call.
+func VerifyCircuitAudit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) (CircuitChallenges, error) {
+	if err := public.checkVerify(V, fs, proof); err != nil {
+		return CircuitChallenges{}, err
+	}
+
+	challenges, err := newVerifyScratch(public).verifyWithChallenges(V, fs, proof)
+	return *challenges, err
+}
+
+// checkVerify validates the circuit, the value commitments and the proof structure before the verification.
+func (p *ArithmeticCircuitPublic) checkVerify(V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	if err := p.checkHVec(); err != nil {
+		return err
+	}
+
+	if err := ValidatePartition(p); err != nil {
+		return err
+	}
+
+	if err := p.checkCommitments(V); err != nil {
+		return err
+	}
+
+	// Reject malformed proofs before the expensive matrix computations
+	if err := p.checkProofStructure(proof); err != nil {
+		return err
+	}
+
+	return checkEngine(fs, proof)
+}
+
+// NewVerifyScratch validates the circuit and precomputes the challenge independent values of the verification:
+// the M matrices derived from Wm, Wl and the partition F, and the WNLA generators GVec||GVec_ and HVec||HVec_.
+// Use it with VerifyCircuitWithScratch to verify many proofs for the same circuit without recomputing them.
+// The circuit should not be modified after the scratch creation. Scratch is not modified during verification,
+// so it can be shared between goroutines.
+func NewVerifyScratch(public *ArithmeticCircuitPublic) (*VerifyScratch, error) {
+	if err := public.checkHVec(); err != nil {
+		return nil, err
+	}
+
+	if err := ValidatePartition(public); err != nil {
+		return nil, err
+	}
+
+	return newVerifyScratch(public), nil
+}
+
+// VerifyCircuitWithScratch verifies the proof for the circuit the scratch was created for. If err is nil then proof
+// is valid. Use empty FiatShamirEngine for call.
+func VerifyCircuitWithScratch(scratch *VerifyScratch, V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	if err := scratch.public.checkCommitments(V); err != nil {
+		return err
+	}
+
+	if err := scratch.public.checkProofStructure(proof); err != nil {
+		return err
+	}
+
+	if err := checkEngine(fs, proof); err != nil {
+		return err
+	}
+
+	return scratch.verify(V, fs, proof)
+}
+
+// CompileCircuit validates the circuit and precomputes the challenge independent values of the verification. Use it
+// with VerifyCircuitCompiled. The result can be serialized with Marshal and cached by Digest.
+func CompileCircuit(public *ArithmeticCircuitPublic) (*CompiledCircuit, error) {
+	scratch, err := NewVerifyScratch(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompiledCircuit{scratch: scratch}, nil
+}
+
+// Summary returns the compiled circuit dimensions and generators requirements.
+func (c *CompiledCircuit) Summary() CircuitSummary {
+	return c.scratch.public.Summary()
+}
+
+// Digest returns Keccak256 of the compiled circuit encoding. The same circuits have the same digests.
+func (c *CompiledCircuit) Digest() [32]byte {
+	h := newKeccakState()
+	h.Write(c.Marshal())
+
+	var res [32]byte
+	copy(res[:], h.Sum(nil))
+	return res
+}
+
+// VerifyCircuitCompiled verifies the proof for the compiled circuit with the same result as VerifyCircuit for the
+// original one. If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyCircuitCompiled(compiled *CompiledCircuit, V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	return VerifyCircuitWithScratch(compiled.scratch, V, fs, proof)
+}
+
+func newVerifyScratch(public *ArithmeticCircuitPublic) *VerifyScratch {
+	return &VerifyScratch{
+		public:   public,
+		matrices: newCircuitMatrices(public),
+		gVec:     concatPoints(public.GVec, public.GVec_),
+		hVec:     concatPoints(public.HVec, public.HVec_),
+	}
+}
+
+// verify verifies the proof for the validated circuit, commitments and proof structure.
+func (s *VerifyScratch) verify(V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	_, err := s.verifyWithChallenges(V, fs, proof)
+	return err
+}
+
+// verifyWithChallenges verifies the proof and returns the derived challenges.
+func (s *VerifyScratch) verifyWithChallenges(V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) (*CircuitChallenges, error) {
+	challenges := DeriveCircuitChallenges(fs, V, proof)
+
+	CT, cT := computeCT(s.public, s.matrices, proof, V, challenges)
+
+	return challenges, s.verifyWNLA(CT, cT, challenges, fs, proof)
+}
+
+// verifyWNLA verifies the WNLA proof of the circuit proof for the commitment CT and the linear vector cT.
+func (s *VerifyScratch) verifyWNLA(CT *bn256.G1, cT []*big.Int, challenges *CircuitChallenges, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	return VerifyWNLA(s.wnlaPublic(cT, challenges), proof.WNLA, CT, fs)
+}
+
+// finalEquation derives the challenges and returns the final WNLA equation of the proof, see wnlaFinalEquation.
+// The proof is valid iff the equation holds.
+func (s *VerifyScratch) finalEquation(V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) ([]*bn256.G1, []*big.Int, *bn256.G1, error) {
+	challenges := DeriveCircuitChallenges(fs, V, proof)
+
+	CT, cT := computeCT(s.public, s.matrices, proof, V, challenges)
+
+	return wnlaFinalEquation(s.wnlaPublic(cT, challenges), proof.WNLA, CT, fs)
+}
+
+// wnlaPublic returns the WNLA public parameters of the circuit proof for the linear vector cT.
+func (s *VerifyScratch) wnlaPublic(cT []*big.Int, challenges *CircuitChallenges) *WeightNormLinearPublic {
+	res := &WeightNormLinearPublic{
+		G:    s.public.G,
+		GVec: s.gVec,
+		HVec: s.hVec,
+		C:    cT,
+		Ro:   challenges.Ro,
+		Mu:   mul(challenges.Ro, challenges.Ro),
+	}
+
+	res.gTable.inherit(&s.public.gTable)
+	return res
+}
+
+// VerifyCircuitGivenCT verifies only the WNLA part of the circuit proof for the commitment CT computed by the caller,
+// e.g. off-chain with ComputeCT. Use the FiatShamirEngine that has been passed to DeriveCircuitChallenges to derive
+// the challenges, so it continues the transcript from the t challenge.
+//
+// Security boundary: the function trusts both CT and challenges. It does not check that the challenges are derived
+// from the transcript of the proof commitments and the value commitments V, nor that CT is derived from V and the
+// proof commitments CL, CR, CO, CS. The statement is proven only if the caller guarantees that challenges =
+// DeriveCircuitChallenges(fs, V, proof) and CT = ComputeCT(public, proof, V, challenges) for the same proof and V.
+// Any other challenges or CT make the result meaningless.
+func VerifyCircuitGivenCT(public *ArithmeticCircuitPublic, CT *bn256.G1, challenges *CircuitChallenges, proof *ArithmeticCircuitProof, fs FiatShamirEngine) error {
+	if err := public.checkHVec(); err != nil {
+		return err
+	}
+
+	if err := ValidatePartition(public); err != nil {
+		return err
+	}
+
+	if err := public.checkProofStructure(proof); err != nil {
+		return err
+	}
+
+	if err := checkEngine(fs, proof); err != nil {
+		return err
+	}
+
+	scratch := newVerifyScratch(public)
+	_, _, cT := computeCTWeights(public, scratch.matrices, challenges)
+
+	return scratch.verifyWNLA(CT, cT, challenges, fs, proof)
+}
+
+// Phase tags absorbed into the transcript at the phase boundaries of the circuit protocol: after the commitments
+// CL, CR, CO, V and after the blinding commitment CS. They separate the phases, so the absorbed data of one phase
+// can not be reinterpreted as the data of another one.
+var (
+	transcriptPhaseCommitments = HashToScalar([]byte("bulletproofs/circuit/commitments"))
+	transcriptPhaseBlinding    = HashToScalar([]byte("bulletproofs/circuit/blinding"))
+)
+
+// DeriveCircuitChallenges absorbs the proof commitments CL, CR, CO, the value commitments V and CS into the
+// transcript and returns the challenges in the same order as the prover and verifier derive them:
+// ro, lambda, beta, delta before CS is absorbed, and t after. Every phase is closed with its tag.
+// Use empty FiatShamirEngine for call.
+func DeriveCircuitChallenges(fs FiatShamirEngine, V []*bn256.G1, proof *ArithmeticCircuitProof) *CircuitChallenges {
+	absorbCircuitCommitments(fs, V, proof)
+
+	// Generates challenges using Fiat-Shamir heuristic
+	res := &CircuitChallenges{
+		Ro:     fs.GetChallenge(),
+		Lambda: fs.GetChallenge(),
+		Beta:   fs.GetChallenge(),
+		Delta:  fs.GetChallenge(),
+	}
+
+	fs.AddPoint(proof.CS)
+	fs.AddNumber(transcriptPhaseBlinding)
+
+	// Select random t using Fiat-Shamir heuristic
+	res.T = fs.GetChallenge()
+
+	return res
+}
+
+// FirstChallenge returns the challenge ro that VerifyCircuit derives for the value commitments V with the same
+// FiatShamirEngine, e.g. to index the proofs. Only CL, CR, CO and V are absorbed before ro, so it is computed without
+// the verification. Returns nil for the malformed proof, the wrong commitments count or the proof generated with the
+// other engine (see ArithmeticCircuitProof.Engine). Use empty FiatShamirEngine for call.
+func (p *ArithmeticCircuitPublic) FirstChallenge(V []*bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) *big.Int {
+	if p.checkCommitments(V) != nil || p.checkProofStructure(proof) != nil || checkEngine(fs, proof) != nil {
+		return nil
+	}
+
+	absorbCircuitCommitments(fs, V, proof)
+	return fs.GetChallenge()
+}
+
+// absorbCircuitCommitments absorbs the proof commitments CL, CR, CO and the value commitments V.
+func absorbCircuitCommitments(fs FiatShamirEngine, V []*bn256.G1, proof *ArithmeticCircuitProof) {
 	fs.AddPoint(proof.CL)
 	fs.AddPoint(proof.CR)
 	fs.AddPoint(proof.CO)
@@ -29,37 +678,93 @@ func VerifyCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamir
 		fs.AddPoint(V[i])
 	}
 
-	// Generates challenges using Fiat-Shamir heuristic
-	ro := fs.GetChallenge()
-	lambda := fs.GetChallenge()
-	beta := fs.GetChallenge()
-	delta := fs.GetChallenge()
+	fs.AddNumber(transcriptPhaseCommitments)
+}
 
-	MlnL, MmnL, MlnR, MmnR := calculateMRL(public)
-	MlnO, MmnO, MllL, MmlL, MllR, MmlR, MllO, MmlO := calculateMO(public)
+// LinearCombineCommitments returns the linear combination of the value commitments V_ = 2 * sum(lcomb_i * V_i)
+// used in CT, where lcomb_i = Fl*lambda^(Nv*i) + Fm*mu^(Nv*i+1). The same combination of the committed vectors
+// is used by the prover. Returns error if the commitments count differs from K.
+func LinearCombineCommitments(public *ArithmeticCircuitPublic, V []*bn256.G1, lambda, mu *big.Int) (*bn256.G1, error) {
+	if err := public.checkCommitments(V); err != nil {
+		return nil, err
+	}
 
-	mu := mul(ro, ro)
+	return linearCombineCommitments(public, V, lambda, mu), nil
+}
+
+// linearCombineCommitments works as LinearCombineCommitments for the already checked commitments.
+func linearCombineCommitments(public *ArithmeticCircuitPublic, V []*bn256.G1, lambda, mu *big.Int) *bn256.G1 {
+	lcomb := linearCombinationWeights(public, lambda, mu)
+
+	V_ := new(bn256.G1).ScalarBaseMult(bint(0)) // set infinite
+	for i := 0; i < public.K; i++ {
+		V_.Add(V_, new(bn256.G1).ScalarMult(V[i], lcomb[i]))
+	}
+
+	return V_.ScalarMult(V_, bint(2))
+}
 
-	lcomb := func(i int) *big.Int {
-		return add(
+// linearCombinationWeights returns the weights lcomb_i = Fl*lambda^(Nv*i) + Fm*mu^(Nv*i+1) for i in [0, K).
+func linearCombinationWeights(public *ArithmeticCircuitPublic, lambda, mu *big.Int) []*big.Int {
+	res := make([]*big.Int, public.K)
+	for i := range res {
+		res[i] = add(
 			mul(bbool(public.Fl), pow(lambda, public.Nv*i)),
 			mul(bbool(public.Fm), pow(mu, public.Nv*i+1)),
 		)
 	}
 
+	return res
+}
+
+// ComputeCT returns the commitment CT that is verified with WNLA protocol during circuit verification
+// for the given Fiat-Shamir challenges. Returns error if the commitments count differs from K.
+func ComputeCT(public *ArithmeticCircuitPublic, proof *ArithmeticCircuitProof, V []*bn256.G1, challenges *CircuitChallenges) (*bn256.G1, error) {
+	if err := public.checkCommitments(V); err != nil {
+		return nil, err
+	}
+
+	CT, _ := computeCT(public, newCircuitMatrices(public), proof, V, challenges)
+	return CT, nil
+}
+
+// computeCT returns the commitment CT and the WNLA c vector for the given Fiat-Shamir challenges and the checked
+// commitments V.
+func computeCT(public *ArithmeticCircuitPublic, m *circuitMatrices, proof *ArithmeticCircuitProof, V []*bn256.G1, challenges *CircuitChallenges) (*bn256.G1, []*big.Int) {
+	psT, pnT, cT := computeCTWeights(public, m, challenges)
+
+	t := challenges.T
+	tinv := inv(t)
+	t2 := mul(t, t)
+	t3 := mul(t2, t)
+
 	// Calculate linear combination of V
-	V_ := func() *bn256.G1 {
-		var V_ = new(bn256.G1).ScalarBaseMult(bint(0)) // set infinite
+	V_ := linearCombineCommitments(public, V, challenges.Lambda, mul(challenges.Ro, challenges.Ro))
 
-		for i := 0; i < public.K; i++ {
-			V_ = V_.Add(V_, new(bn256.G1).ScalarMult(
-				V[i],
-				lcomb(i),
-			))
-		}
+	PT := new(bn256.G1).ScalarMult(public.G, psT)
+	PT.Add(PT, vectorPointScalarMul(public.GVec, pnT))
 
-		return V_.ScalarMult(V_, bint(2))
-	}()
+	CT := new(bn256.G1).Add(PT, new(bn256.G1).ScalarMult(proof.CS, tinv))
+	CT.Add(CT, new(bn256.G1).ScalarMult(proof.CO, minus(challenges.Delta)))
+	CT.Add(CT, new(bn256.G1).ScalarMult(proof.CL, t))
+	CT.Add(CT, new(bn256.G1).ScalarMult(proof.CR, minus(t2)))
+	CT.Add(CT, new(bn256.G1).ScalarMult(V_, t3))
+
+	return CT, cT
+}
+
+// computeCTWeights returns the challenge dependent scalars of CT: the G scalar psT, the GVec scalars pnT,
+// and the WNLA linear vector cT.
+func computeCTWeights(public *ArithmeticCircuitPublic, m *circuitMatrices, challenges *CircuitChallenges) (*big.Int, []*big.Int, []*big.Int) {
+	ro := challenges.Ro
+	lambda := challenges.Lambda
+	beta := challenges.Beta
+	delta := challenges.Delta
+
+	MlnL, MmnL, MlnR, MmnR := m.MlnL, m.MmnL, m.MlnR, m.MmnR
+	MlnO, MmnO, MllL, MmlL, MllR, MmlR, MllO, MmlO := m.MlnO, m.MmnO, m.MllL, m.MmlL, m.MllR, m.MmlR, m.MllO, m.MmlO
+
+	mu := mul(ro, ro)
 
 	// Calculate lambda vector (nl == nv * k)
 	lambdaVec := vectorAdd(
@@ -74,20 +779,15 @@ func VerifyCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamir
 	muVec := vectorMulOnScalar(e(mu, public.Nm), mu) // Nm
 
 	// Calculate coefficients clX, X = {L,R,O}
-	muDiagInv := diagInv(mu, public.Nm) // Nm*Nm
-
-	cnL := vectorMulOnMatrix(vectorSub(vectorMulOnMatrix(lambdaVec, MlnL), vectorMulOnMatrix(muVec, MmnL)), muDiagInv) // Nm
-	cnR := vectorMulOnMatrix(vectorSub(vectorMulOnMatrix(lambdaVec, MlnR), vectorMulOnMatrix(muVec, MmnR)), muDiagInv) // Nm
-	cnO := vectorMulOnMatrix(vectorSub(vectorMulOnMatrix(lambdaVec, MlnO), vectorMulOnMatrix(muVec, MmnO)), muDiagInv) // Nm
+	cnL := diagInvMul(coefficients(lambdaVec, muVec, MlnL, MmnL), mu) // Nm
+	cnR := diagInvMul(coefficients(lambdaVec, muVec, MlnR, MmnR), mu) // Nm
+	cnO := diagInvMul(coefficients(lambdaVec, muVec, MlnO, MmnO), mu) // Nm
 
-	clL := vectorSub(vectorMulOnMatrix(lambdaVec, MllL), vectorMulOnMatrix(muVec, MmlL)) // Nv
-	clR := vectorSub(vectorMulOnMatrix(lambdaVec, MllR), vectorMulOnMatrix(muVec, MmlR)) // Nv
-	clO := vectorSub(vectorMulOnMatrix(lambdaVec, MllO), vectorMulOnMatrix(muVec, MmlO)) // Nv
+	clL := coefficients(lambdaVec, muVec, MllL, MmlL) // Nv
+	clR := coefficients(lambdaVec, muVec, MllR, MmlR) // Nv
+	clO := coefficients(lambdaVec, muVec, MllO, MmlO) // Nv
 
-	fs.AddPoint(proof.CS)
-
-	// Select random t using Fiat-Shamir heuristic
-	t := fs.GetChallenge()
+	t := challenges.T
 	tinv := inv(t)
 	t2 := mul(t, t)
 	t3 := mul(t2, t)
@@ -100,9 +800,6 @@ func VerifyCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamir
 	psT = add(psT, mul(bint(2), mul(vectorMul(lambdaVec, public.Al), t3)))
 	psT = sub(psT, mul(bint(2), mul(vectorMul(muVec, public.Am), t3)))
 
-	PT := new(bn256.G1).ScalarMult(public.G, psT)
-	PT.Add(PT, vectorPointScalarMul(public.GVec, pnT))
-
 	cr_T := []*big.Int{
 		bint(1),
 		mul(beta, tinv),
@@ -126,35 +823,35 @@ func VerifyCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamir
 	cl_T = vectorMulOnScalar(cl_T, bint(2))
 	cl_T = vectorSub(cl_T, cl0)
 
-	cT := append(cr_T, cl_T...)
-
-	CT := new(bn256.G1).Add(PT, new(bn256.G1).ScalarMult(proof.CS, tinv))
-	CT.Add(CT, new(bn256.G1).ScalarMult(proof.CO, minus(delta)))
-	CT.Add(CT, new(bn256.G1).ScalarMult(proof.CL, t))
-	CT.Add(CT, new(bn256.G1).ScalarMult(proof.CR, minus(t2)))
-	CT.Add(CT, new(bn256.G1).ScalarMult(V_, t3))
-
-	return VerifyWNLA(
-		&WeightNormLinearPublic{
-			G:    public.G,
-			GVec: append(public.GVec, public.GVec_...),
-			HVec: append(public.HVec, public.HVec_...),
-			C:    cT,
-			Ro:   ro,
-			Mu:   mu,
-		},
-		proof.WNLA,
-		CT,
-		fs,
-	)
+	return psT, pnT, append(cr_T, cl_T...)
 }
 
 // ProveCircuit generates zero knowledge proof that witness satisfies BP++ arithmetic circuit.
 // Use empty FiatShamirEngine for call.
-func ProveCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirEngine, private *ArithmeticCircuitPrivate) *ArithmeticCircuitProof {
-	ro, rl, no, nl, lo, ll, Co, Cl := commitOL(public, private.Wo, private.Wl)
+func ProveCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirEngine, private *ArithmeticCircuitPrivate) (*ArithmeticCircuitProof, error) {
+	if err := public.checkHVec(); err != nil {
+		return nil, err
+	}
 
-	rr, nr, lr, Cr := commitR(public, private.Wo, private.Wr)
+	if err := ValidatePartition(public); err != nil {
+		return nil, err
+	}
+
+	if err := public.checkCommitments(V); err != nil {
+		return nil, err
+	}
+
+	if len(private.V) != public.K || len(private.Sv) != public.K {
+		return nil, errors.New("invalid witness: V and Sv should contain K elements")
+	}
+
+	done := profilePhase(public.Profiler, "commitOL")
+	ro, rl, no, nl, lo, ll, Co, Cl := commitOL(public, private.Wo, private.Wl, blindingSource(!private.NonHiding, private.Rand))
+	done()
+
+	done = profilePhase(public.Profiler, "commitR")
+	rr, nr, lr, Cr := commitR(public, private.Wo, private.Wr, blindingSource(!private.NonHiding, private.Rand))
+	done()
 
 	fs.AddPoint(Cl)
 	fs.AddPoint(Cr)
@@ -164,18 +861,51 @@ func ProveCircuit(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirE
 		fs.AddPoint(V[i])
 	}
 
-	return innerArithmeticCircuitProve(public, fs, private,
+	proof := innerArithmeticCircuitProve(public, fs, private,
 		[][]*big.Int{rl, rr, ro},
 		[][]*big.Int{nl, nr, no},
 		[][]*big.Int{ll, lr, lo},
 		[]*bn256.G1{Cl, Cr, Co},
 	)
+
+	proof.Engine = engineID(fs)
+	return proof, nil
 }
 
-func commitOL(public *ArithmeticCircuitPublic, wo, wl []*big.Int) (ro []*big.Int, rl []*big.Int, no []*big.Int, nl []*big.Int, lo []*big.Int, ll []*big.Int, Co *bn256.G1, Cl *bn256.G1) {
+// ProveCircuitWithMetadata generates the same proof as ProveCircuit with the metadata absorbed into the transcript
+// before the challenges, so the proof can not be verified without the same metadata. Use empty FiatShamirEngine for call.
+func ProveCircuitWithMetadata(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirEngine, m *Metadata, private *ArithmeticCircuitPrivate) (*ArithmeticCircuitProof, error) {
+	if err := BindMetadata(fs, m); err != nil {
+		return nil, err
+	}
+
+	return ProveCircuit(public, V, fs, private)
+}
+
+// VerifyCircuitWithMetadata verifies the proof generated by ProveCircuitWithMetadata. Returns error if the proof has
+// expired (m.Now is after m.NotAfter) or the metadata differs from the prover's one.
+// Use empty FiatShamirEngine for call.
+func VerifyCircuitWithMetadata(public *ArithmeticCircuitPublic, V []*bn256.G1, fs FiatShamirEngine, m *Metadata, proof *ArithmeticCircuitProof) error {
+	if err := BindMetadata(fs, m); err != nil {
+		return err
+	}
+
+	now := m.Now
+	if now == nil {
+		now = func() int64 { return time.Now().Unix() }
+	}
+
+	if now() > m.NotAfter {
+		return errors.New("proof has expired")
+	}
+
+	return VerifyCircuit(public, V, fs, proof)
+}
+
+func commitOL(public *ArithmeticCircuitPublic, wo, wl []*big.Int, blind func() *big.Int) (ro []*big.Int, rl []*big.Int, no []*big.Int, nl []*big.Int, lo []*big.Int, ll []*big.Int, Co *bn256.G1, Cl *bn256.G1) {
 	// contains random values, except several positions
-	ro = []*big.Int{MustRandScalar(), MustRandScalar(), MustRandScalar(), MustRandScalar(), bint(0), MustRandScalar(), MustRandScalar(), MustRandScalar(), bint(0)} // 9
-	rl = []*big.Int{MustRandScalar(), MustRandScalar(), MustRandScalar(), bint(0), MustRandScalar(), MustRandScalar(), MustRandScalar(), bint(0), bint(0)}          // 9
+	ro = []*big.Int{blind(), blind(), blind(), blind(), bint(0), blind(), blind(), blind(), bint(0)} // 9
+	rl = []*big.Int{blind(), blind(), blind(), bint(0), blind(), blind(), blind(), bint(0), bint(0)} // 9
 
 	nl = wl // Nm
 
@@ -215,9 +945,9 @@ func commitOL(public *ArithmeticCircuitPublic, wo, wl []*big.Int) (ro []*big.Int
 	return
 }
 
-func commitR(public *ArithmeticCircuitPublic, wo, wr []*big.Int) (rr []*big.Int, nr []*big.Int, lr []*big.Int, Cr *bn256.G1) {
+func commitR(public *ArithmeticCircuitPublic, wo, wr []*big.Int, blind func() *big.Int) (rr []*big.Int, nr []*big.Int, lr []*big.Int, Cr *bn256.G1) {
 	// contains random values, except several positions
-	rr = []*big.Int{MustRandScalar(), MustRandScalar(), bint(0), MustRandScalar(), MustRandScalar(), MustRandScalar(), bint(0), bint(0), bint(0)} // 9
+	rr = []*big.Int{blind(), blind(), bint(0), blind(), blind(), blind(), bint(0), bint(0), bint(0)} // 9
 
 	nr = wr // Nm
 
@@ -236,40 +966,29 @@ func commitR(public *ArithmeticCircuitPublic, wo, wr []*big.Int) (rr []*big.Int,
 	return
 }
 
-func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirEngine, private *ArithmeticCircuitPrivate, r, n, l [][]*big.Int, C []*bn256.G1) *ArithmeticCircuitProof {
-	rl := r[0] // 8
-	rr := r[1] // 8
-	ro := r[2] // 8
-
-	ll := l[0] // Nv
-	lr := l[1] // Nv
-	lo := l[2] // Nv
-
-	nl := n[0] // Nm
-	nr := n[1] // Nm
-	no := n[2] // Nm
-
-	Cl := C[0]
-	Cr := C[1]
-	Co := C[2]
+// circuitPolynomial contains the values of innerArithmeticCircuitProve that define the f'(t) polynomial: the blinding
+// vectors rX, the witness vectors lX, nX, the linear combinations v_, v_1 of the committed values, the coefficients
+// cnX, clX and the challenges.
+type circuitPolynomial struct {
+	Ro, Rl, Rr, Rv                    []*big.Int
+	Ls, Lo, Ll, Lr, V1                []*big.Int
+	Ns, No, Nl, Nr                    []*big.Int
+	CnL, CnR, CnO, ClL, ClR, ClO, Cl0 []*big.Int
+	V                                 *big.Int
+	LambdaVec, MuVec                  []*big.Int
+	Beta, Delta, Mu                   *big.Int
+}
 
-	proof := &ArithmeticCircuitProof{
-		CL: Cl,
-		CR: Cr,
-		CO: Co,
+// newCircuitPolynomial returns the circuitPolynomial for the witness, the committed vectors r, n, l and the
+// challenges. The blinding vectors ls and ns are left empty for the caller.
+func newCircuitPolynomial(public *ArithmeticCircuitPublic, private *ArithmeticCircuitPrivate, r, n, l [][]*big.Int, lambda, beta, delta, mu *big.Int) *circuitPolynomial {
+	p := &circuitPolynomial{
+		Rl: r[0], Rr: r[1], Ro: r[2],
+		Nl: n[0], Nr: n[1], No: n[2],
+		Ll: l[0], Lr: l[1], Lo: l[2],
+		Beta: beta, Delta: delta, Mu: mu,
 	}
 
-	// Generates challenges using Fiat-Shamir heuristic
-	rho := fs.GetChallenge()
-	lambda := fs.GetChallenge()
-	beta := fs.GetChallenge()
-	delta := fs.GetChallenge()
-
-	MlnL, MmnL, MlnR, MmnR := calculateMRL(public)
-	MlnO, MmnO, MllL, MmlL, MllR, MmlR, MllO, MmlO := calculateMO(public)
-
-	mu := mul(rho, rho)
-
 	// Calculate lambda vector (nl == nv * k)
 	lambdaVec := vectorAdd(
 		vectorTensorMul(vectorMulOnScalar(e(lambda, public.Nv), mu), e(pow(mu, public.Nv), public.K)),
@@ -277,62 +996,51 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 	)
 
 	lambdaVec = vectorMulOnScalar(lambdaVec, bbool(public.Fl && public.Fm))
-	lambdaVec = vectorSub(e(lambda, public.Nl), lambdaVec) //Nl
+	p.LambdaVec = vectorSub(e(lambda, public.Nl), lambdaVec) //Nl
 
 	// Calculate mu vector
-	muVec := vectorMulOnScalar(e(mu, public.Nm), mu) // Nm
-
-	// Calculate coefficients clX, X = {L,R,O}
-	muDiagInv := diagInv(mu, public.Nm) // Nm*Nm
-
-	cnL := vectorMulOnMatrix(vectorSub(vectorMulOnMatrix(lambdaVec, MlnL), vectorMulOnMatrix(muVec, MmnL)), muDiagInv) // Nm
-	cnR := vectorMulOnMatrix(vectorSub(vectorMulOnMatrix(lambdaVec, MlnR), vectorMulOnMatrix(muVec, MmnR)), muDiagInv) // Nm
-	cnO := vectorMulOnMatrix(vectorSub(vectorMulOnMatrix(lambdaVec, MlnO), vectorMulOnMatrix(muVec, MmnO)), muDiagInv) // Nm
+	p.MuVec = vectorMulOnScalar(e(mu, public.Nm), mu) // Nm
 
-	clL := vectorSub(vectorMulOnMatrix(lambdaVec, MllL), vectorMulOnMatrix(muVec, MmlL)) // Nv
-	clR := vectorSub(vectorMulOnMatrix(lambdaVec, MllR), vectorMulOnMatrix(muVec, MmlR)) // Nv
-	clO := vectorSub(vectorMulOnMatrix(lambdaVec, MllO), vectorMulOnMatrix(muVec, MmlO)) // Nv
+	// Calculate coefficients clX, X = {L,R,O}. The M matrices are built by pairs right before use,
+	// so they can be collected before the next pair is built and the prover never holds all of them.
+	MlnL, MmnL, MlnR, MmnR := calculateMRL(public) // sub-slices of Wl and Wm
 
-	// Prover computes
-	ls := make([]*big.Int, public.Nv) // Nv
-	for i := range ls {
-		ls[i] = MustRandScalar()
+	partitionCoefficients := func(typ PartitionType, cols int) []*big.Int {
+		return coefficients(p.LambdaVec, p.MuVec, public.partitionMatrix(public.Wl, typ, cols), public.partitionMatrix(public.Wm, typ, cols))
 	}
 
-	ns := make([]*big.Int, public.Nm) // Nm
-	for i := range ns {
-		ns[i] = MustRandScalar()
-	}
+	p.CnL = diagInvMul(coefficients(p.LambdaVec, p.MuVec, MlnL, MmnL), mu) // Nm
+	p.CnR = diagInvMul(coefficients(p.LambdaVec, p.MuVec, MlnR, MmnR), mu) // Nm
+	p.CnO = diagInvMul(partitionCoefficients(PartitionNO, public.Nm), mu)  // Nm
 
-	lcomb := func(i int) *big.Int {
-		return add(
-			mul(bbool(public.Fl), pow(lambda, public.Nv*i)),
-			mul(bbool(public.Fm), pow(mu, public.Nv*i+1)),
-		)
-	}
+	p.ClL = partitionCoefficients(PartitionLL, public.Nv) // Nv
+	p.ClR = partitionCoefficients(PartitionLR, public.Nv) // Nv
+	p.ClO = partitionCoefficients(PartitionLO, public.Nv) // Nv
+
+	lcomb := linearCombinationWeights(public, lambda, mu)
 
 	// Calc linear combination of v[][0]
-	v_ := func() *big.Int {
+	p.V = func() *big.Int {
 		v_ := bint(0)
 
 		for i := 0; i < public.K; i++ {
 			v_ = add(v_, mul(
 				private.V[i][0],
-				lcomb(i),
+				lcomb[i],
 			))
 		}
 
 		return mul(v_, bint(2))
 	}()
 
-	rv := zeroVector(9) // 9
-	rv[0] = func() *big.Int {
+	p.Rv = zeroVector(StructuralSlots)
+	p.Rv[0] = func() *big.Int {
 		rv1 := bint(0)
 
 		for i := 0; i < public.K; i++ {
 			rv1 = add(rv1, mul(
 				private.Sv[i],
-				lcomb(i),
+				lcomb[i],
 			))
 		}
 
@@ -340,25 +1048,34 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 	}()
 
 	// Calc linear combination of v[][1:]
-	v_1 := func() []*big.Int {
+	p.V1 = func() []*big.Int {
 		var v_1 = zeroVector(1)
 
 		for i := 0; i < public.K; i++ {
 			v_1 = vectorAdd(v_1, vectorMulOnScalar(
 				private.V[i][1:],
-				lcomb(i),
+				lcomb[i],
 			))
 		}
 
 		return vectorMulOnScalar(v_1, bint(2))
 	}()
 
-	cl0 := vectorSub(
+	p.Cl0 = vectorSub(
 		vectorMulOnScalar(e(lambda, public.Nv)[1:], bbool(public.Fl)),
 		vectorMulOnScalar(vectorMulOnScalar(e(mu, public.Nv)[1:], mu), bbool(public.Fm)),
 	)
 
-	// Define f'(t):
+	return p
+}
+
+// coefficients returns the coefficients of the prover's f'(t) polynomial by the power of t, from t^-2 to t^6.
+func (p *circuitPolynomial) coefficients(public *ArithmeticCircuitPublic) map[int]*big.Int {
+	ls, lo, ll, lr, v_1 := p.Ls, p.Lo, p.Ll, p.Lr, p.V1
+	ns, no, nl, nr := p.Ns, p.No, p.Nl, p.Nr
+	cnL, cnR, cnO, clL, clR, clO, cl0 := p.CnL, p.CnR, p.CnO, p.ClL, p.ClR, p.ClO, p.Cl0
+	v_, lambdaVec, muVec, delta, mu := p.V, p.LambdaVec, p.MuVec, p.Delta, p.Mu
+
 	f_ := make(map[int]*big.Int)
 
 	f_[-2] = sub(f_[-2], weightVectorMul(ns, ns, mu))
@@ -413,9 +1130,16 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 	f_[3] = add(f_[3], mul(weightVectorMul(no, cnO, mu), bint(2)))
 	f_[3] = add(f_[3], mul(weightVectorMul(vectorAdd(nl, cnR), vectorAdd(nr, cnL), mu), bint(2)))
 
+	return f_
+}
+
+// blinding returns the structural slots rs of the blinding vector that cancel the f'(t) coefficients.
+func (p *circuitPolynomial) blinding(f_ map[int]*big.Int) []*big.Int {
+	rl, rr, ro, rv, beta, delta := p.Rl, p.Rr, p.Ro, p.Rv, p.Beta, p.Delta
+
 	ch_beta_inv := inv(beta)
 
-	rs := []*big.Int{
+	return []*big.Int{
 		add(f_[-1], mul(beta, mul(delta, ro[1]))),
 		mul(f_[-2], ch_beta_inv),
 		sub(mul(add(f_[0], mul(delta, ro[0])), ch_beta_inv), rl[1]),
@@ -426,6 +1150,57 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 		add(mul(f_[5], ch_beta_inv), sub(add(rr[4], mul(delta, ro[6])), rl[5])),
 		add(mul(f_[6], ch_beta_inv), add(sub(mul(delta, ro[7]), rl[6]), rr[5])),
 	} // 9
+}
+
+func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirEngine, private *ArithmeticCircuitPrivate, r, n, l [][]*big.Int, C []*bn256.G1) *ArithmeticCircuitProof {
+	Cl := C[0]
+	Cr := C[1]
+	Co := C[2]
+
+	proof := &ArithmeticCircuitProof{
+		CL: Cl,
+		CR: Cr,
+		CO: Co,
+	}
+
+	fs.AddNumber(transcriptPhaseCommitments)
+
+	// Generates challenges using Fiat-Shamir heuristic
+	rho := fs.GetChallenge()
+	lambda := fs.GetChallenge()
+	beta := fs.GetChallenge()
+	delta := fs.GetChallenge()
+
+	done := profilePhase(public.Profiler, "matrices")
+
+	mu := mul(rho, rho)
+	p := newCircuitPolynomial(public, private, r, n, l, lambda, beta, delta, mu)
+
+	done()
+
+	done = profilePhase(public.Profiler, "polynomial")
+
+	// Prover computes
+	blind := blindingSource(!private.NonHiding, private.Rand)
+
+	p.Ls = make([]*big.Int, public.Nv) // Nv
+	for i := range p.Ls {
+		p.Ls[i] = blind()
+	}
+
+	p.Ns = make([]*big.Int, public.Nm) // Nm
+	for i := range p.Ns {
+		p.Ns[i] = blind()
+	}
+
+	rl, rr, ro, rv := p.Rl, p.Rr, p.Ro, p.Rv
+	ls, lo, ll, lr, v_1 := p.Ls, p.Lo, p.Ll, p.Lr, p.V1
+	ns, no, nl, nr := p.Ns, p.No, p.Nl, p.Nr
+	cnL, cnR, cnO, clL, clR, clO, cl0 := p.CnL, p.CnR, p.CnO, p.ClL, p.ClR, p.ClO, p.Cl0
+	v_, lambdaVec, muVec := p.V, p.LambdaVec, p.MuVec
+
+	// Define f'(t) and the blinding rs that cancels it
+	rs := p.blinding(p.coefficients(public)) // 9
 
 	Cs := vectorPointScalarMul(public.HVec, append(rs, ls...))
 	Cs.Add(Cs, vectorPointScalarMul(public.GVec, ns))
@@ -433,6 +1208,7 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 	proof.CS = Cs
 
 	fs.AddPoint(Cs)
+	fs.AddNumber(transcriptPhaseBlinding)
 
 	// Select random t using Fiat-Shamir heuristic
 	t := fs.GetChallenge()
@@ -461,7 +1237,7 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 
 	nT := vectorAdd(pnT, n_T)
 
-	PT := new(bn256.G1).ScalarMult(public.G, psT)
+	PT := public.precomputeG().mul(psT)
 	PT.Add(PT, vectorPointScalarMul(public.GVec, pnT))
 
 	cr_T := []*big.Int{
@@ -486,7 +1262,7 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 
 	vT := add(psT, mul(v_, t3))
 
-	CT := new(bn256.G1).ScalarMult(public.G, vT)
+	CT := public.precomputeG().mul(vT)
 	CT.Add(CT, vectorPointScalarMul(public.HVec, lT))
 	CT.Add(CT, vectorPointScalarMul(public.GVec, nT))
 
@@ -497,27 +1273,48 @@ func innerArithmeticCircuitProve(public *ArithmeticCircuitPublic, fs FiatShamirE
 		cT = append(cT, bint(0))
 	}
 
-	for len(nT) < len(public.GVec_)+len(public.GVec_) {
+	for len(nT) < len(public.GVec)+len(public.GVec_) {
 		nT = append(nT, bint(0))
 	}
 
-	proof.WNLA = ProveWNLA(
-		&WeightNormLinearPublic{
-			G:    public.G,
-			GVec: append(public.GVec, public.GVec_...),
-			HVec: append(public.HVec, public.HVec_...),
-			C:    cT,
-			Ro:   rho,
-			Mu:   mu,
-		},
-		CT,
-		fs,
-		lT,
-		nT,
-	)
+	done()
+
+	done = profilePhase(public.Profiler, "wnla")
+	wnlaPublic := &WeightNormLinearPublic{
+		G:    public.G,
+		GVec: concatPoints(public.GVec, public.GVec_),
+		HVec: concatPoints(public.HVec, public.HVec_),
+		C:    cT,
+		Ro:   rho,
+		Mu:   mu,
+	}
+
+	wnlaPublic.gTable.inherit(&public.gTable)
+	proof.WNLA = ProveWNLA(wnlaPublic, CT, fs, lT, nT)
+	done()
+
 	return proof
 }
 
+// circuitMatrices contains the M matrices of the circuit that do not depend on the challenges.
+type circuitMatrices struct {
+	MlnL, MmnL, MlnR, MmnR                         [][]*big.Int
+	MlnO, MmnO, MllL, MmlL, MllR, MmlR, MllO, MmlO [][]*big.Int
+}
+
+// list returns the pointers to the matrices in the fixed order: the pairs of Nl and Nm rows matrices for nL, nR, nO
+// with Nm columns followed by the pairs for lL, lR, lO with Nv columns.
+func (m *circuitMatrices) list() []*[][]*big.Int {
+	return []*[][]*big.Int{&m.MlnL, &m.MmnL, &m.MlnR, &m.MmnR, &m.MlnO, &m.MmnO, &m.MllL, &m.MmlL, &m.MllR, &m.MmlR, &m.MllO, &m.MmlO}
+}
+
+func newCircuitMatrices(public *ArithmeticCircuitPublic) *circuitMatrices {
+	m := &circuitMatrices{}
+	m.MlnL, m.MmnL, m.MlnR, m.MmnR = calculateMRL(public)
+	m.MlnO, m.MmnO, m.MllL, m.MmlL, m.MllR, m.MmlR, m.MllO, m.MmlO = calculateMO(public)
+	return m
+}
+
 func calculateMRL(public *ArithmeticCircuitPublic) (MlnL, MmnL, MlnR, MmnR [][]*big.Int) {
 	for i := 0; i < public.Nl; i++ { // Nl * Nm
 		MlnL = append(MlnL, public.Wl[i][:public.Nm])
@@ -539,118 +1336,41 @@ func calculateMRL(public *ArithmeticCircuitPublic) (MlnL, MmnL, MlnR, MmnR [][]*
 }
 
 func calculateMO(public *ArithmeticCircuitPublic) (MlnO, MmnO, MllL, MmlL, MllR, MmlR, MllO, MmlO [][]*big.Int) {
-	var WlO [][]*big.Int // Nl*No
-	for i := 0; i < public.Nl; i++ {
-		WlO = append(WlO, public.Wl[i][public.Nm*2:])
-	}
-
-	var WmO [][]*big.Int // Nm*No
-	for i := 0; i < public.Nm; i++ {
-		WmO = append(WmO, public.Wm[i][public.Nm*2:])
-	}
-
 	//ManO, a = {l,m}
-
-	for i := 0; i < public.Nl; i++ { // Nl*Nm
-		MlnO = append(MlnO, make([]*big.Int, public.Nm))
-
-		for j := 0; j < public.Nm; j++ {
-			MlnO[i][j] = big.NewInt(0)
-
-			if j_ := public.F(PartitionNO, j); j_ != nil {
-				MlnO[i][j].Set(WlO[i][*j_])
-			}
-		}
-	}
-
-	for i := 0; i < public.Nm; i++ { // Nm*Nm
-		MmnO = append(MmnO, make([]*big.Int, public.Nm))
-
-		for j := 0; j < public.Nm; j++ {
-			MmnO[i][j] = big.NewInt(0)
-
-			if j_ := public.F(PartitionNO, j); j_ != nil {
-				MmnO[i][j].Set(WmO[i][*j_])
-			}
-		}
-	}
+	MlnO = public.partitionMatrix(public.Wl, PartitionNO, public.Nm) // Nl*Nm
+	MmnO = public.partitionMatrix(public.Wm, PartitionNO, public.Nm) // Nm*Nm
 
 	// MalX, a = {l,m}, X = {L,R,O}
+	MllL = public.partitionMatrix(public.Wl, PartitionLL, public.Nv) // Nl*Nv
+	MmlL = public.partitionMatrix(public.Wm, PartitionLL, public.Nv) // Nm*Nv
+	MllR = public.partitionMatrix(public.Wl, PartitionLR, public.Nv) // Nl*Nv
+	MmlR = public.partitionMatrix(public.Wm, PartitionLR, public.Nv) // Nm*Nv
+	MllO = public.partitionMatrix(public.Wl, PartitionLO, public.Nv) // Nl*Nv
+	MmlO = public.partitionMatrix(public.Wm, PartitionLO, public.Nv) // Nm*Nv
+	return
+}
 
-	// L
-	for i := 0; i < public.Nl; i++ { // Nl*Nv
-		MllL = append(MllL, make([]*big.Int, public.Nv))
-
-		for j := 0; j < public.Nv; j++ {
-			MllL[i][j] = big.NewInt(0)
-
-			if j_ := public.F(PartitionLL, j); j_ != nil {
-				MllL[i][j].Set(WlO[i][*j_])
-			}
-		}
-	}
-
-	for i := 0; i < public.Nm; i++ { // Nm*Nv
-		MmlL = append(MmlL, make([]*big.Int, public.Nv))
-
-		for j := 0; j < public.Nv; j++ {
-			MmlL[i][j] = big.NewInt(0)
-
-			if j_ := public.F(PartitionLL, j); j_ != nil {
-				MmlL[i][j].Set(WmO[i][*j_])
-			}
-		}
-	}
-
-	// R
-	for i := 0; i < public.Nl; i++ { // Nl*Nv
-		MllR = append(MllR, make([]*big.Int, public.Nv))
-
-		for j := 0; j < public.Nv; j++ {
-			MllR[i][j] = big.NewInt(0)
-
-			if j_ := public.F(PartitionLR, j); j_ != nil {
-				MllR[i][j].Set(WlO[i][*j_])
-			}
-		}
-	}
-
-	for i := 0; i < public.Nm; i++ { // Nm*Nv
-		MmlR = append(MmlR, make([]*big.Int, public.Nv))
-
-		for j := 0; j < public.Nv; j++ {
-			MmlR[i][j] = big.NewInt(0)
+// partitionMatrix returns the matrix of the W columns corresponding to wo that are mapped by the partition F of the
+// given type: res[i][j] = W[i][2*Nm+F(typ, j)] or zero if F(typ, j) is not defined, j in [0, cols).
+func (p *ArithmeticCircuitPublic) partitionMatrix(W [][]*big.Int, typ PartitionType, cols int) [][]*big.Int {
+	res := make([][]*big.Int, len(W))
 
-			if j_ := public.F(PartitionLR, j); j_ != nil {
-				MmlR[i][j].Set(WmO[i][*j_])
-			}
-		}
-	}
-
-	// O
-	for i := 0; i < public.Nl; i++ { // Nl*Nv
-		MllO = append(MllO, make([]*big.Int, public.Nv))
+	for i := range W {
+		res[i] = make([]*big.Int, cols)
 
-		for j := 0; j < public.Nv; j++ {
-			MllO[i][j] = big.NewInt(0)
+		for j := 0; j < cols; j++ {
+			res[i][j] = big.NewInt(0)
 
-			if j_ := public.F(PartitionLO, j); j_ != nil {
-				MllO[i][j].Set(WlO[i][*j_])
+			if j_ := p.F(typ, j); j_ != nil {
+				res[i][j].Set(W[i][p.Nm*2+*j_])
 			}
 		}
 	}
 
-	for i := 0; i < public.Nm; i++ { // Nm*Nv
-		MmlO = append(MmlO, make([]*big.Int, public.Nv))
-
-		for j := 0; j < public.Nv; j++ {
-			MmlO[i][j] = big.NewInt(0)
-
-			if j_ := public.F(PartitionLO, j); j_ != nil {
-				MmlO[i][j].Set(WmO[i][*j_])
-			}
-		}
-	}
+	return res
+}
 
-	return
+// coefficients returns lambdaVec*Ml - muVec*Mm.
+func coefficients(lambdaVec, muVec []*big.Int, Ml, Mm [][]*big.Int) []*big.Int {
+	return vectorSub(vectorMulOnMatrix(lambdaVec, Ml), vectorMulOnMatrix(muVec, Mm))
 }
@@ -5,11 +5,17 @@
 package bulletproofs
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/cloudflare/bn256"
 	"github.com/davecgh/go-spew/spew"
 	"math/big"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestArithmeticCircuit(t *testing.T) {
@@ -94,10 +100,16 @@ func TestArithmeticCircuit(t *testing.T) {
 
 	V := make([]*bn256.G1, public.K)
 	for i := range V {
-		V[i] = public.CommitCircuit(private.V[i], private.Sv[i])
+		var err error
+		if V[i], err = public.CommitCircuit(private.V[i], private.Sv[i]); err != nil {
+			panic(err)
+		}
 	}
 
-	proof := ProveCircuit(public, V, NewKeccakFS(), private)
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
 	spew.Dump(proof)
 
 	if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
@@ -106,6 +118,22 @@ func TestArithmeticCircuit(t *testing.T) {
 }
 
 func TestArithmeticCircuitBinaryRangeProof(t *testing.T) {
+	public, private, V := newBinaryRangeCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+	spew.Dump(proof)
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
+
+// newBinaryRangeCircuit returns the 4-bit binary range proof circuit from TestArithmeticCircuitBinaryRangeProof with
+// commitments to the witness.
+func newBinaryRangeCircuit() (*ArithmeticCircuitPublic, *ArithmeticCircuitPrivate, []*bn256.G1) {
 	value := []*big.Int{bint(0), bint(1), bint(1), bint(0)} // bin(0110) = dec(6)
 	// We have prove that value < 2^n - 1
 	// Proving of the bits count is automatic (public parameters dimension will not allow to verify prove for bigger value)
@@ -222,17 +250,1875 @@ func TestArithmeticCircuitBinaryRangeProof(t *testing.T) {
 
 	V := make([]*bn256.G1, public.K)
 	for i := range V {
-		V[i] = public.CommitCircuit(private.V[i], private.Sv[i])
+		var err error
+		if V[i], err = public.CommitCircuit(private.V[i], private.Sv[i]); err != nil {
+			panic(err)
+		}
 	}
 
-	proof := ProveCircuit(public, V, NewKeccakFS(), private)
-	spew.Dump(proof)
+	return public, private, V
+}
 
-	if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+func frac(a, b int) *big.Int {
+	return mul(bint(a), inv(bint(b)))
+}
+
+func TestMatrixVectorMul(t *testing.T) {
+	// x + y = r, x * y = z circuit from TestArithmeticCircuit
+	x, y, z, r := bint(3), bint(5), bint(15), bint(8)
+	w := []*big.Int{x, y, z, r}
+
+	Wm := [][]*big.Int{{bint(0), bint(0), bint(1), bint(0)}}
+	Wl := [][]*big.Int{
+		{bint(0), bint(1), bint(0), bint(0)},
+		{bint(0), bint(-1), bint(1), bint(0)},
+	}
+
+	if res := MatrixVectorMul(Wm, w); len(res) != 1 || res[0].Cmp(mul(x, y)) != 0 {
+		panic("test failed")
+	}
+
+	// <Wl, w> + wv + al = 0
+	lin := vectorAdd(vectorAdd(MatrixVectorMul(Wl, w), []*big.Int{x, y}), []*big.Int{minus(r), minus(z)})
+	for i := range lin {
+		if lin[i].Sign() != 0 {
+			panic("test failed")
+		}
+	}
+
+	// (1, 2) * Wl = (0, 1-2, 2, 0)
+	res := VectorMatrixMul([]*big.Int{bint(1), bint(2)}, Wl)
+	expected := []*big.Int{bint(0), bint(-1), bint(2), bint(0)}
+	if len(res) != len(expected) {
+		panic("test failed")
+	}
+
+	for i := range res {
+		if res[i].Cmp(expected[i]) != 0 {
+			panic("test failed")
+		}
+	}
+}
+
+func TestArithmeticCircuitShortHVec(t *testing.T) {
+	Nm := 1
+	Nv := 2
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public := &ArithmeticCircuitPublic{
+		Nm: Nm,
+		Nl: Nv,
+		Nv: Nv,
+		Nw: Nm + Nm + 2,
+		No: 2,
+		K:  1,
+
+		G:    wnla.G,
+		GVec: wnla.GVec[:Nm],
+		HVec: wnla.HVec[:9+Nv-1], // one element too short
+
+		F: func(typ PartitionType, index int) *int {
+			return nil
+		},
+	}
+
+	if _, err := public.CommitCircuit([]*big.Int{bint(1), bint(2)}, MustRandScalar()); err == nil {
+		panic("commit should fail for short HVec")
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{bint(1), bint(2)}},
+		Sv: []*big.Int{MustRandScalar()},
+	}
+
+	if _, err := ProveCircuit(public, []*bn256.G1{wnla.G}, NewKeccakFS(), private); err == nil {
+		panic("prove should fail for short HVec")
+	}
+}
+
+// newTestCircuit returns the x + y = r, x * y = z circuit from TestArithmeticCircuit with commitments to the witness.
+func newTestCircuit() (*ArithmeticCircuitPublic, *ArithmeticCircuitPrivate, []*bn256.G1) {
+	x, y, r, z := bint(3), bint(5), bint(8), bint(15)
+
+	Nm, No, Nv, K := 1, 2, 2, 1
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public := &ArithmeticCircuitPublic{
+		Nm: Nm,
+		Nl: Nv * K,
+		Nv: Nv,
+		Nw: Nm + Nm + No,
+		No: No,
+		K:  K,
+
+		G:    wnla.G,
+		GVec: wnla.GVec[:Nm],
+		HVec: wnla.HVec[:9+Nv],
+
+		Wm: [][]*big.Int{{bint(0), bint(0), bint(1), bint(0)}},
+		Wl: [][]*big.Int{
+			{bint(0), bint(1), bint(0), bint(0)},
+			{bint(0), bint(-1), bint(1), bint(0)},
+		},
+		Am: []*big.Int{bint(0)},
+		Al: []*big.Int{minus(r), minus(z)},
+		Fl: true,
+		Fm: false,
+
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL { // map all to ll
+				return &index
+			}
+
+			return nil
+		},
+
+		GVec_: wnla.GVec[Nm:],
+		HVec_: wnla.HVec[9+Nv:],
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{x, y}},
+		Sv: []*big.Int{MustRandScalar()},
+		Wl: []*big.Int{x},
+		Wr: []*big.Int{y},
+		Wo: []*big.Int{z, r},
+	}
+
+	V := make([]*bn256.G1, public.K)
+	for i := range V {
+		var err error
+		if V[i], err = public.CommitCircuit(private.V[i], private.Sv[i]); err != nil {
+			panic(err)
+		}
+	}
+
+	return public, private, V
+}
+
+func TestComputeCT(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	// Repeat the verifier's transcript
+	fs := NewKeccakFS()
+	fs.AddPoint(proof.CL)
+	fs.AddPoint(proof.CR)
+	fs.AddPoint(proof.CO)
+	fs.AddPoint(V[0])
+	fs.AddNumber(transcriptPhaseCommitments)
+
+	ch := &CircuitChallenges{Ro: fs.GetChallenge(), Lambda: fs.GetChallenge(), Beta: fs.GetChallenge(), Delta: fs.GetChallenge()}
+	fs.AddPoint(proof.CS)
+	fs.AddNumber(transcriptPhaseBlinding)
+	ch.T = fs.GetChallenge()
+
+	CT, cT := computeCT(public, newCircuitMatrices(public), proof, V, ch)
+
+	publicCT, err := ComputeCT(public, proof, V, ch)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(publicCT.Marshal(), CT.Marshal()) {
+		panic("test failed")
+	}
+
+	if _, err = ComputeCT(public, proof, append(V, V...), ch); err == nil {
+		panic("wrong commitments count should be rejected")
+	}
+
+	wnla := &WeightNormLinearPublic{
+		G:    public.G,
+		GVec: concatPoints(public.GVec, public.GVec_),
+		HVec: concatPoints(public.HVec, public.HVec_),
+		C:    cT,
+		Ro:   ch.Ro,
+		Mu:   mul(ch.Ro, ch.Ro),
+	}
+
+	if err := VerifyWNLA(wnla, proof.WNLA, CT, fs); err != nil {
 		panic(err)
 	}
 }
 
-func frac(a, b int) *big.Int {
-	return mul(bint(a), inv(bint(b)))
+func TestArithmeticCircuitSummary(t *testing.T) {
+	// Dimensions of the circuit from TestArithmeticCircuitBinaryRangeProof
+	public := &ArithmeticCircuitPublic{Nm: 4, Nl: 8, Nv: 2, Nw: 12, No: 4, K: 4}
+
+	expected := CircuitSummary{
+		Nm: 4, Nl: 8, Nv: 2, Nw: 12, No: 4, K: 4,
+		GVecLen:       4,
+		HVecLen:       11,
+		PaddedGVecLen: 4,
+		PaddedHVecLen: 16,
+	}
+
+	if public.Summary() != expected {
+		panic("test failed")
+	}
+}
+
+func TestVerifyCircuitMalformedProof(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	// Without matrices the verifier panics if it starts the circuit computations,
+	// so the malformed proofs below must be rejected before that.
+	public.Wm, public.Wl, public.Am, public.Al = nil, nil, nil, nil
+
+	emptyWNLA := *proof
+	emptyWNLA.WNLA = &WeightNormLinearArgumentProof{}
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), &emptyWNLA); err == nil {
+		panic("proof with empty WNLA should fail")
+	}
+
+	lessRounds := *proof
+	lessRounds.WNLA = &WeightNormLinearArgumentProof{
+		R: proof.WNLA.R[1:],
+		X: proof.WNLA.X[1:],
+		L: proof.WNLA.L,
+		N: proof.WNLA.N,
+	}
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), &lessRounds); err == nil {
+		panic("proof with missing WNLA round should fail")
+	}
+
+	missingPoint := *proof
+	missingPoint.CS = nil
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), &missingPoint); err == nil {
+		panic("proof with missing commitment should fail")
+	}
+}
+
+func TestDeriveCircuitChallenges(t *testing.T) {
+	seed := []byte("bulletproofs challenges test")
+
+	points := DeterministicPoints(seed, "proof", 4)
+	V := DeterministicPoints(seed, "V", 2)
+
+	proof := &ArithmeticCircuitProof{CL: points[0], CR: points[1], CO: points[2], CS: points[3]}
+
+	expected := []string{
+		"407efd51716e8b3dcdff29010b1a975731b0d3d9e76e5e6681bdd4b23f0b9600",
+		"4544a3d0a0435651940bdd96d4cc45e76768fc81b02935e7abda880b2e100cc2",
+		"11c35e2d7fad2735acfa789700e11739a42e8c368089954e919dc073c7f958d0",
+		"31f1f9b7937fe92ff19bd84753e371a1b4298da49c0ea9f7cafabfa6aa3d2eda",
+		"482b6ceb627eef432636dd283dc40622b246152697ed05b485ce9f79d661906d",
+	}
+
+	ch := DeriveCircuitChallenges(NewKeccakFS(), V, proof)
+
+	for i, c := range []*big.Int{ch.Ro, ch.Lambda, ch.Beta, ch.Delta, ch.T} {
+		if c.Text(16) != expected[i] {
+			panic(fmt.Sprintf("challenge %d: got %x, expected %s", i, c, expected[i]))
+		}
+	}
+}
+
+func TestVerifyCircuitAudit(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	fs := &recordingFS{FiatShamirEngine: NewKeccakFS()}
+
+	challenges, err := VerifyCircuitAudit(public, V, fs, proof)
+	if err != nil {
+		panic(err)
+	}
+
+	// The auditor replays the recorded transcript and recomputes every challenge
+	replay := NewKeccakFS()
+
+	var recomputed []*big.Int
+	for _, op := range fs.ops {
+		kind, data, _ := strings.Cut(op, " ")
+
+		switch kind {
+		case "point":
+			b, _ := hex.DecodeString(data)
+			p := new(bn256.G1)
+			if _, err = p.Unmarshal(b); err != nil {
+				panic(err)
+			}
+			replay.AddPoint(p)
+		case "number":
+			v, _ := new(big.Int).SetString(data, 16)
+			replay.AddNumber(v)
+		case "challenge":
+			c := replay.GetChallenge()
+			if c.Text(16) != data {
+				panic(fmt.Sprintf("challenge %d does not match the transcript", len(recomputed)))
+			}
+			recomputed = append(recomputed, c)
+		}
+	}
+
+	if len(recomputed) < 5 {
+		panic("transcript should contain the circuit challenges")
+	}
+
+	for i, c := range []*big.Int{challenges.Ro, challenges.Lambda, challenges.Beta, challenges.Delta, challenges.T} {
+		if c.Cmp(recomputed[i]) != 0 {
+			panic(fmt.Sprintf("returned challenge %d does not match the recomputed one", i))
+		}
+	}
+
+	if ro := public.FirstChallenge(V, NewKeccakFS(), proof); ro == nil || ro.Cmp(challenges.Ro) != 0 {
+		panic("first challenge should be equal to the ro derived by the verification")
+	}
+
+	if public.FirstChallenge(V[:0], NewKeccakFS(), proof) != nil {
+		panic("wrong commitments count should be rejected")
+	}
+
+	// The challenge of the Merlin proof is derived with the Merlin transcript
+	merlinProof, err := ProveCircuit(public, V, NewMerlinFS("circuit"), private)
+	if err != nil {
+		panic(err)
+	}
+
+	merlin, err := VerifyCircuitAudit(public, V, NewMerlinFS("circuit"), merlinProof)
+	if err != nil {
+		panic(err)
+	}
+
+	if ro := public.FirstChallenge(V, NewMerlinFS("circuit"), merlinProof); ro == nil || ro.Cmp(merlin.Ro) != 0 {
+		panic("first challenge should be equal to the ro derived by the Merlin verification")
+	}
+
+	if public.FirstChallenge(V, NewKeccakFS(), merlinProof) != nil {
+		panic("proof of the other engine should be rejected")
+	}
+
+	// The challenges are returned for the rejected proof too
+	proof.WNLA.N[0] = add(proof.WNLA.N[0], bint(1))
+
+	rejected, err := VerifyCircuitAudit(public, V, NewKeccakFS(), proof)
+	if err == nil {
+		panic("modified proof should be rejected")
+	}
+
+	if rejected.T == nil || rejected.T.Cmp(challenges.T) != 0 {
+		panic("challenges should be derived before the WNLA verification")
+	}
+}
+
+func TestValidatePartition(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	if err := ValidatePartition(public); err != nil {
+		panic(err)
+	}
+
+	public.F = func(typ PartitionType, index int) *int {
+		if typ == PartitionLL {
+			res := index + public.Nw
+			return &res
+		}
+
+		return nil
+	}
+
+	if err := ValidatePartition(public); err == nil {
+		panic("partition with out of range index should fail")
+	}
+
+	if _, err := ProveCircuit(public, V, NewKeccakFS(), private); err == nil {
+		panic("prove with invalid partition should fail")
+	}
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), &ArithmeticCircuitProof{}); err == nil {
+		panic("verify with invalid partition should fail")
+	}
+}
+
+func TestCommitCircuitBatch(t *testing.T) {
+	K, Nv := 16, 4
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+	public := &ArithmeticCircuitPublic{Nv: Nv, K: K, G: wnla.G, HVec: wnla.HVec[:9+Nv]}
+
+	vs := make([][]*big.Int, K)
+	ss := make([]*big.Int, K)
+	for k := range vs {
+		vs[k] = []*big.Int{MustRandScalar(), MustRandScalar(), MustRandScalar(), MustRandScalar()}
+		ss[k] = MustRandScalar()
+	}
+
+	batch, err := public.CommitCircuitBatch(vs, ss)
+	if err != nil {
+		panic(err)
+	}
+
+	for k := range vs {
+		V, err := public.CommitCircuit(vs[k], ss[k])
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(V.Marshal(), batch[k].Marshal()) {
+			panic("test failed")
+		}
+	}
+
+	if _, err := public.CommitCircuitBatch(vs, ss[1:]); err == nil {
+		panic("batch with different vs and ss lengths should fail")
+	}
+}
+
+func BenchmarkCommitCircuitBatch(b *testing.B) {
+	K, Nv := 64, 16
+
+	wnla := NewWeightNormLinearPublic(32, 1)
+	public := &ArithmeticCircuitPublic{Nv: Nv, K: K, G: wnla.G, HVec: wnla.HVec[:9+Nv]}
+
+	vs := make([][]*big.Int, K)
+	ss := make([]*big.Int, K)
+	for k := range vs {
+		vs[k] = make([]*big.Int, Nv)
+		for i := range vs[k] {
+			vs[k][i] = MustRandScalar()
+		}
+
+		ss[k] = MustRandScalar()
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for k := range vs {
+				if _, err := public.CommitCircuit(vs[k], ss[k]); err != nil {
+					panic(err)
+				}
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := public.CommitCircuitBatch(vs, ss); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+func TestVectorHelpersDoNotMutateInputs(t *testing.T) {
+	// Sub-slices with the spare capacity: padding with append would overwrite the hidden elements
+	backing := []*big.Int{bint(1), bint(2), bint(3), bint(4)}
+	a := backing[:1]
+	b := backing[2:4]
+
+	vectorAdd(a, b)
+	vectorSub(a, b)
+	vectorMul(a, b)
+	weightVectorMul(a, b, bint(2))
+
+	for i, v := range []*big.Int{bint(1), bint(2), bint(3), bint(4)} {
+		if backing[i].Cmp(v) != 0 {
+			panic("vector helper modified the input")
+		}
+	}
+
+	points := []*bn256.G1{MustRandPoint(), MustRandPoint(), MustRandPoint()}
+	expected := points[1].Marshal()
+
+	vectorPointsAdd(points[:1], points[1:])
+	vectorPointScalarMul(points[:2], backing[:1])
+
+	if !bytes.Equal(points[1].Marshal(), expected) {
+		panic("vector helper modified the input")
+	}
+}
+
+// snapshotCircuit returns the printable values of all the circuit inputs, including the generators that are
+// hidden behind the sub-slices length.
+func snapshotCircuit(public *ArithmeticCircuitPublic, private *ArithmeticCircuitPrivate, V []*bn256.G1) string {
+	res := fmt.Sprint(public.Wm, public.Wl, public.Am, public.Al, private.V, private.Sv, private.Wl, private.Wr, private.Wo)
+
+	for _, vec := range [][]*bn256.G1{public.GVec[:cap(public.GVec)], public.HVec[:cap(public.HVec)], public.GVec_, public.HVec_, V} {
+		for _, p := range vec {
+			res += fmt.Sprintf("%x", p.Marshal())
+		}
+	}
+
+	return res
+}
+
+func TestProveCircuitDoesNotMutateInputs(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	before := snapshotCircuit(public, private, V)
+
+	proof1, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if snapshotCircuit(public, private, V) != before {
+		panic("ProveCircuit modified the inputs")
+	}
+
+	proof2, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	// Proofs are randomized, but both of them should be valid for the same inputs
+	for _, proof := range []*ArithmeticCircuitProof{proof1, proof2} {
+		if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+			panic(err)
+		}
+	}
+
+	if snapshotCircuit(public, private, V) != before {
+		panic("ProveCircuit or VerifyCircuit modified the inputs")
+	}
+}
+
+func TestPadCircuit(t *testing.T) {
+	// 3-bit value binary range circuit from TestArithmeticCircuitBinaryRangeProof: value[i] * value[i] = a[i], a[i] - value[i] = 0
+	value := []*big.Int{bint(1), bint(0), bint(1)}
+
+	Nm, No, Nv, K := 3, 3, 2, 3
+	Nw := Nm + Nm + No
+
+	a := hadamardMul(value, value)
+
+	Wm := zeroMatrix(Nm, Nw)
+	Wl := zeroMatrix(Nv*K, Nw)
+	v := make([][]*big.Int, K)
+
+	for i := 0; i < Nm; i++ {
+		Wm[i][2*Nm+i] = bint(1)
+		Wl[2*i][i] = bint(-1)
+		Wl[2*i+1][i] = bint(-1)
+		v[i] = []*big.Int{value[i], a[i]}
+	}
+
+	wnla := NewWeightNormLinearPublic(16, 4)
+
+	public := &ArithmeticCircuitPublic{
+		Nm: Nm,
+		Nl: Nv * K,
+		Nv: Nv,
+		Nw: Nw,
+		No: No,
+		K:  K,
+
+		G:    wnla.G,
+		GVec: wnla.GVec[:Nm],
+		HVec: wnla.HVec[:9+Nv],
+
+		Wm: Wm,
+		Wl: Wl,
+		Am: zeroVector(Nm),
+		Al: zeroVector(Nv * K),
+		Fl: true,
+		Fm: false,
+
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionNO { // map all to no
+				return &index
+			}
+
+			return nil
+		},
+
+		GVec_: wnla.GVec[Nm:],
+		HVec_: wnla.HVec[9+Nv:],
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  v,
+		Sv: []*big.Int{MustRandScalar(), MustRandScalar(), MustRandScalar()},
+		Wl: value,
+		Wr: value,
+		Wo: a,
+	}
+
+	padded, paddedPrivate := PadCircuit(public, private)
+
+	if padded.Nm != 4 || padded.Nw != 11 || len(padded.GVec) != 4 || len(padded.GVec_) != 0 || len(paddedPrivate.Wl) != 4 {
+		panic("test failed")
+	}
+
+	if public.Nm != Nm || len(public.Wm[0]) != Nw {
+		panic("PadCircuit modified the original circuit")
+	}
+
+	V := make([]*bn256.G1, K)
+	for i := range V {
+		var err error
+		if V[i], err = padded.CommitCircuit(paddedPrivate.V[i], paddedPrivate.Sv[i]); err != nil {
+			panic(err)
+		}
+	}
+
+	proof, err := ProveCircuit(padded, V, NewKeccakFS(), paddedPrivate)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyCircuit(padded, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
+
+func TestBindPublicInput(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	fs := NewKeccakFS()
+	BindPublicInput(fs, "amount", bint(100))
+
+	proof, err := ProveCircuit(public, V, fs, private)
+	if err != nil {
+		panic(err)
+	}
+
+	bound := func(label string, value *big.Int) FiatShamirEngine {
+		fs := NewKeccakFS()
+		BindPublicInput(fs, label, value)
+		return fs
+	}
+
+	if err := VerifyCircuit(public, V, bound("amount", bint(100)), proof); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyCircuit(public, V, bound("amount", bint(101)), proof); err == nil {
+		panic("proof should not be valid for the different input")
+	}
+
+	if err := VerifyCircuit(public, V, bound("fee", bint(100)), proof); err == nil {
+		panic("proof should not be valid for the different input label")
+	}
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid without the bound input")
+	}
+}
+
+func TestArithmeticCircuitEmptyK(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := ProveCircuit(public, nil, NewKeccakFS(), private); err == nil {
+		panic("prove without commitments should fail")
+	}
+
+	if err := VerifyCircuit(public, nil, NewKeccakFS(), proof); err == nil {
+		panic("verify without commitments should fail")
+	}
+
+	public.K, public.Nl = 0, 0
+	public.Wl, public.Al = nil, nil
+	private.V, private.Sv = nil, nil
+
+	if _, err := ProveCircuit(public, nil, NewKeccakFS(), private); err == nil {
+		panic("prove for K = 0 should fail")
+	}
+
+	if err := VerifyCircuit(public, nil, NewKeccakFS(), proof); err == nil {
+		panic("verify for K = 0 should fail")
+	}
+}
+
+func TestVerifyCircuitGeneratorsAliasing(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	// GVec and HVec have spare capacity filled with the points that differ from GVec_ and HVec_
+	public.GVec = append(append(make([]*bn256.G1, 0, 2*len(public.GVec)+len(public.GVec_)), public.GVec...), MustRandPoint())[:len(public.GVec)]
+	public.HVec = append(append(make([]*bn256.G1, 0, 2*len(public.HVec)+len(public.HVec_)), public.HVec...), MustRandPoint())[:len(public.HVec)]
+
+	spareG := public.GVec[:cap(public.GVec)][len(public.GVec)]
+	spareH := public.HVec[:cap(public.HVec)][len(public.HVec)]
+
+	for i := 0; i < 2; i++ {
+		if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+			panic(err)
+		}
+
+		if public.GVec[:cap(public.GVec)][len(public.GVec)] != spareG || public.HVec[:cap(public.HVec)][len(public.HVec)] != spareH {
+			panic("verification modified the generators backing arrays")
+		}
+	}
+
+	if _, err := ProveCircuit(public, V, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if public.GVec[:cap(public.GVec)][len(public.GVec)] != spareG || public.HVec[:cap(public.HVec)][len(public.HVec)] != spareH {
+		panic("proving modified the generators backing arrays")
+	}
+}
+
+func TestLinearCombineCommitments(t *testing.T) {
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public := &ArithmeticCircuitPublic{K: 3, Nv: 2, G: wnla.G, HVec: wnla.HVec[:11]}
+	lambda, mu := MustRandScalar(), MustRandScalar()
+
+	vs := [][]*big.Int{
+		{MustRandScalar(), MustRandScalar()},
+		{MustRandScalar(), MustRandScalar()},
+		{MustRandScalar(), MustRandScalar()},
+	}
+	ss := []*big.Int{MustRandScalar(), MustRandScalar(), MustRandScalar()}
+
+	V, err := public.CommitCircuitBatch(vs, ss)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, f := range [][2]bool{{true, false}, {false, true}, {true, true}} {
+		public.Fl, public.Fm = f[0], f[1]
+
+		// The prover combines the committed vectors and blindings with the same weights
+		v, s := zeroVector(public.Nv), bint(0)
+		for i := 0; i < public.K; i++ {
+			w := add(mul(bbool(public.Fl), pow(lambda, public.Nv*i)), mul(bbool(public.Fm), pow(mu, public.Nv*i+1)))
+			v = vectorAdd(v, vectorMulOnScalar(vs[i], mul(w, bint(2))))
+			s = add(s, mul(ss[i], mul(w, bint(2))))
+		}
+
+		expected, err := public.CommitCircuit(v, s)
+		if err != nil {
+			panic(err)
+		}
+
+		res, err := LinearCombineCommitments(public, V, lambda, mu)
+		if err != nil {
+			panic(err)
+		}
+
+		if !pointsEqual(res, expected) {
+			panic("test failed")
+		}
+	}
+
+	for _, invalid := range [][]*bn256.G1{nil, V[:2], append(V, V[0])} {
+		if _, err := LinearCombineCommitments(public, invalid, lambda, mu); err == nil {
+			panic("wrong commitments count should be rejected")
+		}
+	}
+}
+
+func TestVerifyCircuitWithScratch(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	scratch, err := NewVerifyScratch(public)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := VerifyCircuitWithScratch(scratch, V, NewKeccakFS(), proof); err != nil {
+			panic(err)
+		}
+
+		proof.CL = new(bn256.G1).Add(proof.CL, public.G)
+
+		if VerifyCircuitWithScratch(scratch, V, NewKeccakFS(), proof) == nil || VerifyCircuit(public, V, NewKeccakFS(), proof) == nil {
+			panic("verification of the corrupted proof should fail")
+		}
+	}
+
+	public.HVec = public.HVec[:9]
+	if _, err := NewVerifyScratch(public); err == nil {
+		panic("scratch for the invalid circuit should not be created")
+	}
+}
+
+func BenchmarkVerifyCircuit(b *testing.B) {
+	// Bits decomposition circuit of the 32-bit value
+	Nb := 32
+	wnla := NewWeightNormLinearPublic(64, 32)
+
+	bits := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnla.G,
+			GVec:  wnla.GVec[:Nb],
+			HVec:  wnla.HVec[:Nb+1+9],
+			GVec_: wnla.GVec[Nb:],
+			HVec_: wnla.HVec[Nb+1+9:],
+		},
+		Nb: Nb,
+	}
+
+	x := bint(0xab4f0540)
+	s := MustRandScalar()
+
+	digits := make([]*big.Int, Nb)
+	for i := range digits {
+		digits[i] = bint(int(x.Bit(i)))
+	}
+
+	proof, err := ProveBits(bits, NewKeccakFS(), x, digits, s)
+	if err != nil {
+		panic(err)
+	}
+
+	public := bits.circuit()
+	V := []*bn256.G1{bits.CommitValue(x, s), ZeroCommitment()}
+
+	b.Run("VerifyCircuit", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for n := 0; n < b.N; n++ {
+			if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	b.Run("VerifyCircuitWithScratch", func(b *testing.B) {
+		scratch, err := NewVerifyScratch(public)
+		if err != nil {
+			panic(err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for n := 0; n < b.N; n++ {
+			if err := VerifyCircuitWithScratch(scratch, V, NewKeccakFS(), proof); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+func TestDescribeConstraints(t *testing.T) {
+	public, _, _ := newTestCircuit()
+
+	expected := []string{
+		"wl[0] * wr[0] = wo[0]",
+		"wr[0] + v[0][0] - 8 = 0",
+		"-wr[0] + wo[0] + v[0][1] - 15 = 0",
+	}
+
+	description := public.DescribeConstraints()
+	if len(description) != len(expected) {
+		panic(fmt.Sprintf("unexpected description: %q", description))
+	}
+
+	for i := range expected {
+		if description[i] != expected[i] {
+			panic(fmt.Sprintf("constraint %d: got %q, expected %q", i, description[i], expected[i]))
+		}
+	}
+}
+
+func BenchmarkProveCircuitMemory(b *testing.B) {
+	// Bits decomposition circuit of the 128-bit value
+	Nb := 128
+	wnla := NewWeightNormLinearPublic(256, 128)
+
+	bits := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnla.G,
+			GVec:  wnla.GVec[:Nb],
+			HVec:  wnla.HVec[:Nb+1+9],
+			GVec_: wnla.GVec[Nb:],
+			HVec_: wnla.HVec[Nb+1+9:],
+		},
+		Nb: Nb,
+	}
+
+	x := new(big.Int).Lsh(bint(0xab4f0540), 90)
+
+	digits := make([]*big.Int, Nb)
+	for i := range digits {
+		digits[i] = bint(int(x.Bit(i)))
+	}
+
+	b.ReportAllocs()
+
+	var peak uint64
+	for n := 0; n < b.N; n++ {
+		peak = max(peak, peakHeapInuse(func() {
+			if _, err := ProveBits(bits, NewKeccakFS(), x, digits, MustRandScalar()); err != nil {
+				panic(err)
+			}
+		}))
+	}
+
+	b.ReportMetric(float64(peak), "peak-heap-B")
+}
+
+// peakHeapInuse runs f and returns the high-water mark of HeapInuse above its level before the call. The heap is
+// sampled with runtime.ReadMemStats every 50us by the background goroutine.
+func peakHeapInuse(f func()) uint64 {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base, peak := stats.HeapInuse, stats.HeapInuse
+
+	done, sampled := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(sampled)
+
+		ticker := time.NewTicker(50 * time.Microsecond)
+		defer ticker.Stop()
+
+		var s runtime.MemStats
+		for {
+			runtime.ReadMemStats(&s)
+			peak = max(peak, s.HeapInuse)
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	f()
+	close(done)
+	<-sampled
+
+	return peak - base
+}
+
+func TestDiagInvMul(t *testing.T) {
+	x := MustRandScalar()
+	v := []*big.Int{MustRandScalar(), bint(0), MustRandScalar(), bint(7)}
+
+	// Dense diag(x^-1, x^-2, ...) matrix
+	diag := zeroMatrix(len(v), len(v))
+	for i := range diag {
+		diag[i][i] = pow(x, -(i + 1))
+	}
+
+	expected := vectorMulOnMatrix(v, diag)
+	res := diagInvMul(v, x)
+
+	for i := range expected {
+		if expected[i].Cmp(res[i]) != 0 {
+			panic("test failed")
+		}
+	}
+}
+
+// countingFS counts the GetChallenge calls of the wrapped FiatShamirEngine
+type countingFS struct {
+	FiatShamirEngine
+	challenges int
+}
+
+func (c *countingFS) GetChallenge() *big.Int {
+	c.challenges++
+	return c.FiatShamirEngine.GetChallenge()
+}
+
+func TestChallengeCount(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proverFS := &countingFS{FiatShamirEngine: NewKeccakFS()}
+	proof, err := ProveCircuit(public, V, proverFS, private)
+	if err != nil {
+		panic(err)
+	}
+
+	verifierFS := &countingFS{FiatShamirEngine: NewKeccakFS()}
+	if err = VerifyCircuit(public, V, verifierFS, proof); err != nil {
+		panic(err)
+	}
+
+	expected := ChallengeCount(public)
+	if proverFS.challenges != expected || verifierFS.challenges != expected {
+		panic(fmt.Sprintf("expected %d challenges, got %d and %d", expected, proverFS.challenges, verifierFS.challenges))
+	}
+
+	if expected != 5+len(proof.WNLA.X) {
+		panic("challenge count should include one challenge per WNLA round")
+	}
+}
+
+func TestArithmeticCircuitNoMultiplications(t *testing.T) {
+	// Purely linear circuit for x + y = c with x and y committed separately:
+	// -wo + x = 0
+	// wo + y - c = 0
+	x, y, c := bint(3), bint(5), bint(8)
+
+	Nm, No, Nv, K := 0, 1, 1, 2
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public := &ArithmeticCircuitPublic{
+		Nm: Nm,
+		Nl: Nv * K,
+		Nv: Nv,
+		Nw: Nm + Nm + No,
+		No: No,
+		K:  K,
+
+		G:    wnla.G,
+		GVec: wnla.GVec[:Nm],
+		HVec: wnla.HVec[:9+Nv],
+
+		Wm: zeroMatrix(Nm, Nm+Nm+No),
+		Wl: [][]*big.Int{{bint(-1)}, {bint(1)}},
+		Am: zeroVector(Nm),
+		Al: []*big.Int{bint(0), minus(c)},
+		Fl: true,
+		Fm: false,
+
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+
+		GVec_: wnla.GVec[Nm:],
+		HVec_: wnla.HVec[9+Nv:],
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{x}, {y}},
+		Sv: []*big.Int{MustRandScalar(), MustRandScalar()},
+		Wl: []*big.Int{},
+		Wr: []*big.Int{},
+		Wo: []*big.Int{x},
+	}
+
+	V, err := public.CommitCircuitBatch(private.V, private.Sv)
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// y + 1 breaks the relation
+	V[1].Add(V[1], public.G)
+	if err = VerifyCircuit(public, V, NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid for the other value")
+	}
+}
+
+func TestNewArithmeticCircuitPublic(t *testing.T) {
+	c, private, V := newTestCircuit()
+
+	newCircuit := func(HVec []*bn256.G1) (*ArithmeticCircuitPublic, error) {
+		return NewArithmeticCircuitPublic(c.Nm, c.No, c.Nv, c.K, c.G, c.GVec, HVec, c.GVec_, c.HVec_, c.Wm, c.Wl, c.Am, c.Al, c.Fl, c.Fm, c.F)
+	}
+
+	public, err := newCircuit(c.HVec)
+	if err != nil {
+		panic(err)
+	}
+
+	if public.Nl != c.Nl || public.Nw != c.Nw {
+		panic("invalid dimensions")
+	}
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(c, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// HVec without the place for the last committed value
+	if _, err = newCircuit(c.HVec[:StructuralSlots+c.Nv-1]); err == nil {
+		panic("HVec shorter than StructuralSlots+Nv should be rejected")
+	}
+
+	if _, err = NewArithmeticCircuitPublic(c.Nm, c.No, c.Nv, c.K, c.G, c.GVec, c.HVec, c.GVec_, c.HVec_, c.Wm, c.Wl[:1], c.Am, c.Al, c.Fl, c.Fm, c.F); err == nil {
+		panic("Wl with missing rows should be rejected")
+	}
+}
+
+func TestVerifyCircuitGivenCT(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	// Off-chain part: challenges and CT
+	fs := NewKeccakFS()
+	ch := DeriveCircuitChallenges(fs, V, proof)
+	CT, err := ComputeCT(public, proof, V, ch)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuitGivenCT(public, CT, ch, proof, fs); err != nil {
+		panic(err)
+	}
+
+	// CT for the other value commitment
+	fs = NewKeccakFS()
+	ch = DeriveCircuitChallenges(fs, V, proof)
+	if CT, err = ComputeCT(public, proof, []*bn256.G1{new(bn256.G1).Add(V[0], public.G)}, ch); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuitGivenCT(public, CT, ch, proof, fs); err == nil {
+		panic("proof should not be valid for the wrong CT")
+	}
+
+	// Proof of the other transcript engine is rejected as VerifyCircuit does
+	fs = NewMerlinFS("circuit")
+	ch = DeriveCircuitChallenges(fs, V, proof)
+	if CT, err = ComputeCT(public, proof, V, ch); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuitGivenCT(public, CT, ch, proof, fs); !errors.Is(err, ErrTranscriptEngineMismatch) {
+		panic("proof of the other engine should be rejected")
+	}
+}
+
+func TestCircuitMetadata(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	meta := &Metadata{Nonce: []byte("nonce-1"), NotAfter: time.Now().Unix() + 3600}
+
+	proof, err := ProveCircuitWithMetadata(public, V, NewKeccakFS(), meta, private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), meta, proof); err != nil {
+		panic(err)
+	}
+
+	tampered := &Metadata{Nonce: []byte("nonce-2"), NotAfter: meta.NotAfter}
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), tampered, proof); err == nil {
+		panic("proof should not be valid for the tampered nonce")
+	}
+
+	extended := &Metadata{Nonce: meta.Nonce, NotAfter: meta.NotAfter + 1}
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), extended, proof); err == nil {
+		panic("proof should not be valid for the extended expiry")
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid with the stripped metadata")
+	}
+
+	expired := &Metadata{Nonce: []byte("nonce-3"), NotAfter: time.Now().Unix() - 1}
+
+	if proof, err = ProveCircuitWithMetadata(public, V, NewKeccakFS(), expired, private); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), expired, proof); err == nil {
+		panic("expired proof should be rejected")
+	}
+
+	// The injected clock: the proof is valid up to NotAfter inclusive
+	fixed := &Metadata{Nonce: []byte("nonce-4"), NotAfter: 1000, Now: func() int64 { return 1000 }}
+
+	if proof, err = ProveCircuitWithMetadata(public, V, NewKeccakFS(), fixed, private); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), fixed, proof); err != nil {
+		panic(err)
+	}
+
+	fixed.Now = func() int64 { return 1001 }
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), fixed, proof); err == nil {
+		panic("proof should be rejected after NotAfter")
+	}
+
+	if _, err = ProveCircuitWithMetadata(public, V, NewKeccakFS(), nil, private); err == nil {
+		panic("nil metadata should be rejected")
+	}
+
+	if err = VerifyCircuitWithMetadata(public, V, NewKeccakFS(), nil, proof); err == nil {
+		panic("nil metadata should be rejected")
+	}
+}
+
+func TestCompileCircuit(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	compiled, err := CompileCircuit(public)
+	if err != nil {
+		panic(err)
+	}
+
+	decoded := &CompiledCircuit{}
+	if err = decoded.Unmarshal(compiled.Marshal()); err != nil {
+		panic(err)
+	}
+
+	if compiled.Digest() != decoded.Digest() || compiled.Summary() != public.Summary() {
+		panic("compiled circuit should be round-tripped")
+	}
+
+	wrongV := []*bn256.G1{new(bn256.G1).Add(V[0], public.G)}
+
+	for _, c := range []*CompiledCircuit{compiled, decoded} {
+		if err = VerifyCircuitCompiled(c, V, NewKeccakFS(), proof); err != nil {
+			panic(err)
+		}
+
+		if (VerifyCircuitCompiled(c, wrongV, NewKeccakFS(), proof) == nil) != (VerifyCircuit(public, wrongV, NewKeccakFS(), proof) == nil) {
+			panic("compiled circuit should verify identically to the original one")
+		}
+	}
+
+	// Another circuit has another digest
+	other, err := CompileCircuit(&ArithmeticCircuitPublic{
+		Nm: public.Nm, Nl: public.Nl, Nv: public.Nv, Nw: public.Nw, No: public.No, K: public.K,
+		G: public.G, GVec: public.GVec, HVec: public.HVec, Wm: public.Wm, Wl: public.Wl, Am: public.Am,
+		Al: []*big.Int{public.Al[0], add(public.Al[1], bint(1))}, Fl: public.Fl, Fm: public.Fm, F: public.F,
+		GVec_: public.GVec_, HVec_: public.HVec_,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if other.Digest() == compiled.Digest() {
+		panic("different circuits should have different digests")
+	}
+
+	if err = decoded.Unmarshal(compiled.Marshal()[:100]); err == nil {
+		panic("truncated encoding should be rejected")
+	}
+}
+
+func TestArithmeticCircuitNoOutputs(t *testing.T) {
+	// Single multiplication without outputs for x*y = z with committed x, y and public z:
+	// wl * wr = z
+	// -wl + x = 0
+	// -wr + y = 0
+	x, y, z := bint(3), bint(5), bint(15)
+
+	Nm, No, Nv, K := 1, 0, 2, 1
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public, err := NewArithmeticCircuitPublic(
+		Nm, No, Nv, K,
+		wnla.G, wnla.GVec[:Nm], wnla.HVec[:StructuralSlots+Nv], wnla.GVec[Nm:], wnla.HVec[StructuralSlots+Nv:],
+		[][]*big.Int{{bint(0), bint(0)}},
+		[][]*big.Int{{bint(-1), bint(0)}, {bint(0), bint(-1)}},
+		[]*big.Int{z},
+		[]*big.Int{bint(0), bint(0)},
+		true, false,
+		func(PartitionType, int) *int { return nil },
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{x, y}},
+		Sv: []*big.Int{MustRandScalar()},
+		Wl: []*big.Int{x},
+		Wr: []*big.Int{y},
+		Wo: []*big.Int{},
+	}
+
+	V, err := public.CommitCircuitBatch(private.V, private.Sv)
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// 3*6 != 15
+	private.V[0][1], private.Wr[0] = bint(6), bint(6)
+	if V, err = public.CommitCircuitBatch(private.V, private.Sv); err != nil {
+		panic(err)
+	}
+
+	if proof, err = ProveCircuit(public, V, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), proof); err == nil {
+		panic("proof of the wrong product should fail")
+	}
+}
+
+// reorderingFS absorbs the points in the reverse order: they are buffered till the next number or challenge
+type reorderingFS struct {
+	FiatShamirEngine
+	points []*bn256.G1
+}
+
+func (r *reorderingFS) AddPoint(p *bn256.G1) {
+	r.points = append(r.points, p)
+}
+
+func (r *reorderingFS) AddNumber(v *big.Int) {
+	r.flush()
+	r.FiatShamirEngine.AddNumber(v)
+}
+
+func (r *reorderingFS) GetChallenge() *big.Int {
+	r.flush()
+	return r.FiatShamirEngine.GetChallenge()
+}
+
+func (r *reorderingFS) flush() {
+	for i := len(r.points) - 1; i >= 0; i-- {
+		r.FiatShamirEngine.AddPoint(r.points[i])
+	}
+
+	r.points = nil
+}
+
+func TestCircuitTranscriptPhases(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, &reorderingFS{FiatShamirEngine: NewKeccakFS()}, private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, V, &reorderingFS{FiatShamirEngine: NewKeccakFS()}, proof); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), proof); err == nil {
+		panic("proof with reordered commitments absorption should fail")
+	}
+
+	// The phase tags are absorbed between the commitments and the challenges
+	fs := &recordingFS{FiatShamirEngine: NewKeccakFS()}
+	DeriveCircuitChallenges(fs, V, proof)
+
+	expected := []string{"point", "point", "point", "point", "number", "challenge", "challenge", "challenge", "challenge", "point", "number", "challenge"}
+	if strings.Join(fs.kinds(), ",") != strings.Join(expected, ",") {
+		panic(fmt.Sprintf("unexpected transcript: %v", fs.kinds()))
+	}
+}
+
+// staticChallengeFS absorbs the data into the wrapped KeccakFS, but returns the fixed challenge without squeezing it,
+// so the transcript state is defined by the absorbed data and the phase tags only. The tags are skipped with dropTags.
+type staticChallengeFS struct {
+	*KeccakFS
+	dropTags bool
+}
+
+func (s *staticChallengeFS) AddNumber(v *big.Int) {
+	if s.dropTags && (v.Cmp(transcriptPhaseCommitments) == 0 || v.Cmp(transcriptPhaseBlinding) == 0) {
+		return
+	}
+
+	s.KeccakFS.AddNumber(v)
+}
+
+func (s *staticChallengeFS) GetChallenge() *big.Int {
+	return bint(1)
+}
+
+func TestCircuitTranscriptPhaseBoundary(t *testing.T) {
+	CL, CR, CO := MustRandPoint(), MustRandPoint(), MustRandPoint()
+	A, B, C := MustRandPoint(), MustRandPoint(), MustRandPoint()
+
+	transcript := func(dropTags bool, V []*bn256.G1, CS *bn256.G1, next ...*bn256.G1) [32]byte {
+		fs := &staticChallengeFS{KeccakFS: NewKeccakFS().(*KeccakFS), dropTags: dropTags}
+		DeriveCircuitChallenges(fs, V, &ArithmeticCircuitProof{CL: CL, CR: CR, CO: CO, CS: CS})
+
+		for _, p := range next {
+			fs.AddPoint(p)
+		}
+
+		return fs.Fingerprint()
+	}
+
+	// Both transcripts absorb CL, CR, CO, A, B, C: B is the value commitment in the first one and CS in the second
+	if transcript(true, []*bn256.G1{A, B}, C) != transcript(true, []*bn256.G1{A}, B, C) {
+		panic("absorbed data should be the same")
+	}
+
+	if transcript(false, []*bn256.G1{A, B}, C) == transcript(false, []*bn256.G1{A}, B, C) {
+		panic("transcripts with the different phase boundaries should differ")
+	}
+}
+
+// laurent is the Laurent polynomial in t: laurent[k] is the coefficient of t^k
+type laurent map[int]*big.Int
+
+// monomials returns the polynomial sum(coefficients[i] * t^(from+i))
+func monomials(from int, coefficients ...*big.Int) laurent {
+	res := laurent{}
+	for i, c := range coefficients {
+		res[from+i] = c
+	}
+	return res
+}
+
+// at returns the coefficient of t^k
+func (p laurent) at(k int) *big.Int {
+	if c, ok := p[k]; ok {
+		return c
+	}
+	return bint(0)
+}
+
+func (p laurent) add(q laurent) laurent {
+	res := laurent{}
+	for _, x := range []laurent{p, q} {
+		for k, c := range x {
+			res[k] = add(res.at(k), c)
+		}
+	}
+	return res
+}
+
+func (p laurent) mul(q laurent) laurent {
+	res := laurent{}
+	for i, a := range p {
+		for j, b := range q {
+			res[i+j] = add(res.at(i+j), mul(a, b))
+		}
+	}
+	return res
+}
+
+// newTestCircuitPolynomial commits the witness the way ProveCircuit does and returns the prover's circuitPolynomial
+// for the given challenges with the random blinding vectors ls, ns.
+func newTestCircuitPolynomial(public *ArithmeticCircuitPublic, private *ArithmeticCircuitPrivate, rho, lambda, beta, delta *big.Int) *circuitPolynomial {
+	blind := blindingSource(!private.NonHiding, private.Rand)
+
+	ro, rl, no, nl, lo, ll, _, _ := commitOL(public, private.Wo, private.Wl, blind)
+	rr, nr, lr, _ := commitR(public, private.Wo, private.Wr, blind)
+
+	p := newCircuitPolynomial(public, private,
+		[][]*big.Int{rl, rr, ro},
+		[][]*big.Int{nl, nr, no},
+		[][]*big.Int{ll, lr, lo},
+		lambda, beta, delta, mul(rho, rho),
+	)
+
+	p.Ls, p.Ns = make([]*big.Int, public.Nv), make([]*big.Int, public.Nm)
+	for i := range p.Ls {
+		p.Ls[i] = blind()
+	}
+
+	for i := range p.Ns {
+		p.Ns[i] = blind()
+	}
+
+	return p
+}
+
+// checkCircuitPolynomial computes the prover's f'(t) coefficients and the blinding rs for p, rebuilds the vectors lT,
+// nT, cT and vT of the WNLA relation from the same witness and blinding vectors as the polynomials in t, and checks
+// the relation polynomial
+// f(t) = <cT, lT> + |nT|^2_mu - vT
+// Its witness part (without the 9 structural slots) must have the zero t^3 coefficient - the circuit relation, and
+// the other coefficients must be the negated prover's f'(t) ones, so the structural part with rs cancels them and
+// the whole polynomial is zero.
+func checkCircuitPolynomial(public *ArithmeticCircuitPublic, p *circuitPolynomial) {
+	f := p.coefficients(public)
+	rs := p.blinding(f)
+	deltaInv := inv(p.Delta)
+
+	// Structural slots: cr = (1, beta/t, beta*t, ..., beta*t^7)
+	structural := laurent{}
+	for i := 0; i < StructuralSlots; i++ {
+		cr := monomials(0, bint(1))
+		switch {
+		case i == 1:
+			cr = monomials(-1, p.Beta)
+		case i > 1:
+			cr = monomials(i-1, p.Beta)
+		}
+
+		lT := monomials(-1, scalarAt(rs, i), minus(mul(p.Delta, scalarAt(p.Ro, i))), scalarAt(p.Rl, i), minus(scalarAt(p.Rr, i)), scalarAt(p.Rv, i))
+		structural = structural.add(cr.mul(lT))
+	}
+
+	// Witness part of <cT, lT>
+	witness := laurent{}
+	for j := range p.Ls {
+		cl := monomials(0, minus(scalarAt(p.Cl0, j)), mul(bint(2), p.ClR[j]), minus(mul(bint(2), p.ClL[j])), mul(bint(2), mul(p.ClO[j], deltaInv)))
+		lT := monomials(-1, p.Ls[j], minus(mul(p.Delta, p.Lo[j])), p.Ll[j], minus(p.Lr[j]), scalarAt(p.V1, j))
+		witness = witness.add(cl.mul(lT))
+	}
+
+	// |nT|^2_mu and the same part of vT: |pnT|^2_mu
+	weight := p.Mu
+	for j := range p.Ns {
+		pnT := monomials(1, p.CnR[j], minus(p.CnL[j]), mul(p.CnO[j], deltaInv))
+		nT := pnT.add(monomials(-1, p.Ns[j], minus(mul(p.Delta, p.No[j])), p.Nl[j], minus(p.Nr[j])))
+
+		witness = witness.add(monomials(0, weight).mul(nT.mul(nT).add(monomials(0, minus(bint(1))).mul(pnT.mul(pnT)))))
+		weight = mul(weight, p.Mu)
+	}
+
+	// The rest of vT: (v_ + 2 * (<lambda, Al> - <mu, Am>)) * t^3
+	witness = witness.add(monomials(3, minus(add(p.V, mul(bint(2), sub(vectorMul(p.LambdaVec, public.Al), vectorMul(p.MuVec, public.Am)))))))
+
+	if witness.at(3).Sign() != 0 || f[3].Sign() != 0 {
+		panic("t^3 coefficient should be zero for the satisfied circuit")
+	}
+
+	for k := -2; k <= 6; k++ {
+		if witness.at(k).Cmp(minus(laurent(f).at(k))) != 0 {
+			panic(fmt.Sprintf("t^%d coefficient differs from the prover's f'(t)", k))
+		}
+	}
+
+	relation := structural.add(witness)
+	for k, c := range relation {
+		if c.Sign() != 0 {
+			panic(fmt.Sprintf("t^%d coefficient of the relation should be zero", k))
+		}
+	}
+}
+
+func TestCircuitPolynomial(t *testing.T) {
+	for _, newCircuit := range []func() (*ArithmeticCircuitPublic, *ArithmeticCircuitPrivate, []*bn256.G1){newTestCircuit, newBinaryRangeCircuit} {
+		public, private, _ := newCircuit()
+		checkCircuitPolynomial(public, newTestCircuitPolynomial(public, private, MustRandScalar(), MustRandScalar(), MustRandScalar(), MustRandScalar()))
+	}
+
+	// Unsatisfied circuit gives the non-zero t^3 coefficient
+	public, private, _ := newTestCircuit()
+	private.Wo[0] = add(private.Wo[0], bint(1))
+
+	failed := func() (failed bool) {
+		defer func() { failed = recover() != nil }()
+		checkCircuitPolynomial(public, newTestCircuitPolynomial(public, private, MustRandScalar(), MustRandScalar(), MustRandScalar(), MustRandScalar()))
+		return
+	}()
+
+	if !failed {
+		panic("polynomial check should fail for the unsatisfied circuit")
+	}
+}
+
+func TestNegPoint(t *testing.T) {
+	identity := new(bn256.G1).ScalarBaseMult(bint(0))
+
+	for _, p := range []*bn256.G1{MustRandPoint(), MustRandPoint(), identity} {
+		if !bytes.Equal(new(bn256.G1).Add(negPoint(p), p).Marshal(), identityBytes) {
+			panic("test failed")
+		}
+
+		if !pointsEqual(negPoint(p), new(bn256.G1).ScalarMult(p, minus(bint(1)))) {
+			panic("test failed")
+		}
+	}
+}
+
+func BenchmarkNegPoint(b *testing.B) {
+	p := MustRandPoint()
+
+	b.Run("ScalarMult", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			new(bn256.G1).ScalarMult(p, minus(bint(1)))
+		}
+	})
+
+	b.Run("negPoint", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			negPoint(p)
+		}
+	})
+}
+
+func TestGrowGenerators(t *testing.T) {
+	wnla := NewWeightNormLinearPublic(16, 8)
+
+	G, GVec, HVec := wnla.G, append([]*bn256.G1{}, wnla.GVec...), append([]*bn256.G1{}, wnla.HVec...)
+
+	GrowGenerators(wnla, 16, 32)
+
+	if len(wnla.GVec) != 16 || len(wnla.HVec) != 32 || len(wnla.C) != 32 {
+		panic("generators should be extended")
+	}
+
+	if wnla.G != G {
+		panic("G should not be changed")
+	}
+
+	for i := range GVec {
+		if !pointsEqual(wnla.GVec[i], GVec[i]) {
+			panic("existing GVec generators should not be changed")
+		}
+	}
+
+	for i := range HVec {
+		if !pointsEqual(wnla.HVec[i], HVec[i]) {
+			panic("existing HVec generators should not be changed")
+		}
+	}
+
+	// The subslices of the other generators are extended with the new backing arrays
+	full := NewWeightNormLinearPublic(32, 16)
+	fullGVec, fullHVec := append([]*bn256.G1{}, full.GVec...), append([]*bn256.G1{}, full.HVec...)
+	fullC := append([]*big.Int{}, full.C...)
+
+	GrowGenerators(&WeightNormLinearPublic{G: full.G, GVec: full.GVec[:8], HVec: full.HVec[:16], C: full.C[:16]}, 16, 32)
+
+	for i := range fullGVec {
+		if !pointsEqual(full.GVec[i], fullGVec[i]) {
+			panic("growth should not overwrite the shared GVec")
+		}
+	}
+
+	for i := range fullHVec {
+		if !pointsEqual(full.HVec[i], fullHVec[i]) || full.C[i].Cmp(fullC[i]) != 0 {
+			panic("growth should not overwrite the shared HVec and C")
+		}
+	}
+
+	// Deterministic for the same G
+	grown := &WeightNormLinearPublic{G: G, GVec: GVec}
+	GrowGenerators(grown, 16, 0)
+
+	for i := range grown.GVec {
+		if !pointsEqual(grown.GVec[i], wnla.GVec[i]) {
+			panic("growth should be deterministic")
+		}
+	}
+
+	// The range proof requires 16 GVec and 16+1+9 HVec generators
+	Nd, Np := 16, 16
+
+	public := testReciprocalPublicFrom(wnla, Nd, Np, 1)
+
+	proof, V, _, err := ProveRangeFresh(public, NewKeccakFS(), new(big.Int).SetUint64(0xab4f0540ab4f0540))
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRange(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
+
+func TestWNLAProofLayout(t *testing.T) {
+	for _, size := range [][2]int{{2, 2}, {8, 4}, {7, 5}, {64, 32}} {
+		public := NewWeightNormLinearPublic(size[0], size[1])
+
+		l := make([]*big.Int, size[0])
+		for i := range l {
+			l[i] = MustRandScalar()
+		}
+
+		n := make([]*big.Int, size[1])
+		for i := range n {
+			n[i] = MustRandScalar()
+		}
+
+		Com := public.CommitWNLA(l, n)
+
+		recursive := ProveWNLA(public, Com, NewKeccakFS(), l, n)
+		if err := VerifyWNLA(public, recursive, Com, NewKeccakFS()); err != nil {
+			panic(err)
+		}
+
+		public.Layout = Flattened
+		flat := ProveWNLA(public, Com, NewKeccakFS(), l, n)
+
+		if err := VerifyWNLAFlat(public, flat, Com, NewKeccakFS()); err != nil {
+			panic(fmt.Sprintf("size %v: %v", size, err))
+		}
+
+		if len(flat.Y) != len(flat.X) || len(flat.Folded) != len(flat.X) {
+			panic("flattened proof should contain the challenge and folded commitment of every round")
+		}
+
+		decoded := new(WeightNormLinearArgumentProof)
+		if err := decoded.Unmarshal(flat.Marshal()); err != nil {
+			panic(err)
+		}
+
+		if err := VerifyWNLAFlat(public, decoded, Com, NewKeccakFS()); err != nil {
+			panic(fmt.Sprintf("size %v: decoded proof: %v", size, err))
+		}
+
+		if err := decoded.Unmarshal(recursive.Marshal()); err != nil || decoded.IsFlattened() {
+			panic("decoded recursive proof should not be flattened")
+		}
+
+		if err := VerifyWNLAFlat(public, flat, new(bn256.G1).Add(Com, public.G), NewKeccakFS()); err == nil {
+			panic("flattened proof for the other commitment should fail")
+		}
+
+		if recursive.IsBaseCase() {
+			continue
+		}
+
+		// Flattened proof is accepted by the flat verifier only
+		if err := VerifyWNLA(public, flat, Com, NewKeccakFS()); err == nil {
+			panic("recursive verifier should reject the flattened proof")
+		}
+
+		if err := VerifyWNLAFast(public, flat, Com, NewKeccakFS()); err == nil {
+			panic("fast verifier should reject the flattened proof")
+		}
+
+		if err := VerifyWNLAFlat(public, recursive, Com, NewKeccakFS()); err == nil {
+			panic("flat verifier should reject the recursive proof")
+		}
+
+		corrupted := *flat
+		corrupted.Y = append([]*big.Int{add(flat.Y[0], bint(1))}, flat.Y[1:]...)
+		if err := VerifyWNLAFlat(public, &corrupted, Com, NewKeccakFS()); err == nil {
+			panic("proof with the wrong challenge should fail")
+		}
+
+		corrupted = *flat
+		corrupted.Folded = append([]*bn256.G1{new(bn256.G1).Add(flat.Folded[0], public.G)}, flat.Folded[1:]...)
+		if err := VerifyWNLAFlat(public, &corrupted, Com, NewKeccakFS()); err == nil {
+			panic("proof with the wrong folded commitment should fail")
+		}
+
+		corrupted = *flat
+		corrupted.L = append([]*big.Int{add(flat.L[0], bint(1))}, flat.L[1:]...)
+		if err := VerifyWNLAFlat(public, &corrupted, Com, NewKeccakFS()); err == nil {
+			panic("proof with the wrong l vector should fail")
+		}
+	}
+}
+
+func TestMergeGenerators(t *testing.T) {
+	seed := []byte("merge generators test")
+
+	// a is the prefix of b extended with its own random points
+	a := NewWeightNormLinearPublicFromSeed(seed, 8, 2)
+	b := NewWeightNormLinearPublicFromSeed(seed, 12, 4)
+
+	a.GVec = append(a.GVec, MustRandPoint())
+	a.HVec = append(a.HVec, MustRandPoint())
+	a.C = append(a.C, MustRandScalar())
+
+	merged, err := MergeGenerators(a, b)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(merged.GVec) != 5 || len(merged.HVec) != 13 || len(merged.C) != 13 {
+		panic(fmt.Sprintf("unexpected merged lengths %d, %d, %d", len(merged.GVec), len(merged.HVec), len(merged.C)))
+	}
+
+	// a points go first, then the new points of b
+	if !pointsEqual(merged.HVec[8], a.HVec[8]) || !pointsEqual(merged.HVec[9], b.HVec[8]) || merged.C[9].Cmp(b.C[8]) != 0 {
+		panic("merged generators should be aligned")
+	}
+
+	// Circuit is proven under the merged set
+	public, private, _ := newTestCircuit()
+	Nm, Nv := public.Nm, public.Nv
+
+	public.G = merged.G
+	public.GVec, public.GVec_ = merged.GVec[:Nm], merged.GVec[Nm:]
+	public.HVec, public.HVec_ = merged.HVec[:StructuralSlots+Nv], merged.HVec[StructuralSlots+Nv:]
+
+	V, err := public.CommitCircuit(private.V[0], private.Sv[0])
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := ProveCircuit(public, []*bn256.G1{V}, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, []*bn256.G1{V}, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	other := NewWeightNormLinearPublicFromSeed([]byte("other seed"), 8, 2)
+	if _, err = MergeGenerators(a, other); err == nil {
+		panic("sets with different G should not be merged")
+	}
+
+	other = NewWeightNormLinearPublicFromSeed(seed, 8, 2)
+	other.Ro = add(other.Ro, bint(1))
+	if _, err = MergeGenerators(a, other); err == nil {
+		panic("sets with different Ro should not be merged")
+	}
+
+	other = NewWeightNormLinearPublicFromSeed(seed, 8, 2)
+	other.GVec = append(other.GVec, a.HVec[0])
+	if _, err = MergeGenerators(a, other); err == nil {
+		panic("sets sharing GVec and HVec points should not be merged")
+	}
+
+	other = NewWeightNormLinearPublicFromSeed(seed, 8, 2)
+	other.C[0] = add(other.C[0], bint(1))
+	if _, err = MergeGenerators(a, other); err == nil {
+		panic("sets with the different C of the same HVec point should not be merged")
+	}
+
+	other = NewWeightNormLinearPublicFromSeed(seed, 8, 2)
+	other.C = nil
+	if _, err = MergeGenerators(a, other); err == nil {
+		panic("sets with C on one side only should not be merged")
+	}
+
+	if _, err = MergeGenerators(other, a); err == nil {
+		panic("sets with C on one side only should not be merged")
+	}
+
+	// Sets without C are merged without C
+	a.C, other.GVec, other.HVec = nil, other.GVec[:1], other.HVec[:1]
+	if merged, err = MergeGenerators(a, other); err != nil || merged.C != nil {
+		panic("sets without C should be merged without C")
+	}
 }
@@ -0,0 +1,114 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// transcriptComposed is absorbed before the composed proof, so its transcript differs from the transcripts of the
+// standalone range and circuit proofs.
+var transcriptComposed = HashToScalar([]byte("bulletproofs/composed"))
+
+// NewProofComposer creates the composer of the statement "x is in range and x satisfies the circuit". The value x
+// is committed in the index-th circuit commitment V[index] = x*G + s*HVec[0], that should be the range commitment
+// too, so the circuit should commit (x, 0, ..., 0) in the index-th vector. Returns error if the range and circuit
+// parameters do not share G and HVec[0], otherwise the same point does not bind both statements to the same x.
+func NewProofComposer(rangePublic *ReciprocalPublic, circuitPublic *ArithmeticCircuitPublic, index int) (*ProofComposer, error) {
+	if index < 0 || index >= circuitPublic.K {
+		return nil, fmt.Errorf("invalid index: should be in [0, %d), got %d", circuitPublic.K, index)
+	}
+
+	if err := circuitPublic.checkHVec(); err != nil {
+		return nil, err
+	}
+
+	if len(rangePublic.HVec) == 0 || !pointsEqual(rangePublic.G, circuitPublic.G) || !pointsEqual(rangePublic.HVec[0], circuitPublic.HVec[0]) {
+		return nil, errors.New("invalid public parameters: range and circuit should share G and HVec[0]")
+	}
+
+	return &ProofComposer{
+		rangePublic:   rangePublic,
+		circuitPublic: circuitPublic,
+		index:         index,
+	}, nil
+}
+
+// Prove generates the composed proof for the circuit commitments V, the range private values and the circuit private
+// values. The index-th circuit vector should be (rangePrivate.X, 0, ..., 0) with the blinding rangePrivate.S.
+//
+// The transcript ordering on fs is:
+//  1. the composition tag and the index as numbers;
+//  2. the range proof transcript of ProveRange for the commitment V[index];
+//  3. the circuit proof transcript of ProveCircuit for V, continuing the same engine.
+//
+// So the circuit challenges bind the whole range proof. Use empty FiatShamirEngine for call.
+func (c *ProofComposer) Prove(V []*bn256.G1, fs FiatShamirEngine, rangePrivate *ReciprocalPrivate, circuitPrivate *ArithmeticCircuitPrivate) (*ComposedProof, error) {
+	if len(rangePrivate.Xs) > 0 {
+		return nil, errors.New("invalid range private values: composition supports the single value proof")
+	}
+
+	if len(V) != c.circuitPublic.K || len(circuitPrivate.V) != c.circuitPublic.K || len(circuitPrivate.Sv) != c.circuitPublic.K {
+		return nil, errors.New("invalid circuit values: commitments and vectors count should be equal to K")
+	}
+
+	v := circuitPrivate.V[c.index]
+	if len(v) == 0 || new(big.Int).Mod(v[0], bn256.Order).Cmp(new(big.Int).Mod(rangePrivate.X, bn256.Order)) != 0 {
+		return nil, fmt.Errorf("invalid circuit values: vector %d should start with the range value", c.index)
+	}
+
+	for _, e := range v[1:] {
+		if new(big.Int).Mod(e, bn256.Order).Sign() != 0 {
+			return nil, fmt.Errorf("invalid circuit values: vector %d should commit only the range value", c.index)
+		}
+	}
+
+	if !pointsEqual(V[c.index], c.rangePublic.CommitValue(rangePrivate.X, rangePrivate.S)) {
+		return nil, fmt.Errorf("invalid commitment %d: does not open to the range value", c.index)
+	}
+
+	c.absorb(fs)
+
+	rangeProof, err := ProveRange(c.rangePublic, fs, rangePrivate)
+	if err != nil {
+		return nil, err
+	}
+
+	circuitProof, err := ProveCircuit(c.circuitPublic, V, fs, circuitPrivate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ComposedProof{Range: rangeProof, Circuit: circuitProof}, nil
+}
+
+// Verify verifies the composed proof for the circuit commitments V, the range proof is verified for V[index]. The
+// transcript is repeated in the order documented by Prove. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func (c *ProofComposer) Verify(V []*bn256.G1, fs FiatShamirEngine, proof *ComposedProof) error {
+	if proof == nil || proof.Range == nil || proof.Circuit == nil {
+		return errors.New("invalid proof: missing range or circuit proof")
+	}
+
+	if len(V) != c.circuitPublic.K {
+		return errors.New("invalid commitments: count should be equal to K")
+	}
+
+	c.absorb(fs)
+
+	if err := VerifyRange(c.rangePublic, V[c.index], fs, proof.Range); err != nil {
+		return err
+	}
+
+	return VerifyCircuit(c.circuitPublic, V, fs, proof.Circuit)
+}
+
+func (c *ProofComposer) absorb(fs FiatShamirEngine) {
+	fs.AddNumber(transcriptComposed)
+	fs.AddNumber(bint(c.index))
+}
@@ -0,0 +1,98 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestProofComposer(t *testing.T) {
+	Nd, Np := 16, 16
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 16)
+
+	rangePublic := testReciprocalPublicFrom(wnlaPublic, Nd, Np, 1)
+
+	// The circuit proves that the committed value is not zero: wl * wr = 1, wl = v
+	circuitPublic := (&NonZeroPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:1],
+			HVec:  wnlaPublic.HVec[:1+StructuralSlots],
+			GVec_: wnlaPublic.GVec[1:],
+			HVec_: wnlaPublic.HVec[1+StructuralSlots:],
+		},
+	}).circuit()
+
+	composer, err := NewProofComposer(rangePublic, circuitPublic, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	x := new(big.Int).SetUint64(0xab4f0540ab4f0540)
+	s := MustRandScalar()
+	digits := UInt64Hex(0xab4f0540ab4f0540)
+
+	rangePrivate := &ReciprocalPrivate{
+		X:      x,
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      s,
+	}
+
+	circuitPrivate := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{x}},
+		Sv: []*big.Int{s},
+		Wl: []*big.Int{x},
+		Wr: []*big.Int{inv(x)},
+		Wo: []*big.Int{bint(1)},
+	}
+
+	V := []*bn256.G1{rangePublic.CommitValue(x, s)}
+
+	proof, err := composer.Prove(V, NewKeccakFS(), rangePrivate, circuitPrivate)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = composer.Verify(V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// The proofs are generated on the shared transcript, so they are not valid standalone
+	if err = VerifyRange(rangePublic, V[0], NewKeccakFS(), proof.Range); err == nil {
+		panic("range proof should not be valid on the fresh transcript")
+	}
+
+	if err = VerifyCircuit(circuitPublic, V, NewKeccakFS(), proof.Circuit); err == nil {
+		panic("circuit proof should not be valid on the fresh transcript")
+	}
+
+	if err = composer.Verify([]*bn256.G1{rangePublic.CommitValue(add(x, bint(1)), s)}, NewKeccakFS(), proof); err == nil {
+		panic("proof should be rejected for the other commitment")
+	}
+
+	// The circuit should commit the same value as the range proof
+	circuitPrivate.V[0] = []*big.Int{add(x, bint(1))}
+	if _, err = composer.Prove(V, NewKeccakFS(), rangePrivate, circuitPrivate); err == nil {
+		panic("different values should be rejected")
+	}
+
+	otherG := &ReciprocalPublic{
+		G:     MustRandPoint(),
+		GVec:  rangePublic.GVec,
+		HVec:  rangePublic.HVec,
+		Nd:    rangePublic.Nd,
+		Np:    rangePublic.Np,
+		GVec_: rangePublic.GVec_,
+		HVec_: rangePublic.HVec_,
+	}
+
+	if _, err = NewProofComposer(otherG, circuitPublic, 0); err == nil {
+		panic("parameters with the different G should be rejected")
+	}
+}
@@ -0,0 +1,69 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// ProveDivisible generates zero knowledge proof that the value x committed in VCom = x*G + s*HVec[0] is a multiple of
+// the public d: x = q*d for the quotient q in [0, 2^Nb). The quotient is range bounded by its bits, so the equality
+// holds over the integers and not only modulo the group order. The bits circuit is reused, so use
+// BitsPublic.CommitValue to create the commitment. Use empty FiatShamirEngine for call.
+func ProveDivisible(public *BitsPublic, fs FiatShamirEngine, x, s, d *big.Int) (*ArithmeticCircuitProof, error) {
+	circuit, err := public.divisibleCircuit(d)
+	if err != nil {
+		return nil, err
+	}
+
+	q, r := new(big.Int).DivMod(x, d, new(big.Int))
+	if r.Sign() != 0 {
+		return nil, errors.New("invalid value: should be a multiple of d")
+	}
+
+	if q.Sign() < 0 || q.BitLen() > public.Nb {
+		return nil, errors.New("invalid value: quotient should be in [0, 2^Nb) range")
+	}
+
+	bits := make([]*big.Int, public.Nb)
+	for i := range bits {
+		bits[i] = bint(int(q.Bit(i)))
+	}
+
+	return public.proveCircuit(circuit, fs, x, s, bits)
+}
+
+// VerifyDivisible verifies the proof that the value committed in VCom is a multiple of d.
+// If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyDivisible(public *BitsPublic, VCom *bn256.G1, fs FiatShamirEngine, d *big.Int, proof *ArithmeticCircuitProof) error {
+	circuit, err := public.divisibleCircuit(d)
+	if err != nil {
+		return err
+	}
+
+	return public.verifyCircuit(circuit, VCom, fs, proof)
+}
+
+// divisibleCircuit builds the bits decomposition circuit for the quotient q, where the value equality
+// v[0][0] - sum(wl[i] * 2^i) = 0 is replaced with v[0][0] - d * sum(wl[i] * 2^i) = 0.
+// d * (2^Nb - 1) should be less than the group order, so the product does not wrap around.
+func (p *BitsPublic) divisibleCircuit(d *big.Int) (*ArithmeticCircuitPublic, error) {
+	if d.Sign() <= 0 {
+		return nil, errors.New("invalid divisor: should be positive")
+	}
+
+	if new(big.Int).Lsh(d, uint(p.Nb)).Cmp(bn256.Order) > 0 {
+		return nil, errors.New("invalid divisor: d * 2^Nb should not exceed the group order")
+	}
+
+	res := p.circuit()
+	for i := 0; i < res.Nm; i++ {
+		res.Wl[0][i] = minus(mul(d, pow(bint(2), i)))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,112 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestDivisible(t *testing.T) {
+	Nb := 8
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 8)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+StructuralSlots],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+StructuralSlots:],
+		},
+		Nb: Nb,
+	}
+
+	d := bint(100)
+
+	x := bint(500)
+	s := MustRandScalar()
+	VCom := public.CommitValue(x, s)
+
+	proof, err := ProveDivisible(public, NewKeccakFS(), x, s, d)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyDivisible(public, VCom, NewKeccakFS(), d, proof); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyDivisible(public, VCom, NewKeccakFS(), bint(3), proof); err == nil {
+		panic("proof should not be valid for the other divisor")
+	}
+
+	// 550 is not a multiple of 100
+	if _, err = ProveDivisible(public, NewKeccakFS(), bint(550), s, d); err == nil {
+		panic("value that is not a multiple of d should be rejected")
+	}
+
+	if err = VerifyDivisible(public, public.CommitValue(bint(550), s), NewKeccakFS(), d, proof); err == nil {
+		panic("proof should not be valid for 550")
+	}
+}
+
+func TestDivisibleForgedCommitment(t *testing.T) {
+	Nb := 8
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 8)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+StructuralSlots],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+StructuralSlots:],
+		},
+		Nb: Nb,
+	}
+
+	d := bint(100)
+
+	circuit, err := public.divisibleCircuit(d)
+	if err != nil {
+		panic(err)
+	}
+
+	// VCom = 550*G + s*HVec[0] + (1-q)*HVec[9] with wl[0] = q = 550/100 and wr[0] = 1
+	x, s := bint(550), MustRandScalar()
+	q := mul(x, inv(d))
+
+	v := zeroVector(circuit.Nv)
+	v[0], v[1] = x, sub(bint(1), q)
+
+	forged, err := circuit.CommitCircuit(v, s)
+	if err != nil {
+		panic(err)
+	}
+
+	wl, wr := zeroVector(Nb), zeroVector(Nb)
+	wl[0], wr[0] = q, bint(1)
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{v, zeroVector(circuit.Nv)},
+		Sv: []*big.Int{s, bint(0)},
+		Wl: wl,
+		Wr: wr,
+		Wo: []*big.Int{bint(0)},
+	}
+
+	proof, err := ProveCircuit(circuit, []*bn256.G1{forged, ZeroCommitment()}, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyDivisible(public, forged, NewKeccakFS(), d, proof); err == nil {
+		panic("proof for the forged commitment should fail")
+	}
+}
@@ -0,0 +1,688 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cloudflare/bn256"
+	"io"
+	"math/big"
+)
+
+// Proofs encoding: points are encoded with bn256.G1 Marshal (64 bytes), scalars as 32 bytes big-endian
+// and vectors are prefixed with uint32 big-endian length. The circuit proofs end with the ID of the engine
+// that generated them (see EngineIdentifier) prefixed with uint32 big-endian length.
+
+const (
+	pointSize  = 64
+	scalarSize = 32
+
+	// compressedPointSize is the size of the point encoded with MarshalCompressedG1
+	compressedPointSize = 33
+
+	// maxVectorLength limits the decoded vectors length
+	maxVectorLength = 1 << 20
+
+	// maxEngineIDLength limits the decoded FiatShamirEngine ID length
+	maxEngineIDLength = 256
+)
+
+// Marshal encodes the proof. The round challenges and folded commitments of the Flattened layout proof are appended
+// after the N vector, so the Recursive layout proofs are encoded as before. The proofs inside the circuit proofs are
+// always encoded without them.
+func (p *WeightNormLinearArgumentProof) Marshal() []byte {
+	enc := &encoder{}
+	enc.wnla(p)
+
+	if p.IsFlattened() {
+		enc.scalars(p.Y)
+		enc.points(p.Folded)
+	}
+
+	return enc.buf
+}
+
+func (p *WeightNormLinearArgumentProof) Unmarshal(data []byte) error {
+	return unmarshal(data, func(dec *decoder) (err error) {
+		if err = dec.wnla(p); err != nil {
+			return err
+		}
+
+		if dec.r.(*bytes.Reader).Len() == 0 {
+			p.Y, p.Folded = nil, nil
+			return nil
+		}
+
+		if p.Y, err = dec.scalars(); err != nil {
+			return err
+		}
+
+		p.Folded, err = dec.points()
+		return err
+	})
+}
+
+func (p *ArithmeticCircuitProof) Marshal() []byte {
+	enc := &encoder{}
+	enc.circuit(p)
+	return enc.buf
+}
+
+func (p *ArithmeticCircuitProof) Unmarshal(data []byte) error {
+	return unmarshal(data, func(dec *decoder) error {
+		return dec.circuit(p)
+	})
+}
+
+// CompactMarshal encodes the proof without the data the verifier can derive from the circuit. None of the proof
+// scalars are recomputable: L and N are the final WNLA responses and depend on the witness, so the savings come from
+// the structure only. The vectors lengths are omitted, as the rounds count and the L, N lengths are defined by the
+// generators count of the circuit, and the 4+2*rounds points are compressed with MarshalCompressedG1. It saves
+// 31*(4+2*rounds)+16 bytes, e.g. 264 of 704 bytes (37%) for the 2 rounds proof with 5 final scalars generated
+// with NewKeccakFS. The engine ID is encoded as in Marshal. Decode with CompactUnmarshal for the same circuit.
+func (p *ArithmeticCircuitProof) CompactMarshal() []byte {
+	enc := &encoder{}
+
+	for _, c := range []*bn256.G1{p.CL, p.CR, p.CO, p.CS} {
+		enc.buf = append(enc.buf, MarshalCompressedG1(c)...)
+	}
+
+	for i := range p.WNLA.R {
+		enc.buf = append(enc.buf, MarshalCompressedG1(p.WNLA.R[i])...)
+		enc.buf = append(enc.buf, MarshalCompressedG1(p.WNLA.X[i])...)
+	}
+
+	for _, v := range append(append([]*big.Int{}, p.WNLA.L...), p.WNLA.N...) {
+		enc.scalar(v)
+	}
+
+	enc.engine(p.Engine)
+	return enc.buf
+}
+
+// CompactUnmarshal decodes the proof encoded with CompactMarshal for the circuit public. The lengths of the WNLA
+// vectors are reconstructed from the circuit generators count, so the public should be the same as during proving.
+func (p *ArithmeticCircuitProof) CompactUnmarshal(public *ArithmeticCircuitPublic, data []byte) error {
+	rounds, lLen, nLen := wnlaShape(len(public.HVec)+len(public.HVec_), len(public.GVec)+len(public.GVec_))
+
+	// The engine ID length is checked by the decoder
+	if expected := (4+2*rounds)*compressedPointSize + (lLen+nLen)*scalarSize + 4; len(data) < expected {
+		return fmt.Errorf("invalid encoding: compact proof should have at least %d bytes, got %d", expected, len(data))
+	}
+
+	return unmarshal(data, func(dec *decoder) (err error) {
+		for _, c := range []**bn256.G1{&p.CL, &p.CR, &p.CO, &p.CS} {
+			if *c, err = dec.compressedPoint(); err != nil {
+				return err
+			}
+		}
+
+		p.WNLA = &WeightNormLinearArgumentProof{
+			R: make([]*bn256.G1, rounds),
+			X: make([]*bn256.G1, rounds),
+			L: make([]*big.Int, lLen),
+			N: make([]*big.Int, nLen),
+		}
+
+		for i := 0; i < rounds; i++ {
+			if p.WNLA.R[i], err = dec.compressedPoint(); err != nil {
+				return err
+			}
+
+			if p.WNLA.X[i], err = dec.compressedPoint(); err != nil {
+				return err
+			}
+		}
+
+		for _, v := range [][]*big.Int{p.WNLA.L, p.WNLA.N} {
+			for i := range v {
+				if v[i], err = dec.scalar(); err != nil {
+					return err
+				}
+			}
+		}
+
+		p.Engine, err = dec.engine()
+		return err
+	})
+}
+
+// Marshal encodes the reciprocal proof as the arithmetic circuit proof followed by the poles commitment V and the
+// poles commitments vector Vs. V of the proof for several values is nil and encoded as the point at infinity.
+func (p *ReciprocalProof) Marshal() []byte {
+	enc := &encoder{}
+	enc.circuit(p.ArithmeticCircuitProof)
+
+	if p.V != nil {
+		enc.point(p.V)
+	} else {
+		enc.point(new(bn256.G1).ScalarBaseMult(bint(0)))
+	}
+
+	enc.points(p.Vs)
+	return enc.buf
+}
+
+func (p *ReciprocalProof) Unmarshal(data []byte) error {
+	return unmarshal(data, func(dec *decoder) error {
+		return dec.reciprocal(p)
+	})
+}
+
+// Marshal encodes the aggregated reciprocal proof as the arithmetic circuit proof followed by the poles commitments vector V.
+func (p *AggregatedReciprocalProof) Marshal() []byte {
+	enc := &encoder{}
+	enc.circuit(p.ArithmeticCircuitProof)
+	enc.points(p.V)
+	return enc.buf
+}
+
+func (p *AggregatedReciprocalProof) Unmarshal(data []byte) error {
+	return unmarshal(data, func(dec *decoder) (err error) {
+		p.ArithmeticCircuitProof = &ArithmeticCircuitProof{}
+		if err = dec.circuit(p.ArithmeticCircuitProof); err != nil {
+			return err
+		}
+
+		p.V, err = dec.points()
+		return err
+	})
+}
+
+// Marshal encodes the compiled circuit: dimensions Nm, Nl, Nv, Nw, No, K and flags Fl, Fm as uint32, generators G, GVec,
+// HVec, GVec_, HVec_, constants Am, Al and the M matrices as the rows count followed by the rows.
+// Scalars are reduced modulo bn256.Order.
+func (c *CompiledCircuit) Marshal() []byte {
+	p := c.scratch.public
+
+	enc := &encoder{}
+	for _, n := range []int{p.Nm, p.Nl, p.Nv, p.Nw, p.No, p.K, int(bbool(p.Fl).Int64()), int(bbool(p.Fm).Int64())} {
+		enc.length(n)
+	}
+
+	enc.point(p.G)
+	enc.points(p.GVec)
+	enc.points(p.HVec)
+	enc.points(p.GVec_)
+	enc.points(p.HVec_)
+	enc.scalars(reduceScalars(p.Am))
+	enc.scalars(reduceScalars(p.Al))
+
+	for _, m := range c.scratch.matrices.list() {
+		enc.length(len(*m))
+		for _, row := range *m {
+			enc.scalars(reduceScalars(row))
+		}
+	}
+
+	return enc.buf
+}
+
+// Unmarshal decodes the compiled circuit encoded with Marshal. Returns error if the dimensions of the decoded values
+// are not consistent.
+func (c *CompiledCircuit) Unmarshal(data []byte) error {
+	return unmarshal(data, func(dec *decoder) (err error) {
+		dims := make([]int, 8)
+		for i := range dims {
+			if dims[i], err = dec.length(); err != nil {
+				return err
+			}
+		}
+
+		p := &ArithmeticCircuitPublic{
+			Nm: dims[0], Nl: dims[1], Nv: dims[2], Nw: dims[3], No: dims[4], K: dims[5],
+			Fl: dims[6] == 1, Fm: dims[7] == 1,
+		}
+
+		if p.Nl != p.Nv*p.K || p.Nw != p.Nm+p.Nm+p.No || dims[6] > 1 || dims[7] > 1 {
+			return errors.New("invalid encoding: inconsistent circuit dimensions")
+		}
+
+		if p.G, err = dec.point(); err != nil {
+			return err
+		}
+
+		for _, v := range []*[]*bn256.G1{&p.GVec, &p.HVec, &p.GVec_, &p.HVec_} {
+			if *v, err = dec.points(); err != nil {
+				return err
+			}
+		}
+
+		if p.Am, err = dec.scalars(); err != nil {
+			return err
+		}
+
+		if p.Al, err = dec.scalars(); err != nil {
+			return err
+		}
+
+		if len(p.GVec) < p.Nm || len(p.Am) != p.Nm || len(p.Al) != p.Nl {
+			return errors.New("invalid encoding: inconsistent circuit vectors")
+		}
+
+		if err = p.checkHVec(); err != nil {
+			return err
+		}
+
+		m := &circuitMatrices{}
+		for i, matrix := range m.list() {
+			rows, cols := p.Nl, p.Nm
+			if i%2 == 1 {
+				rows = p.Nm
+			}
+
+			if i >= 6 {
+				cols = p.Nv
+			}
+
+			if *matrix, err = dec.matrix(rows, cols); err != nil {
+				return err
+			}
+		}
+
+		c.scratch = &VerifyScratch{
+			public:   p,
+			matrices: m,
+			gVec:     concatPoints(p.GVec, p.GVec_),
+			hVec:     concatPoints(p.HVec, p.HVec_),
+		}
+
+		return nil
+	})
+}
+
+// reduceScalars returns the values reduced modulo bn256.Order, so the negative values can be encoded.
+func reduceScalars(v []*big.Int) []*big.Int {
+	res := make([]*big.Int, len(v))
+	for i := range v {
+		res[i] = new(big.Int).Mod(v[i], bn256.Order)
+	}
+
+	return res
+}
+
+// UnmarshalCommitment decodes the commitment point. Returns error for the invalid encoding, points that are not on
+// the curve, not canonical encoding of the coordinates and for the identity point, that is not a valid commitment.
+func UnmarshalCommitment(data []byte) (*bn256.G1, error) {
+	if len(data) != pointSize {
+		return nil, errors.New("invalid encoding: wrong point size")
+	}
+
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal does not check that coordinates are reduced, so different encodings can lead to the same point
+	if !bytes.Equal(p.Marshal(), data) {
+		return nil, errors.New("invalid encoding: not canonical point")
+	}
+
+	if bytes.Equal(data, make([]byte, pointSize)) {
+		return nil, errors.New("invalid commitment: identity point")
+	}
+
+	return p, nil
+}
+
+// G1FromCoords constructs the point from the affine coordinates, as they are passed to the EVM precompiles.
+// Returns error for the coordinates outside of [0, p) and for the point that is not on the curve.
+// Following the precompiles, (0, 0) is the identity point.
+func G1FromCoords(x, y *big.Int) (*bn256.G1, error) {
+	if x.Sign() < 0 || x.Cmp(curveP) >= 0 || y.Sign() < 0 || y.Cmp(curveP) >= 0 {
+		return nil, errors.New("invalid coordinates: should be in [0, p) range")
+	}
+
+	buf := make([]byte, pointSize)
+	x.FillBytes(buf[:pointSize/2])
+	y.FillBytes(buf[pointSize/2:])
+
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(buf); err != nil {
+		return nil, errors.New("invalid coordinates: point is not on the curve")
+	}
+
+	return p, nil
+}
+
+// ScalarFromBytes decodes the 32 bytes big-endian scalar. Returns error for the wrong size and for the not reduced
+// values >= bn256.Order, so every scalar has the single valid encoding.
+func ScalarFromBytes(data []byte) (*big.Int, error) {
+	if len(data) != scalarSize {
+		return nil, errors.New("invalid encoding: wrong scalar size")
+	}
+
+	res := new(big.Int).SetBytes(data)
+	if res.Cmp(bn256.Order) >= 0 {
+		return nil, errors.New("invalid encoding: scalar is not reduced")
+	}
+
+	return res, nil
+}
+
+// curveP is the base field modulus of the bn256 curve y^2 = x^3 + 3. It is not exported by bn256.
+var curveP, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+// MarshalCompressedG1 encodes the point as the 33 bytes: the prefix 0x02 for the even y or 0x03 for the odd y, followed
+// by the 32 bytes big-endian x. The identity is encoded as 33 zero bytes.
+//
+// The 32 bytes encoding with the y-sign and infinity flags in the two top bits of x (arkworks, gnark) needs p < 2^254.
+// It holds for BN254 (alt_bn128), but this is the other curve: its base field modulus p is about 1.12*2^255, so x
+// uses all 256 bits and there is no spare bit for the flags. The 32 bytes BN254 points of those libraries are not on
+// this curve and can not be decoded here at all, so the prefix of SEC1 format is used instead.
+func MarshalCompressedG1(p *bn256.G1) []byte {
+	res := make([]byte, compressedPointSize)
+
+	data := p.Marshal()
+	if bytes.Equal(data, identityBytes) {
+		return res
+	}
+
+	res[0] = 0x02 | data[pointSize-1]&1
+	copy(res[1:], data[:pointSize/2])
+	return res
+}
+
+// UnmarshalCompressedG1 decodes the point encoded with MarshalCompressedG1. Returns error for the wrong size or prefix,
+// for the not reduced x and for x that does not correspond to a curve point.
+func UnmarshalCompressedG1(data []byte) (*bn256.G1, error) {
+	if len(data) != compressedPointSize {
+		return nil, errors.New("invalid encoding: wrong compressed point size")
+	}
+
+	if bytes.Equal(data, make([]byte, compressedPointSize)) {
+		return new(bn256.G1).ScalarBaseMult(bint(0)), nil
+	}
+
+	if data[0] != 0x02 && data[0] != 0x03 {
+		return nil, errors.New("invalid encoding: wrong compressed point prefix")
+	}
+
+	x := new(big.Int).SetBytes(data[1:])
+	if x.Cmp(curveP) >= 0 {
+		return nil, errors.New("invalid encoding: x is not reduced")
+	}
+
+	// y^2 = x^3 + 3
+	y2 := new(big.Int).Exp(x, bint(3), curveP)
+	y2.Add(y2, bint(3))
+	y2.Mod(y2, curveP)
+
+	y := new(big.Int).ModSqrt(y2, curveP)
+	if y == nil {
+		return nil, errors.New("invalid encoding: x is not on the curve")
+	}
+
+	if y.Bit(0) != uint(data[0]&1) {
+		y.Sub(curveP, y)
+	}
+
+	buf := make([]byte, pointSize)
+	x.FillBytes(buf[:pointSize/2])
+	y.FillBytes(buf[pointSize/2:])
+
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func unmarshal(data []byte, f func(dec *decoder) error) error {
+	r := bytes.NewReader(data)
+	if err := f(&decoder{r: r}); err != nil {
+		return err
+	}
+
+	if r.Len() != 0 {
+		return errors.New("invalid encoding: unexpected trailing data")
+	}
+
+	return nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) length(n int) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+}
+
+func (e *encoder) point(p *bn256.G1) {
+	e.buf = append(e.buf, p.Marshal()...)
+}
+
+func (e *encoder) scalar(v *big.Int) {
+	e.buf = append(e.buf, scalarTo32Byte(v)...)
+}
+
+func (e *encoder) points(v []*bn256.G1) {
+	e.length(len(v))
+	for _, p := range v {
+		e.point(p)
+	}
+}
+
+func (e *encoder) scalars(v []*big.Int) {
+	e.length(len(v))
+	for _, s := range v {
+		e.scalar(s)
+	}
+}
+
+func (e *encoder) wnla(p *WeightNormLinearArgumentProof) {
+	e.points(p.R)
+	e.points(p.X)
+	e.scalars(p.L)
+	e.scalars(p.N)
+}
+
+func (e *encoder) circuit(p *ArithmeticCircuitProof) {
+	e.point(p.CL)
+	e.point(p.CR)
+	e.point(p.CO)
+	e.point(p.CS)
+	e.wnla(p.WNLA)
+	e.engine(p.Engine)
+}
+
+func (e *encoder) engine(id string) {
+	e.length(len(id))
+	e.buf = append(e.buf, id...)
+}
+
+type decoder struct {
+	r io.Reader
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return nil, errors.New("invalid encoding: not enough data")
+	}
+	return buf, nil
+}
+
+func (d *decoder) length() (int, error) {
+	buf, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+
+	n := binary.BigEndian.Uint32(buf)
+	if n > maxVectorLength {
+		return 0, errors.New("invalid encoding: vector is too long")
+	}
+
+	return int(n), nil
+}
+
+func (d *decoder) point() (*bn256.G1, error) {
+	buf, err := d.read(pointSize)
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (d *decoder) compressedPoint() (*bn256.G1, error) {
+	buf, err := d.read(compressedPointSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnmarshalCompressedG1(buf)
+}
+
+func (d *decoder) scalar() (*big.Int, error) {
+	buf, err := d.read(scalarSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return ScalarFromBytes(buf)
+}
+
+func (d *decoder) points() ([]*bn256.G1, error) {
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*bn256.G1, 0)
+	for i := 0; i < n; i++ {
+		p, err := d.point()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+
+	return res, nil
+}
+
+func (d *decoder) scalars() ([]*big.Int, error) {
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*big.Int, 0)
+	for i := 0; i < n; i++ {
+		s, err := d.scalar()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+// matrix decodes the matrix with the given dimensions.
+func (d *decoder) matrix(rows, cols int) ([][]*big.Int, error) {
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+
+	if n != rows {
+		return nil, errors.New("invalid encoding: wrong matrix rows count")
+	}
+
+	res := make([][]*big.Int, n)
+	for i := range res {
+		if res[i], err = d.scalars(); err != nil {
+			return nil, err
+		}
+
+		if len(res[i]) != cols {
+			return nil, errors.New("invalid encoding: wrong matrix columns count")
+		}
+	}
+
+	return res, nil
+}
+
+func (d *decoder) reciprocal(p *ReciprocalProof) (err error) {
+	p.ArithmeticCircuitProof = &ArithmeticCircuitProof{}
+	if err = d.circuit(p.ArithmeticCircuitProof); err != nil {
+		return err
+	}
+
+	if p.V, err = d.point(); err != nil {
+		return err
+	}
+
+	if p.Vs, err = d.points(); err != nil {
+		return err
+	}
+
+	if len(p.Vs) == 0 {
+		p.Vs = nil
+	} else {
+		p.V = nil
+	}
+
+	return nil
+}
+
+func (d *decoder) wnla(p *WeightNormLinearArgumentProof) (err error) {
+	if p.R, err = d.points(); err != nil {
+		return err
+	}
+
+	if p.X, err = d.points(); err != nil {
+		return err
+	}
+
+	if p.L, err = d.scalars(); err != nil {
+		return err
+	}
+
+	p.N, err = d.scalars()
+	return err
+}
+
+func (d *decoder) circuit(p *ArithmeticCircuitProof) (err error) {
+	for _, c := range []**bn256.G1{&p.CL, &p.CR, &p.CO, &p.CS} {
+		if *c, err = d.point(); err != nil {
+			return err
+		}
+	}
+
+	p.WNLA = &WeightNormLinearArgumentProof{}
+	if err = d.wnla(p.WNLA); err != nil {
+		return err
+	}
+
+	p.Engine, err = d.engine()
+	return err
+}
+
+func (d *decoder) engine() (string, error) {
+	n, err := d.length()
+	if err != nil {
+		return "", err
+	}
+
+	if n > maxEngineIDLength {
+		return "", errors.New("invalid encoding: engine ID is too long")
+	}
+
+	id, err := d.read(n)
+	return string(id), err
+}
@@ -0,0 +1,255 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestScalarFromBytes(t *testing.T) {
+	orderMinusOne := new(big.Int).Sub(bn256.Order, bint(1))
+
+	s, err := ScalarFromBytes(orderMinusOne.FillBytes(make([]byte, 32)))
+	if err != nil {
+		panic(err)
+	}
+
+	if s.Cmp(orderMinusOne) != 0 {
+		panic("test failed")
+	}
+
+	for _, v := range []*big.Int{bn256.Order, new(big.Int).Add(bn256.Order, bint(1))} {
+		if _, err := ScalarFromBytes(v.FillBytes(make([]byte, 32))); err == nil {
+			panic("not reduced scalar should be rejected")
+		}
+	}
+
+	if _, err := ScalarFromBytes(make([]byte, 31)); err == nil {
+		panic("short scalar should be rejected")
+	}
+
+	// Not reduced scalar inside the proof encoding
+	proof := &WeightNormLinearArgumentProof{
+		R: []*bn256.G1{},
+		X: []*bn256.G1{},
+		L: []*big.Int{bint(1)},
+		N: []*big.Int{bn256.Order},
+	}
+
+	if err := new(WeightNormLinearArgumentProof).Unmarshal(proof.Marshal()); err == nil {
+		panic("proof with not reduced scalar should be rejected")
+	}
+
+	proof.N[0] = bint(0)
+	if err := new(WeightNormLinearArgumentProof).Unmarshal(proof.Marshal()); err != nil {
+		panic(err)
+	}
+}
+
+func TestCompressedG1(t *testing.T) {
+	for i := 0; i < 32; i++ {
+		p := MustRandPoint()
+
+		data := MarshalCompressedG1(p)
+		if len(data) != 33 {
+			panic("compressed point should take 33 bytes")
+		}
+
+		res, err := UnmarshalCompressedG1(data)
+		if err != nil {
+			panic(err)
+		}
+
+		if !pointsEqual(p, res) {
+			panic("decompressed point differs from the original one")
+		}
+
+		// Negated point differs only by the prefix
+		neg := MarshalCompressedG1(negPoint(p))
+		if neg[0] == data[0] || !bytes.Equal(neg[1:], data[1:]) {
+			panic("negated point should have the other prefix")
+		}
+	}
+
+	// Known answers: G = (1, p-2) and 2G
+	for k, expected := range []string{
+		"030000000000000000000000000000000000000000000000000000000000000001",
+		"0308fb501e34aa387f9aa6fecb86184dc21ee5b88d120b5b59e185cac6c5e08965",
+	} {
+		p := new(bn256.G1).ScalarBaseMult(bint(k + 1))
+		if res := hex.EncodeToString(MarshalCompressedG1(p)); res != expected {
+			panic(fmt.Sprintf("compressed %dG: expected %s, got %s", k+1, expected, res))
+		}
+
+		data, _ := hex.DecodeString(expected)
+		if res, err := UnmarshalCompressedG1(data); err != nil || !pointsEqual(res, p) {
+			panic(fmt.Sprintf("compressed %dG should be decoded", k+1))
+		}
+	}
+
+	// x needs all 256 bits, so the flags do not fit into the 32 bytes x
+	if curveP.Cmp(new(big.Int).Lsh(bint(1), 255)) <= 0 {
+		panic("base field modulus should exceed 2^255")
+	}
+
+	identity := new(bn256.G1).ScalarBaseMult(bint(0))
+	if res, err := UnmarshalCompressedG1(MarshalCompressedG1(identity)); err != nil || !pointsEqual(res, identity) {
+		panic("identity should be round-tripped")
+	}
+
+	invalid := MarshalCompressedG1(MustRandPoint())
+	invalid[0] = 0x04
+	if _, err := UnmarshalCompressedG1(invalid); err == nil {
+		panic("wrong prefix should be rejected")
+	}
+
+	notReduced := append([]byte{0x02}, curveP.FillBytes(make([]byte, 32))...)
+	if _, err := UnmarshalCompressedG1(notReduced); err == nil {
+		panic("not reduced x should be rejected")
+	}
+}
+
+func TestCompactMarshal(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	full, compact := proof.Marshal(), proof.CompactMarshal()
+
+	rounds := len(proof.WNLA.X)
+	if len(full)-len(compact) != 31*(4+2*rounds)+16 {
+		panic(fmt.Sprintf("unexpected sizes: full %d, compact %d", len(full), len(compact)))
+	}
+
+	decoded := &ArithmeticCircuitProof{}
+	if err = decoded.CompactUnmarshal(public, compact); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), decoded); err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(decoded.Marshal(), full) {
+		panic("decoded proof should be equal to the original one")
+	}
+
+	if err = decoded.CompactUnmarshal(public, compact[:len(compact)-1]); err == nil {
+		panic("truncated encoding should be rejected")
+	}
+
+	// Other generators count changes the proof shape
+	other := &ArithmeticCircuitPublic{
+		Nm: public.Nm, Nl: public.Nl, Nv: public.Nv, Nw: public.Nw, No: public.No, K: public.K,
+		G: public.G, GVec: public.GVec, HVec: public.HVec,
+		Wm: public.Wm, Wl: public.Wl, Am: public.Am, Al: public.Al, Fl: public.Fl, Fm: public.Fm, F: public.F,
+		GVec_: public.GVec_,
+		HVec_: append(append([]*bn256.G1{}, public.HVec_...), MustRandPoint(), MustRandPoint()),
+	}
+	if err = decoded.CompactUnmarshal(other, compact); err == nil {
+		panic("encoding for the other circuit shape should be rejected")
+	}
+}
+
+func TestReciprocalProofMarshal(t *testing.T) {
+	Nd, Np, K := 16, 16, 2
+
+	public := newTestReciprocalPublicMulti(t, Nd, Np, K)
+
+	d1, d2 := UInt64Hex(0xab4f0540ab4f0540), UInt64Hex(0x1234567890abcdef)
+
+	private := &ReciprocalPrivate{
+		Xs:         []*big.Int{new(big.Int).SetUint64(0xab4f0540ab4f0540), new(big.Int).SetUint64(0x1234567890abcdef)},
+		Ms:         [][]*big.Int{HexMapping(d1), HexMapping(d2)},
+		DigitsList: [][]*big.Int{d1, d2},
+		Ss:         []*big.Int{MustRandScalar(), MustRandScalar()},
+	}
+
+	V := []*bn256.G1{
+		public.CommitValue(private.Xs[0], private.Ss[0]),
+		public.CommitValue(private.Xs[1], private.Ss[1]),
+	}
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	decoded := &ReciprocalProof{}
+	if err = decoded.Unmarshal(proof.Marshal()); err != nil {
+		panic(err)
+	}
+
+	if decoded.V != nil || len(decoded.Vs) != K {
+		panic("decoded proof should contain the poles commitment of every value")
+	}
+
+	if err = VerifyRangeMulti(public, V, NewKeccakFS(), decoded); err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(decoded.Marshal(), proof.Marshal()) {
+		panic("decoded proof should be equal to the original one")
+	}
+
+	// The single value proof has no Vs
+	private = &ReciprocalPrivate{X: private.Xs[0], M: private.Ms[0], Digits: d1, S: private.Ss[0]}
+
+	if proof, err = ProveRange(public, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if err = decoded.Unmarshal(proof.Marshal()); err != nil {
+		panic(err)
+	}
+
+	if decoded.V == nil || decoded.Vs != nil {
+		panic("decoded single value proof should contain V only")
+	}
+
+	if err = VerifyRange(public, V[0], NewKeccakFS(), decoded); err != nil {
+		panic(err)
+	}
+}
+
+func TestG1FromCoords(t *testing.T) {
+	p := MustRandPoint()
+	data := p.Marshal()
+
+	x, y := new(big.Int).SetBytes(data[:32]), new(big.Int).SetBytes(data[32:])
+
+	res, err := G1FromCoords(x, y)
+	if err != nil {
+		panic(err)
+	}
+
+	if !pointsEqual(res, p) {
+		panic("test failed")
+	}
+
+	if _, err = G1FromCoords(x, new(big.Int).Add(y, bint(1))); err == nil {
+		panic("off-curve coordinates should be rejected")
+	}
+
+	if _, err = G1FromCoords(x, new(big.Int).Add(y, curveP)); err == nil {
+		panic("not reduced coordinates should be rejected")
+	}
+
+	if _, err = G1FromCoords(bint(-1), y); err == nil {
+		panic("negative coordinates should be rejected")
+	}
+
+	if res, err = G1FromCoords(bint(0), bint(0)); err != nil || !bytes.Equal(res.Marshal(), identityBytes) {
+		panic("(0, 0) should be the identity")
+	}
+}
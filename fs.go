@@ -5,24 +5,77 @@
 package bulletproofs
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"github.com/cloudflare/bn256"
-	"github.com/ethereum/go-ethereum/crypto"
+	"hash"
 	"math/big"
 )
 
+// FiatShamirEngine generates challenges for the non-interactive protocols.
+// GetChallenge should never return zero, as the challenges are inverted during proving and verification.
+// Custom implementations can use NonZeroChallenge to resample their challenges.
+//
+// Points are absorbed with bn256.G1 Marshal. The point at infinity is encoded as 64 zero bytes. This encoding is
+// unambiguous, because (0, 0) is not on the curve, so the identity is absorbed without a separate tag and the
+// transcripts stay compatible with other implementations. Identity value commitments are rejected on decoding
+// by UnmarshalCommitment instead.
 type FiatShamirEngine interface {
 	AddPoint(*bn256.G1)
 	AddNumber(*big.Int)
 	GetChallenge() *big.Int
 }
 
+// ErrTranscriptEngineMismatch is returned by the verification when the proof records the ID of the engine that differs
+// from the verifier's one.
+var ErrTranscriptEngineMismatch = errors.New("transcript engine mismatch: proof was generated with another FiatShamirEngine")
+
+// EngineIdentifier is implemented by the FiatShamirEngine that has a stable identifier. ProveCircuit records the ID
+// into the proof, and the verification fails fast with ErrTranscriptEngineMismatch if the verifier's engine has
+// another ID. Engines without ID are not checked.
+type EngineIdentifier interface {
+	ID() string
+}
+
+// engineID returns the ID of the engine or empty string if the engine does not implement EngineIdentifier.
+func engineID(fs FiatShamirEngine) string {
+	if e, ok := fs.(EngineIdentifier); ok {
+		return e.ID()
+	}
+
+	return ""
+}
+
+// checkEngine returns ErrTranscriptEngineMismatch if both the proof and the engine have IDs and they differ.
+func checkEngine(fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	if id := engineID(fs); proof.Engine != "" && id != "" && id != proof.Engine {
+		return ErrTranscriptEngineMismatch
+	}
+
+	return nil
+}
+
+// KeccakFS implements FiatShamirEngine over the legacy Keccak256 hash function (as used in Ethereum).
+// By default, the golang.org/x/crypto/sha3 implementation is used. Build with `geth` tag to use the
+// go-ethereum implementation instead. Both produce identical challenges.
+// Challenges are the single 32 bytes digest reduced modulo the ~2^254 group order, so they have a slight bias,
+// which is kept for the compatibility with the existing proofs. Use HashToScalar in the new engines instead.
 type KeccakFS struct {
-	state   crypto.KeccakState
+	state   hash.Hash
 	counter int
+	version int
 }
 
 func NewKeccakFS() FiatShamirEngine {
-	return &KeccakFS{state: crypto.NewKeccakState()}
+	return &KeccakFS{state: newKeccakState()}
+}
+
+// NewKeccakFSVersioned creates KeccakFS with the challenge counter started from the version number, so transcripts
+// of different protocol versions diverge even for the same absorbed data. Prover and verifier must use the same
+// version. NewKeccakFSVersioned(0) is equal to NewKeccakFS().
+func NewKeccakFSVersioned(version int) FiatShamirEngine {
+	return &KeccakFS{state: newKeccakState(), counter: version, version: version}
 }
 
 func (k *KeccakFS) AddPoint(p *bn256.G1) {
@@ -37,10 +90,138 @@ func (k *KeccakFS) AddNumber(v *big.Int) {
 	}
 }
 
+// GetChallenge returns the next challenge. If the challenge is zero the counter is advanced and the state is squeezed again.
 func (k *KeccakFS) GetChallenge() *big.Int {
-	k.counter++
-	k.AddNumber(bint(k.counter))
-	return new(big.Int).Mod(new(big.Int).SetBytes(k.state.Sum(nil)), bn256.Order)
+	return NonZeroChallenge(func() *big.Int {
+		k.counter++
+		k.AddNumber(bint(k.counter))
+		return new(big.Int).Mod(new(big.Int).SetBytes(k.state.Sum(nil)), bn256.Order)
+	})
+}
+
+// ID returns "keccak256-v<version>", e.g. "keccak256-v0" for NewKeccakFS.
+func (k *KeccakFS) ID() string {
+	return fmt.Sprintf("keccak256-v%d", k.version)
+}
+
+// Fingerprint returns the digest of the current transcript state without modifying it. Use it after verification to
+// identify the transcript, e.g. for logging: the same proof and parameters give the same fingerprint.
+func (k *KeccakFS) Fingerprint() [32]byte {
+	var res [32]byte
+	copy(res[:], k.state.Sum(nil))
+	return res
+}
+
+// MerlinFS implements FiatShamirEngine over the Merlin transcript (STROBE-128 based), compatible with
+// the dalek bulletproofs ecosystem. Points are appended with "point" label, scalars with "scalar" label and
+// challenges are extracted as 64 bytes with "challenge" label and reduced modulo the group order.
+type MerlinFS struct {
+	strobe *strobe128
+	label  string
+}
+
+func NewMerlinFS(label string) FiatShamirEngine {
+	m := &MerlinFS{strobe: newStrobe128([]byte("Merlin v1.0")), label: label}
+	m.appendMessage([]byte("dom-sep"), []byte(label))
+	return m
+}
+
+func (m *MerlinFS) AddPoint(p *bn256.G1) {
+	m.appendMessage([]byte("point"), p.Marshal())
+}
+
+func (m *MerlinFS) AddNumber(v *big.Int) {
+	m.appendMessage([]byte("scalar"), scalarTo32Byte(v))
+}
+
+// GetChallenge returns the next challenge. If the challenge is zero the next one is extracted.
+func (m *MerlinFS) GetChallenge() *big.Int {
+	return NonZeroChallenge(func() *big.Int {
+		return new(big.Int).Mod(new(big.Int).SetBytes(m.challengeBytes([]byte("challenge"), 64)), bn256.Order)
+	})
+}
+
+// ID returns "merlin-v1.0/<label>", as the transcripts with the different domain separation labels differ.
+func (m *MerlinFS) ID() string {
+	return "merlin-v1.0/" + m.label
+}
+
+// Fingerprint returns 32 bytes extracted with "fingerprint" label from the copy of the current transcript state,
+// so the transcript is not modified.
+func (m *MerlinFS) Fingerprint() [32]byte {
+	strobe := *m.strobe
+	cp := &MerlinFS{strobe: &strobe, label: m.label}
+
+	var res [32]byte
+	copy(res[:], cp.challengeBytes([]byte("fingerprint"), 32))
+	return res
+}
+
+func (m *MerlinFS) appendMessage(label, message []byte) {
+	m.strobe.metaAD(label, false)
+	m.strobe.metaAD(binary.LittleEndian.AppendUint32(nil, uint32(len(message))), true)
+	m.strobe.ad(message, false)
+}
+
+func (m *MerlinFS) challengeBytes(label []byte, n int) []byte {
+	m.strobe.metaAD(label, false)
+	m.strobe.metaAD(binary.LittleEndian.AppendUint32(nil, uint32(n)), true)
+
+	res := make([]byte, n)
+	m.strobe.prf(res, false)
+	return res
+}
+
+// BindPublicInput absorbs the named public input into the transcript, so the proof generated with this transcript
+// is valid only for the same input. Call it with the same label and value on the empty FiatShamirEngine before both
+// proving and verifying, e.g.:
+//
+//	fs := NewKeccakFS()
+//	BindPublicInput(fs, "amount", amount)
+//	proof, err := ProveCircuit(public, V, fs, private)
+//
+// The label is absorbed as HashToScalar(label) to separate inputs with the same value.
+func BindPublicInput(fs FiatShamirEngine, label string, value *big.Int) {
+	fs.AddNumber(HashToScalar([]byte(label)))
+	fs.AddNumber(value)
+}
+
+// BindMetadata absorbs the proof metadata into the transcript, so the proof generated with this transcript is valid
+// only for the same nonce and expiry. Call it on the empty FiatShamirEngine before both proving and verifying.
+// ProveCircuitWithMetadata and VerifyCircuitWithMetadata call it for the circuit proofs. Returns error if m is nil.
+func BindMetadata(fs FiatShamirEngine, m *Metadata) error {
+	if m == nil {
+		return errors.New("invalid metadata: should not be nil")
+	}
+
+	fs.AddNumber(HashToScalar([]byte("metadata"), m.Nonce))
+	fs.AddNumber(new(big.Int).SetUint64(uint64(m.NotAfter)))
+	return nil
+}
+
+// ExternalCommitment is the commitment to the same value created outside of bn256, e.g. the secp256k1 Pedersen
+// commitment of a Bitcoin system. The library can not check it: the caller verifies it out-of-band, including that
+// it commits to the same value as the bn256 value commitment. Scheme identifies the commitment scheme
+// (e.g. "secp256k1-pedersen") and Bytes returns its canonical encoding.
+type ExternalCommitment interface {
+	Scheme() string
+	Bytes() []byte
+}
+
+// BindExternalCommitment absorbs the hash of the external commitment into the transcript, so the proof generated
+// with this transcript is valid only for the same external commitment. Call it on the empty FiatShamirEngine before
+// both proving and verifying. ProveRangeExternal and VerifyRangeExternal call it for the range proofs.
+func BindExternalCommitment(fs FiatShamirEngine, c ExternalCommitment) {
+	fs.AddNumber(HashToScalar([]byte("external-commitment"), []byte(c.Scheme()), c.Bytes()))
+}
+
+// NonZeroChallenge calls next until it returns non-zero challenge.
+func NonZeroChallenge(next func() *big.Int) *big.Int {
+	for {
+		if c := next(); c.Sign() != 0 {
+			return c
+		}
+	}
 }
 
 func scalarTo32Byte(s *big.Int) []byte {
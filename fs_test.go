@@ -5,9 +5,16 @@
 package bulletproofs
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/cloudflare/bn256"
 	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/sha3"
 	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -23,6 +30,7 @@ func TestKeccakFS(t *testing.T) {
 			crypto.Keccak256(
 				scalarTo32Byte(bint(1)),
 				scalarTo32Byte(bint(2)),
+				scalarTo32Byte(bint(1)), // counter
 			),
 		),
 		bn256.Order,
@@ -40,7 +48,9 @@ func TestKeccakFS(t *testing.T) {
 			crypto.Keccak256(
 				scalarTo32Byte(bint(1)),
 				scalarTo32Byte(bint(2)),
+				scalarTo32Byte(bint(1)), // counter
 				scalarTo32Byte(bint(3)),
+				scalarTo32Byte(bint(2)), // counter
 			),
 		),
 		bn256.Order,
@@ -50,3 +60,388 @@ func TestKeccakFS(t *testing.T) {
 		panic("test failed")
 	}
 }
+
+func TestMerlinFS(t *testing.T) {
+	// Test vector from the merlin crate: simple transcript
+	fs := NewMerlinFS("test protocol").(*MerlinFS)
+	fs.appendMessage([]byte("some label"), []byte("some data"))
+
+	c := fs.challengeBytes([]byte("challenge"), 32)
+	if hex.EncodeToString(c) != "d5a21972d0d5fe320c0d263fac7fffb8145aa640af6e9bca177c03c7efcf0615" {
+		panic("test failed")
+	}
+
+	// Test vector from the merlin crate: complex transcript
+	fs = NewMerlinFS("test protocol").(*MerlinFS)
+	fs.appendMessage([]byte("step1"), []byte("some data"))
+
+	data := make([]byte, 1024)
+	for i := range data {
+		data[i] = 99
+	}
+
+	for i := 0; i < 32; i++ {
+		c = fs.challengeBytes([]byte("challenge"), 32)
+		fs.appendMessage([]byte("bigdata"), data)
+		fs.appendMessage([]byte("challengedata"), c)
+	}
+
+	if hex.EncodeToString(c) != "a8c933f54fae76e3f9bea93648c1308e7dfa2152dd51674ff3ca438351cf003c" {
+		panic("test failed")
+	}
+
+	// Challenges should be reproducible for the same transcript
+	fs1 := NewMerlinFS("bulletproofs")
+	fs2 := NewMerlinFS("bulletproofs")
+
+	fs1.AddPoint(new(bn256.G1).ScalarBaseMult(bint(5)))
+	fs2.AddPoint(new(bn256.G1).ScalarBaseMult(bint(5)))
+	fs1.AddNumber(bint(7))
+	fs2.AddNumber(bint(7))
+
+	if fs1.GetChallenge().Cmp(fs2.GetChallenge()) != 0 {
+		panic("test failed")
+	}
+
+	if fs1.GetChallenge().Cmp(NewMerlinFS("bulletproofs").GetChallenge()) == 0 {
+		panic("test failed")
+	}
+}
+
+// riggedFS returns the predefined challenges sequence
+type riggedFS struct {
+	challenges []*big.Int
+	calls      int
+}
+
+func (r *riggedFS) AddPoint(*bn256.G1) {}
+
+func (r *riggedFS) AddNumber(*big.Int) {}
+
+func (r *riggedFS) GetChallenge() *big.Int {
+	return NonZeroChallenge(func() *big.Int {
+		c := r.challenges[r.calls]
+		r.calls++
+		return c
+	})
+}
+
+func TestNonZeroChallenge(t *testing.T) {
+	fs := &riggedFS{challenges: []*big.Int{bint(0), bint(0), bint(5), bint(0), bint(7)}}
+
+	if fs.GetChallenge().Cmp(bint(5)) != 0 || fs.calls != 3 {
+		panic("test failed")
+	}
+
+	if fs.GetChallenge().Cmp(bint(7)) != 0 || fs.calls != 5 {
+		panic("test failed")
+	}
+}
+
+func TestKeccakFSBackends(t *testing.T) {
+	fs1 := &KeccakFS{state: sha3.NewLegacyKeccak256()}
+	fs2 := &KeccakFS{state: crypto.NewKeccakState()}
+
+	for i := 0; i < 10; i++ {
+		p := MustRandPoint()
+		v := MustRandScalar()
+
+		fs1.AddPoint(p)
+		fs2.AddPoint(p)
+		fs1.AddNumber(v)
+		fs2.AddNumber(v)
+
+		if fs1.GetChallenge().Cmp(fs2.GetChallenge()) != 0 {
+			panic("test failed")
+		}
+	}
+}
+
+func TestNewKeccakFSVersioned(t *testing.T) {
+	p := MustRandPoint()
+	v := MustRandScalar()
+
+	fs0 := NewKeccakFSVersioned(0)
+	fs1 := NewKeccakFSVersioned(1)
+	fs2 := NewKeccakFSVersioned(2)
+	fs := NewKeccakFS()
+
+	for _, f := range []FiatShamirEngine{fs0, fs1, fs2, fs} {
+		f.AddPoint(p)
+		f.AddNumber(v)
+	}
+
+	c0, c1, c2 := fs0.GetChallenge(), fs1.GetChallenge(), fs2.GetChallenge()
+
+	if c0.Cmp(c1) == 0 || c1.Cmp(c2) == 0 || c0.Cmp(c2) == 0 {
+		panic("different versions should produce different challenges")
+	}
+
+	if c0.Cmp(fs.GetChallenge()) != 0 {
+		panic("version 0 should be equal to the default engine")
+	}
+}
+
+func TestFSIdentityPoint(t *testing.T) {
+	identity := new(bn256.G1).ScalarBaseMult(bint(0))
+
+	if !bytes.Equal(identity.Marshal(), make([]byte, 64)) {
+		panic("identity should be encoded as zero bytes")
+	}
+
+	// Identity contribution to the transcript is the zero encoding
+	fs1 := &KeccakFS{state: newKeccakState()}
+	fs1.AddPoint(identity)
+
+	fs2 := &KeccakFS{state: newKeccakState()}
+	if _, err := fs2.state.Write(make([]byte, 64)); err != nil {
+		panic(err)
+	}
+
+	if fs1.GetChallenge().Cmp(fs2.GetChallenge()) != 0 {
+		panic("test failed")
+	}
+
+	// and it differs from any other point and from not absorbing anything
+	for _, engine := range []func() FiatShamirEngine{NewKeccakFS, func() FiatShamirEngine { return NewMerlinFS("test") }} {
+		withIdentity, withGenerator, empty := engine(), engine(), engine()
+		withIdentity.AddPoint(identity)
+		withGenerator.AddPoint(new(bn256.G1).ScalarBaseMult(bint(1)))
+
+		c := withIdentity.GetChallenge()
+		if c.Cmp(withGenerator.GetChallenge()) == 0 || c.Cmp(empty.GetChallenge()) == 0 {
+			panic("test failed")
+		}
+	}
+}
+
+func TestHashToScalar(t *testing.T) {
+	if HashToScalar([]byte("a"), []byte("bc")).Cmp(HashToScalar([]byte("a"), []byte("bc"))) != 0 {
+		panic("test failed")
+	}
+
+	// Parts are length prefixed
+	if HashToScalar([]byte("a"), []byte("bc")).Cmp(HashToScalar([]byte("ab"), []byte("c"))) == 0 {
+		panic("test failed")
+	}
+
+	// Chi-squared test for the uniform distribution over 16 equal ranges of [0, order)
+	const buckets, samples = 16, 16000
+
+	counts := make([]int, buckets)
+	for i := 0; i < samples; i++ {
+		s := HashToScalar([]byte("distribution"), binary.BigEndian.AppendUint32(nil, uint32(i)))
+		if s.Cmp(bn256.Order) >= 0 {
+			panic("scalar should be reduced")
+		}
+
+		counts[new(big.Int).Div(new(big.Int).Mul(s, big.NewInt(buckets)), bn256.Order).Int64()]++
+	}
+
+	expected := float64(samples) / buckets
+
+	chi2 := 0.0
+	for _, c := range counts {
+		chi2 += (float64(c) - expected) * (float64(c) - expected) / expected
+	}
+
+	// 15 degrees of freedom, p = 0.001
+	if chi2 > 37.7 {
+		panic(fmt.Sprintf("distribution is not uniform: chi2 = %f", chi2))
+	}
+}
+
+func TestTranscriptEngineMismatch(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if proof.Engine != "keccak256-v0" {
+		panic("proof should record the engine ID")
+	}
+
+	// The ID is encoded with the proof
+	decoded := &ArithmeticCircuitProof{}
+	if err = decoded.Unmarshal(proof.Marshal()); err != nil {
+		panic(err)
+	}
+
+	if decoded.Engine != proof.Engine {
+		panic("decoded proof should have the engine ID")
+	}
+
+	if err = VerifyCircuit(public, V, NewKeccakFS(), decoded); err != nil {
+		panic(err)
+	}
+
+	for _, fs := range []FiatShamirEngine{NewMerlinFS("test"), NewKeccakFSVersioned(1)} {
+		if err = VerifyCircuit(public, V, fs, decoded); !errors.Is(err, ErrTranscriptEngineMismatch) {
+			panic(fmt.Sprintf("expected engine mismatch, got %v", err))
+		}
+	}
+
+	// Every engine and version has its own ID
+	ids := map[string]bool{}
+	for _, fs := range []FiatShamirEngine{NewKeccakFS(), NewKeccakFSVersioned(1), NewKeccakFSVersioned(2), NewMerlinFS("a"), NewMerlinFS("b")} {
+		ids[engineID(fs)] = true
+	}
+
+	if len(ids) != 5 {
+		panic("engine IDs should be distinct")
+	}
+
+	// Engines without ID are not checked, the verification fails on the transcript
+	err = VerifyCircuit(public, V, &riggedFS{challenges: []*big.Int{bint(1), bint(2), bint(3), bint(4), bint(5), bint(6), bint(7)}}, proof)
+	if err == nil || errors.Is(err, ErrTranscriptEngineMismatch) {
+		panic("proof should be rejected by the transcript check")
+	}
+}
+
+// recordingFS records the operations with the wrapped FiatShamirEngine and the challenges it returns
+type recordingFS struct {
+	FiatShamirEngine
+	ops []string
+}
+
+func (r *recordingFS) AddPoint(p *bn256.G1) {
+	r.ops = append(r.ops, fmt.Sprintf("point %x", p.Marshal()))
+	r.FiatShamirEngine.AddPoint(p)
+}
+
+func (r *recordingFS) AddNumber(v *big.Int) {
+	r.ops = append(r.ops, fmt.Sprintf("number %x", v))
+	r.FiatShamirEngine.AddNumber(v)
+}
+
+func (r *recordingFS) GetChallenge() *big.Int {
+	c := r.FiatShamirEngine.GetChallenge()
+	r.ops = append(r.ops, fmt.Sprintf("challenge %x", c))
+	return c
+}
+
+// kinds returns the kinds of the recorded operations
+func (r *recordingFS) kinds() []string {
+	res := make([]string, len(r.ops))
+	for i, op := range r.ops {
+		res[i] = op[:strings.IndexByte(op, ' ')]
+	}
+	return res
+}
+
+// checkTranscripts checks that the verifier repeats the prover's transcript operation for operation
+func checkTranscripts(name string, prover, verifier *recordingFS) {
+	for i := range prover.ops {
+		if i >= len(verifier.ops) {
+			panic(fmt.Sprintf("%s: verifier stopped at operation %d, prover did %q", name, i, prover.ops[i]))
+		}
+
+		if prover.ops[i] != verifier.ops[i] {
+			panic(fmt.Sprintf("%s: operation %d differs: prover %q, verifier %q", name, i, prover.ops[i], verifier.ops[i]))
+		}
+	}
+
+	if len(verifier.ops) != len(prover.ops) {
+		panic(fmt.Sprintf("%s: verifier did %d operations, prover did %d", name, len(verifier.ops), len(prover.ops)))
+	}
+}
+
+func TestTranscriptDrift(t *testing.T) {
+	// Circuit
+	circuit, private, V := newTestCircuit()
+
+	prover, verifier := &recordingFS{FiatShamirEngine: NewKeccakFS()}, &recordingFS{FiatShamirEngine: NewKeccakFS()}
+
+	circuitProof, err := ProveCircuit(circuit, V, prover, private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(circuit, V, verifier, circuitProof); err != nil {
+		panic(err)
+	}
+
+	checkTranscripts("circuit", prover, verifier)
+
+	// WNLA of both layouts
+	wnla := NewWeightNormLinearPublic(64, 32)
+
+	l := make([]*big.Int, 64)
+	for i := range l {
+		l[i] = MustRandScalar()
+	}
+
+	n := make([]*big.Int, 32)
+	for i := range n {
+		n[i] = MustRandScalar()
+	}
+
+	Com := wnla.CommitWNLA(l, n)
+
+	prover, verifier = &recordingFS{FiatShamirEngine: NewKeccakFS()}, &recordingFS{FiatShamirEngine: NewKeccakFS()}
+	if err = VerifyWNLA(wnla, ProveWNLA(wnla, Com, prover, l, n), Com, verifier); err != nil {
+		panic(err)
+	}
+
+	checkTranscripts("wnla", prover, verifier)
+
+	wnla.Layout = Flattened
+
+	prover, verifier = &recordingFS{FiatShamirEngine: NewKeccakFS()}, &recordingFS{FiatShamirEngine: NewKeccakFS()}
+	if err = VerifyWNLAFlat(wnla, ProveWNLA(wnla, Com, prover, l, n), Com, verifier); err != nil {
+		panic(err)
+	}
+
+	checkTranscripts("flattened wnla", prover, verifier)
+
+	// Range proofs of single and several values
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublicMulti(t, Nd, Np, 2)
+
+	xs := []*big.Int{new(big.Int).SetUint64(0xab4f0540ab4f0540), new(big.Int).SetUint64(0x1234567890abcdef)}
+	ss := []*big.Int{MustRandScalar(), MustRandScalar()}
+
+	digits, m, err := ReciprocalWitness(xs[0], Np, Nd)
+	if err != nil {
+		panic(err)
+	}
+
+	prover, verifier = &recordingFS{FiatShamirEngine: NewKeccakFS()}, &recordingFS{FiatShamirEngine: NewKeccakFS()}
+
+	rangeProof, err := ProveRange(public, prover, &ReciprocalPrivate{X: xs[0], M: m, Digits: digits, S: ss[0]})
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRange(public, public.CommitValue(xs[0], ss[0]), verifier, rangeProof); err != nil {
+		panic(err)
+	}
+
+	checkTranscripts("range", prover, verifier)
+
+	multi := &ReciprocalPrivate{Xs: xs, Ss: ss}
+	for _, x := range xs {
+		if digits, m, err = ReciprocalWitness(x, Np, Nd); err != nil {
+			panic(err)
+		}
+
+		multi.DigitsList = append(multi.DigitsList, digits)
+		multi.Ms = append(multi.Ms, m)
+	}
+
+	prover, verifier = &recordingFS{FiatShamirEngine: NewKeccakFS()}, &recordingFS{FiatShamirEngine: NewKeccakFS()}
+
+	if rangeProof, err = ProveRange(public, prover, multi); err != nil {
+		panic(err)
+	}
+
+	VCom := []*bn256.G1{public.CommitValue(xs[0], ss[0]), public.CommitValue(xs[1], ss[1])}
+	if err = VerifyRangeMulti(public, VCom, verifier, rangeProof); err != nil {
+		panic(err)
+	}
+
+	checkTranscripts("multi-value range", prover, verifier)
+}
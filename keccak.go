@@ -0,0 +1,16 @@
+//go:build !geth
+
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"golang.org/x/crypto/sha3"
+	"hash"
+)
+
+func newKeccakState() hash.Hash {
+	return sha3.NewLegacyKeccak256()
+}
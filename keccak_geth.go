@@ -0,0 +1,16 @@
+//go:build geth
+
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+	"hash"
+)
+
+func newKeccakState() hash.Hash {
+	return crypto.NewKeccakState()
+}
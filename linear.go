@@ -0,0 +1,105 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// ProveLinearRelation generates zero knowledge proof that the values x, y committed in xCom = x*G + sx*HVec[0] and
+// yCom = y*G + sy*HVec[0] satisfy a*x + b*y = c for the public a, b, c. Use empty FiatShamirEngine for call.
+func ProveLinearRelation(public *LinearRelationPublic, fs FiatShamirEngine, a, b, c *big.Int, xCom, yCom *bn256.G1, x, y, sx, sy *big.Int) (*ArithmeticCircuitProof, error) {
+	circuit, err := public.circuit(a, b, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// wl carries the value that is not bound by its own linear row
+	wl := x
+	if new(big.Int).Mod(b, bn256.Order).Sign() == 0 {
+		wl = y
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{x}, {y}},
+		Sv: []*big.Int{sx, sy},
+		Wl: []*big.Int{wl},
+		Wr: []*big.Int{bint(0)},
+		Wo: []*big.Int{bint(0)},
+	}
+
+	return ProveCircuit(circuit, []*bn256.G1{xCom, yCom}, fs, private)
+}
+
+// VerifyLinearRelation verifies the proof that the values committed in xCom and yCom satisfy a*x + b*y = c.
+// If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyLinearRelation(public *LinearRelationPublic, fs FiatShamirEngine, a, b, c *big.Int, xCom, yCom *bn256.G1, proof *ArithmeticCircuitProof) error {
+	circuit, err := public.circuit(a, b, c)
+	if err != nil {
+		return err
+	}
+
+	return VerifyCircuit(circuit, []*bn256.G1{xCom, yCom}, fs, proof)
+}
+
+// circuit builds the arithmetic circuit for a*x + b*y = c, where v[0][0] = x and v[1][0] = y.
+// Every linear row contains one committed value with the coefficient 1, so for b != 0:
+// -wl + x = 0 - wl carries x to the second row,
+// (a/b) * wl + y - c/b = 0 - the relation divided by b.
+// For b = 0 the relation is a*x = c:
+// x - c/a = 0,
+// -wl + y = 0 - y is not constrained.
+// The multiplication gate wl * wr = 0 and the output wo are not constrained (wr = wo = 0).
+func (p *LinearRelationPublic) circuit(a, b, c *big.Int) (*ArithmeticCircuitPublic, error) {
+	Nm, No, Nv, K := 1, 1, 1, 2
+
+	Wl := zeroMatrix(Nv*K, Nm+Nm+No)
+	Al := zeroVector(Nv * K)
+
+	switch {
+	case new(big.Int).Mod(b, bn256.Order).Sign() != 0:
+		binv := inv(b)
+		Wl[0][0] = bint(-1)
+		Wl[1][0] = mul(a, binv)
+		Al[1] = minus(mul(c, binv))
+	case new(big.Int).Mod(a, bn256.Order).Sign() != 0:
+		Al[0] = minus(mul(c, inv(a)))
+		Wl[1][0] = bint(-1)
+	default:
+		return nil, errors.New("invalid relation: a and b are both zero")
+	}
+
+	res := &ArithmeticCircuitPublic{
+		Nm:   Nm,
+		Nl:   Nv * K,
+		Nv:   Nv,
+		Nw:   Nm + Nm + No,
+		No:   No,
+		K:    K,
+		G:    p.G,
+		GVec: p.GVec,
+		HVec: p.HVec,
+		Wm:   zeroMatrix(Nm, Nm+Nm+No),
+		Wl:   Wl,
+		Am:   zeroVector(Nm),
+		Al:   Al,
+		Fl:   true,
+		Fm:   false,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
+	}
+
+	res.gTable.inherit(&p.gTable)
+	return res, nil
+}
@@ -0,0 +1,68 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"testing"
+)
+
+func TestLinearRelation(t *testing.T) {
+	wnlaPublic := NewWeightNormLinearPublic(16, 1)
+
+	public := &LinearRelationPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:1],
+			HVec:  wnlaPublic.HVec[:1+9],
+			GVec_: wnlaPublic.GVec[1:],
+			HVec_: wnlaPublic.HVec[1+9:],
+		},
+	}
+
+	x, y := bint(2), bint(3)
+	sx, sy := MustRandScalar(), MustRandScalar()
+	xCom, yCom := public.CommitValue(x, sx), public.CommitValue(y, sy)
+
+	// 2x + 3y = 13
+	proof, err := ProveLinearRelation(public, NewKeccakFS(), bint(2), bint(3), bint(13), xCom, yCom, x, y, sx, sy)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyLinearRelation(public, NewKeccakFS(), bint(2), bint(3), bint(13), xCom, yCom, proof); err != nil {
+		panic(err)
+	}
+
+	// Wrong coefficients
+	if err := VerifyLinearRelation(public, NewKeccakFS(), bint(3), bint(2), bint(13), xCom, yCom, proof); err == nil {
+		panic("verification with wrong coefficients should fail")
+	}
+
+	if err := VerifyLinearRelation(public, NewKeccakFS(), bint(2), bint(3), bint(14), xCom, yCom, proof); err == nil {
+		panic("verification with wrong constant should fail")
+	}
+
+	// 2x + 3y = 14 does not hold
+	if proof, err = ProveLinearRelation(public, NewKeccakFS(), bint(2), bint(3), bint(14), xCom, yCom, x, y, sx, sy); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyLinearRelation(public, NewKeccakFS(), bint(2), bint(3), bint(14), xCom, yCom, proof); err == nil {
+		panic("proof for the wrong relation should fail")
+	}
+
+	// b = 0: 5x = 10
+	if proof, err = ProveLinearRelation(public, NewKeccakFS(), bint(5), bint(0), bint(10), xCom, yCom, x, y, sx, sy); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyLinearRelation(public, NewKeccakFS(), bint(5), bint(0), bint(10), xCom, yCom, proof); err != nil {
+		panic(err)
+	}
+
+	if _, err := ProveLinearRelation(public, NewKeccakFS(), bint(0), bint(0), bint(0), xCom, yCom, x, y, sx, sy); err == nil {
+		panic("relation without values should be rejected")
+	}
+}
@@ -0,0 +1,99 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestFixedBaseMul(t *testing.T) {
+	g := MustRandPoint()
+
+	for _, window := range []int{1, 3, 4, 8} {
+		fb := NewFixedBaseMul(g, window)
+
+		scalars := []*big.Int{bint(0), bint(1), bint(2), minus(bint(1)), new(big.Int).Add(bn256.Order, bint(5))}
+		for i := 0; i < 32; i++ {
+			scalars = append(scalars, MustRandScalar())
+		}
+
+		for _, k := range scalars {
+			if !pointsEqual(fb.Mul(k), new(bn256.G1).ScalarMult(g, k)) {
+				panic("test failed")
+			}
+		}
+	}
+}
+
+func BenchmarkFixedBaseMul(b *testing.B) {
+	g := MustRandPoint()
+	k := MustRandScalar()
+
+	b.Run("ScalarMult", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			new(bn256.G1).ScalarMult(g, k)
+		}
+	})
+
+	b.Run("FixedBaseMul", func(b *testing.B) {
+		fb := NewFixedBaseMul(g, 4)
+		b.ResetTimer()
+
+		for n := 0; n < b.N; n++ {
+			fb.Mul(k)
+		}
+	})
+}
+
+func TestPrecomputeG(t *testing.T) {
+	g := MustRandPoint()
+	table := newWNAFTable(g, gWindow)
+
+	top := new(big.Int).Sub(new(big.Int).Lsh(bint(1), uint(bn256.Order.BitLen())), bint(1))
+	scalars := []*big.Int{bint(0), bint(1), bint(8), bint(9), minus(bint(1)), new(big.Int).Add(bn256.Order, bint(5)), top}
+	for i := 0; i < 32; i++ {
+		scalars = append(scalars, MustRandScalar())
+	}
+
+	for _, k := range scalars {
+		if !pointsEqual(table.mul(k), new(bn256.G1).ScalarMult(g, k)) {
+			panic("test failed")
+		}
+	}
+
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	if public.precomputeG() != public.aggregated().circuit(bint(1)).precomputeG() {
+		panic("derived parameters should share the table")
+	}
+
+	// The table is stored on the object, the other parameters with the same G precompute their own one
+	if public.precomputeG() == (&ReciprocalPublic{G: public.G, HVec: public.HVec}).precomputeG() {
+		panic("parameters should not share the table")
+	}
+
+	x, s := MustRandScalar(), MustRandScalar()
+	commit := func() *bn256.G1 {
+		res := new(bn256.G1).ScalarMult(public.G, x)
+		return res.Add(res, new(bn256.G1).ScalarMult(public.HVec[0], s))
+	}
+
+	if !pointsEqual(public.CommitValue(x, s), commit()) {
+		panic("test failed")
+	}
+
+	// In-place change of G precomputes the table again
+	public.G.Add(public.G, public.G)
+	if !pointsEqual(public.CommitValue(x, s), commit()) {
+		panic("commitment should use the changed G")
+	}
+
+	value := &ValueCommitmentPublic{G: public.G, HVec: public.HVec}
+	if !pointsEqual(value.CommitValue(x, s), commit()) || value.precomputeG() != value.precomputeG() {
+		panic("value commitment should use the table of G")
+	}
+}
@@ -17,29 +17,27 @@ func zeroMatrix(n, m int) [][]*big.Int {
 	return res
 }
 
-func diagInv(x *big.Int, n int) [][]*big.Int {
-	var res [][]*big.Int = make([][]*big.Int, n)
-	inv := inv(x)
-	val := new(big.Int).Set(inv)
+// diagInvMul returns v * diag(x^-1, x^-2, ..., x^-len(v)) without building the matrix.
+func diagInvMul(v []*big.Int, x *big.Int) []*big.Int {
+	xinv := inv(x)
+	val := new(big.Int).Set(xinv)
 
-	for i := 0; i < n; i++ {
-		res[i] = make([]*big.Int, n)
-
-		for j := 0; j < n; j++ {
-			res[i][j] = bint(0)
-
-			if i == j {
-				res[i][j] = val
-				val = mul(val, inv)
-			}
-		}
+	res := make([]*big.Int, len(v))
+	for i := range res {
+		res[i] = mul(v[i], val)
+		val = mul(val, xinv)
 	}
 
 	return res
 }
 
+// vectorMulOnMatrix returns a*m. The matrix without rows (e.g. Mm of the circuit with Nm = 0) gives the empty result,
+// that is treated as the zero vector by vectorAdd and vectorSub.
 func vectorMulOnMatrix(a []*big.Int, m [][]*big.Int) []*big.Int {
 	var res []*big.Int
+	if len(m) == 0 {
+		return res
+	}
 
 	for j := 0; j < len(m[0]); j++ {
 		var column []*big.Int
@@ -63,3 +61,14 @@ func matrixMulOnVector(a []*big.Int, m [][]*big.Int) []*big.Int {
 
 	return res
 }
+
+// MatrixVectorMul returns the product of matrix m (n*k) and column vector v (k): res[i] = <m[i], v>, len(res) = n.
+// Use it to evaluate the circuit constraints, e.g. MatrixVectorMul(Wm, w).
+func MatrixVectorMul(m [][]*big.Int, v []*big.Int) []*big.Int {
+	return matrixMulOnVector(v, m)
+}
+
+// VectorMatrixMul returns the product of row vector v (n) and matrix m (n*k): res[j] = sum_i v[i]*m[i][j], len(res) = k.
+func VectorMatrixMul(v []*big.Int, m [][]*big.Int) []*big.Int {
+	return vectorMulOnMatrix(v, m)
+}
@@ -0,0 +1,83 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+var identityBytes = make([]byte, 64)
+
+// negPoint returns -p. The negation only flips the y coordinate, so use it instead of the multiplication by -1
+// to subtract the points: a - b = a + negPoint(b). The multiplication by the negated scalar x*(-Q) costs the same as
+// (-x)*Q, so keep ScalarMult(Q, minus(x)) there.
+func negPoint(p *bn256.G1) *bn256.G1 {
+	return new(bn256.G1).Neg(p)
+}
+
+// pointsEqual checks that a = b. The points are equal iff a - b is the identity, which is detected without
+// conversion to the affine coordinates, so the equal points are compared without field inversions.
+func pointsEqual(a, b *bn256.G1) bool {
+	diff := negPoint(b)
+	diff.Add(diff, a)
+	return bytes.Equal(diff.Marshal(), identityBytes)
+}
+
+// multiScalarMul returns sum(scalars[i]*points[i]) using the bucket (Pippenger) method: scalars are split into the
+// c-bit windows, and for every window the points are added into the buckets by the window digit. It requires about
+// (254/c)*(len(points) + 2^(c+1)) additions instead of ~381 additions and doublings per point for ScalarMult.
+// Missing scalars are treated as zeros.
+func multiScalarMul(points []*bn256.G1, scalars []*big.Int) *bn256.G1 {
+	c := 1
+	for (1 << (c + 2)) < len(points) {
+		c++
+	}
+
+	ks := make([]*big.Int, len(points))
+	for i := range ks {
+		ks[i] = new(big.Int).Mod(scalarAt(scalars, i), bn256.Order)
+	}
+
+	windows := (bn256.Order.BitLen() + c - 1) / c
+	res := new(bn256.G1).ScalarBaseMult(bint(0))
+
+	for w := windows - 1; w >= 0; w-- {
+		for b := 0; b < c; b++ {
+			res.Add(res, res)
+		}
+
+		buckets := make([]*bn256.G1, 1<<c)
+		for i := range ks {
+			d := 0
+			for b := 0; b < c; b++ {
+				d |= int(ks[i].Bit(w*c+b)) << b
+			}
+
+			if d == 0 {
+				continue
+			}
+
+			if buckets[d] == nil {
+				buckets[d] = new(bn256.G1).Set(points[i])
+			} else {
+				buckets[d].Add(buckets[d], points[i])
+			}
+		}
+
+		// sum(d*buckets[d]) as the sum of the running sums from the highest bucket
+		running := new(bn256.G1).ScalarBaseMult(bint(0))
+		for d := len(buckets) - 1; d > 0; d-- {
+			if buckets[d] != nil {
+				running.Add(running, buckets[d])
+			}
+
+			res.Add(res, running)
+		}
+	}
+
+	return res
+}
@@ -6,10 +6,16 @@ package bulletproofs
 
 import (
 	"crypto/rand"
+	"encoding/binary"
 	"github.com/cloudflare/bn256"
+	"golang.org/x/crypto/sha3"
+	"io"
 	"math/big"
 )
 
+// generatorsDST is the domain separation tag used for hashing to G1 in deterministic generators.
+var generatorsDST = []byte("BULLETPROOFS-BN256-G1-GENERATORS")
+
 func MustRandPoint() *bn256.G1 {
 	_, p, err := bn256.RandomG1(rand.Reader)
 	if err != nil {
@@ -25,3 +31,107 @@ func MustRandScalar() *big.Int {
 	}
 	return v
 }
+
+// blindingSource returns the source of the proof blinding scalars: uniform scalars read from r, MustRandScalar if r
+// is nil, or zero if hiding is disabled.
+func blindingSource(hiding bool, r io.Reader) func() *big.Int {
+	if !hiding {
+		return func() *big.Int { return bint(0) }
+	}
+
+	if r == nil {
+		return MustRandScalar
+	}
+
+	return func() *big.Int {
+		v, err := rand.Int(r, bn256.Order)
+		if err != nil {
+			panic(err)
+		}
+		return v
+	}
+}
+
+// DeterministicReader returns the endless stream of bytes derived from the seed and label:
+// Keccak256(seed || label || uint64_be(0)) || Keccak256(seed || label || uint64_be(1)) || ...
+// Use it as the ArithmeticCircuitPrivate.Rand to get the reproducible proofs, e.g. for the test vectors.
+// The proofs are zero-knowledge only while the seed is secret.
+func DeterministicReader(seed []byte, label string) io.Reader {
+	return &deterministicReader{prefix: append(append([]byte{}, seed...), label...)}
+}
+
+type deterministicReader struct {
+	prefix  []byte
+	counter uint64
+	block   []byte
+}
+
+func (r *deterministicReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.block) == 0 {
+			h := sha3.NewLegacyKeccak256()
+			h.Write(r.prefix)
+			h.Write(binary.BigEndian.AppendUint64(nil, r.counter))
+			r.block = h.Sum(nil)
+			r.counter++
+		}
+
+		c := copy(p[n:], r.block)
+		r.block = r.block[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// DeterministicPoints returns n points derived from the seed and label: HashG1(seed || label || uint32_be(i)).
+// Hash to curve guarantees that the discrete log relations between the points are unknown.
+func DeterministicPoints(seed []byte, label string, n int) []*bn256.G1 {
+	res := make([]*bn256.G1, n)
+	for i := range res {
+		res[i] = deterministicPoint(seed, label, i)
+	}
+	return res
+}
+
+// DeriveH returns the nothing-up-my-sleeve generator H for the plain Pedersen commitments v*g + s*H: the hash to curve
+// of the g encoding, DeterministicPoints(g.Marshal(), "H", 1)[0]. The discrete log of H relative to g is unknown.
+func DeriveH(g *bn256.G1) *bn256.G1 {
+	return deterministicPoint(g.Marshal(), "H", 0)
+}
+
+// deterministicPoint returns the i-th point of DeterministicPoints(seed, label, n).
+func deterministicPoint(seed []byte, label string, i int) *bn256.G1 {
+	msg := append(append(append([]byte{}, seed...), label...), binary.BigEndian.AppendUint32(nil, uint32(i))...)
+	return bn256.HashG1(msg, generatorsDST)
+}
+
+// DeterministicScalar returns scalar derived from the seed and label: Keccak256(seed || label) mod order.
+func DeterministicScalar(seed []byte, label string) *big.Int {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(seed)
+	h.Write([]byte(label))
+	return new(big.Int).Mod(new(big.Int).SetBytes(h.Sum(nil)), bn256.Order)
+}
+
+// HashToScalar returns the uniformly distributed scalar derived from data with the wide reduction: 64 bytes
+// Keccak256(0x00 || parts) || Keccak256(0x01 || parts) are reduced modulo the group order, so the bias is
+// negligible (about 2^-258). Every data part is prefixed with its uint32 big-endian length to make the encoding
+// unambiguous. This is the recommended primitive for the custom FiatShamirEngine implementations.
+func HashToScalar(data ...[]byte) *big.Int {
+	wide := make([]byte, 0, 64)
+
+	for _, prefix := range []byte{0, 1} {
+		h := sha3.NewLegacyKeccak256()
+		h.Write([]byte{prefix})
+
+		for _, d := range data {
+			h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(d))))
+			h.Write(d)
+		}
+
+		wide = h.Sum(wide)
+	}
+
+	return new(big.Int).Mod(new(big.Int).SetBytes(wide), bn256.Order)
+}
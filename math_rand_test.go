@@ -0,0 +1,64 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"github.com/cloudflare/bn256"
+	"io"
+	"testing"
+)
+
+func TestDeriveH(t *testing.T) {
+	g := MustRandPoint()
+
+	H := DeriveH(g)
+	if !pointsEqual(H, DeriveH(new(bn256.G1).Set(g))) {
+		panic("DeriveH should be deterministic")
+	}
+
+	if pointsEqual(H, g) {
+		panic("H should differ from G")
+	}
+
+	if pointsEqual(H, new(bn256.G1).ScalarBaseMult(bint(0))) {
+		panic("H should not be the identity")
+	}
+
+	if pointsEqual(H, DeriveH(MustRandPoint())) {
+		panic("H should depend on G")
+	}
+}
+
+func TestDeterministicReader(t *testing.T) {
+	// The stream does not depend on the read sizes
+	whole := make([]byte, 100)
+	if _, err := io.ReadFull(DeterministicReader([]byte("seed"), "label"), whole); err != nil {
+		panic(err)
+	}
+
+	r := DeterministicReader([]byte("seed"), "label")
+	parts := make([]byte, 0, 100)
+	for _, n := range []int{1, 31, 33, 35} {
+		part := make([]byte, n)
+		if _, err := io.ReadFull(r, part); err != nil {
+			panic(err)
+		}
+		parts = append(parts, part...)
+	}
+
+	if !bytes.Equal(whole, parts) {
+		panic("stream should be deterministic")
+	}
+
+	other := make([]byte, 100)
+	if _, err := io.ReadFull(DeterministicReader([]byte("seed"), "other"), other); err != nil {
+		panic(err)
+	}
+
+	if bytes.Equal(whole, other) {
+		panic("stream should depend on the label")
+	}
+}
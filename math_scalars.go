@@ -9,16 +9,48 @@ import (
 	"math/big"
 )
 
+// fieldOrder is the modulus of the scalar arithmetic helpers. It is always bn256.Order, except the builds with the
+// toyfield tag, see SetFieldModulus.
+var fieldOrder = bn256.Order
+
 func pow(x *big.Int, y int) *big.Int {
 	if y < 0 {
-		return new(big.Int).Exp(inv(x), big.NewInt(-int64(y)), bn256.Order)
+		return new(big.Int).Exp(inv(x), big.NewInt(-int64(y)), fieldOrder)
 	}
 
-	return new(big.Int).Exp(x, big.NewInt(int64(y)), bn256.Order)
+	return new(big.Int).Exp(x, big.NewInt(int64(y)), fieldOrder)
 }
 
 func inv(x *big.Int) *big.Int {
-	return new(big.Int).ModInverse(x, bn256.Order)
+	return new(big.Int).ModInverse(x, fieldOrder)
+}
+
+// batchInv returns the inverses of all xs with the Montgomery batch inversion: the prefix products are inverted
+// with the single Fermat exponentiation x^(p-2) and the inverses are recovered by 3(n-1) multiplications. Unlike
+// ModInverse the sequence of operations depends only on len(xs) and the public modulus, but the math/big operations
+// themselves are not constant-time, see digitReciprocals. All xs should be non-zero.
+func batchInv(xs []*big.Int) []*big.Int {
+	if len(xs) == 0 {
+		return nil
+	}
+
+	// prefix[i] = xs[0] * ... * xs[i]
+	prefix := make([]*big.Int, len(xs))
+	prefix[0] = new(big.Int).Mod(xs[0], fieldOrder)
+	for i := 1; i < len(xs); i++ {
+		prefix[i] = mul(prefix[i-1], xs[i])
+	}
+
+	acc := new(big.Int).Exp(prefix[len(xs)-1], new(big.Int).Sub(fieldOrder, bint(2)), fieldOrder)
+
+	res := make([]*big.Int, len(xs))
+	for i := len(xs) - 1; i > 0; i-- {
+		res[i] = mul(acc, prefix[i-1])
+		acc = mul(acc, xs[i])
+	}
+	res[0] = acc
+
+	return res
 }
 
 func minus(x *big.Int) *big.Int {
@@ -34,7 +66,7 @@ func powerOfTwo(x int) (p2 int) {
 }
 
 func bint(v int) *big.Int {
-	return new(big.Int).Mod(new(big.Int).SetInt64(int64(v)), bn256.Order)
+	return new(big.Int).Mod(new(big.Int).SetInt64(int64(v)), fieldOrder)
 }
 
 func bbool(v bool) *big.Int {
@@ -55,18 +87,18 @@ func zeroIfNil(x *big.Int) *big.Int {
 func add(x *big.Int, y *big.Int) *big.Int {
 	x = zeroIfNil(x)
 	y = zeroIfNil(y)
-	return new(big.Int).Mod(new(big.Int).Add(x, y), bn256.Order)
+	return new(big.Int).Mod(new(big.Int).Add(x, y), fieldOrder)
 }
 
 func sub(x *big.Int, y *big.Int) *big.Int {
 	x = zeroIfNil(x)
 	y = zeroIfNil(y)
-	return new(big.Int).Mod(new(big.Int).Sub(x, y), bn256.Order)
+	return new(big.Int).Mod(new(big.Int).Sub(x, y), fieldOrder)
 }
 
 func mul(x *big.Int, y *big.Int) *big.Int {
 	if x == nil || y == nil {
 		return bint(0)
 	}
-	return new(big.Int).Mod(new(big.Int).Mul(x, y), bn256.Order)
+	return new(big.Int).Mod(new(big.Int).Mul(x, y), fieldOrder)
 }
@@ -0,0 +1,28 @@
+//go:build toyfield
+
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// SetFieldModulus overrides the modulus of the scalar arithmetic and returns the previous one. Nil resets it to the
+// default bn256.Order. It is available only with the toyfield build tag and intended for research and testing: the
+// prover's relations can be checked over a small toy field, but proofs are not valid for the modulus that differs
+// from the group order. It is not safe for concurrent use with any other package function.
+func SetFieldModulus(m *big.Int) *big.Int {
+	prev := fieldOrder
+
+	if m == nil {
+		fieldOrder = bn256.Order
+	} else {
+		fieldOrder = new(big.Int).Set(m)
+	}
+
+	return prev
+}
@@ -0,0 +1,49 @@
+//go:build toyfield
+
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSetFieldModulus(t *testing.T) {
+	// x + y = r, x * y = z circuit from TestArithmeticCircuit over F_101
+	prev := SetFieldModulus(big.NewInt(101))
+	defer SetFieldModulus(prev)
+
+	public, private, _ := newTestCircuit()
+
+	x, y := bint(50), bint(60)
+	r, z := bint(9), bint(71) // 110 mod 101, 3000 mod 101
+
+	public.Al = []*big.Int{minus(r), minus(z)}
+
+	// Zero blinding and the challenges below 101 keep the inputs of f'(t) the same in both fields
+	private.V, private.Sv = [][]*big.Int{{x, y}}, []*big.Int{bint(0)}
+	private.Wl, private.Wr, private.Wo = []*big.Int{x}, []*big.Int{y}, []*big.Int{z, r}
+	private.NonHiding = true
+
+	prove := func() (failed bool) {
+		defer func() { failed = recover() != nil }()
+
+		checkCircuitPolynomial(public, newTestCircuitPolynomial(public, private, bint(2), bint(3), bint(5), bint(7)))
+		return
+	}
+
+	// The prover's f'(t) has the zero t^3 coefficient over F_101
+	if prove() {
+		panic("circuit polynomial should be satisfied over F_101")
+	}
+
+	// The same witness does not satisfy the circuit over the default field
+	SetFieldModulus(nil)
+
+	if !prove() {
+		panic("circuit polynomial should not be satisfied over the default field")
+	}
+}
@@ -27,35 +27,38 @@ func oneVector(n int) []*big.Int {
 	return res
 }
 
-func vectorAdd(a []*big.Int, b []*big.Int) []*big.Int {
-	for len(a) < len(b) {
-		a = append(a, bint(0))
+// Vectors of different lengths are treated as padded with zeros up to the longest one. Padding must not be done
+// with append: the argument can be a sub-slice of the caller's vector, so appending overwrites the caller's data.
+
+func scalarAt(v []*big.Int, i int) *big.Int {
+	if i < len(v) {
+		return v[i]
 	}
 
-	for len(b) < len(a) {
-		b = append(b, bint(0))
+	return bint(0)
+}
+
+func maxLen(a, b int) int {
+	if a > b {
+		return a
 	}
 
-	res := make([]*big.Int, len(a))
+	return b
+}
+
+func vectorAdd(a []*big.Int, b []*big.Int) []*big.Int {
+	res := make([]*big.Int, maxLen(len(a), len(b)))
 	for i := 0; i < len(res); i++ {
-		res[i] = add(a[i], b[i])
+		res[i] = add(scalarAt(a, i), scalarAt(b, i))
 	}
 
 	return res
 }
 
 func vectorSub(a []*big.Int, b []*big.Int) []*big.Int {
-	for len(a) < len(b) {
-		a = append(a, bint(0))
-	}
-
-	for len(b) < len(a) {
-		b = append(b, bint(0))
-	}
-
-	res := make([]*big.Int, len(a))
+	res := make([]*big.Int, maxLen(len(a), len(b)))
 	for i := 0; i < len(res); i++ {
-		res[i] = sub(a[i], b[i])
+		res[i] = sub(scalarAt(a, i), scalarAt(b, i))
 	}
 
 	return res
@@ -70,35 +73,19 @@ func vectorMulOnScalar(a []*big.Int, c *big.Int) []*big.Int {
 }
 
 func vectorMul(a []*big.Int, b []*big.Int) *big.Int {
-	for len(a) < len(b) {
-		a = append(a, bint(0))
-	}
-
-	for len(b) < len(a) {
-		b = append(b, bint(0))
-	}
-
 	res := big.NewInt(0)
-	for i := 0; i < len(a); i++ {
-		res = add(res, mul(a[i], b[i]))
+	for i := 0; i < maxLen(len(a), len(b)); i++ {
+		res = add(res, mul(scalarAt(a, i), scalarAt(b, i)))
 	}
 	return res
 }
 
 func weightVectorMul(a []*big.Int, b []*big.Int, mu *big.Int) *big.Int {
-	for len(a) < len(b) {
-		a = append(a, bint(0))
-	}
-
-	for len(b) < len(a) {
-		b = append(b, bint(0))
-	}
-
 	res := big.NewInt(0)
 	exp := new(big.Int).Set(mu)
 
-	for i := 0; i < len(a); i++ {
-		res = add(res, mul(mul(a[i], b[i]), exp))
+	for i := 0; i < maxLen(len(a), len(b)); i++ {
+		res = add(res, mul(mul(scalarAt(a, i), scalarAt(b, i)), exp))
 		exp = mul(exp, mu)
 	}
 	return res
@@ -111,33 +98,37 @@ func vectorPointScalarMul(g []*bn256.G1, a []*big.Int) *bn256.G1 {
 		return new(bn256.G1).ScalarBaseMult(bint(0))
 	}
 
-	for len(a) < len(g) {
-		a = append(a, bint(0))
-	}
-
-	res := new(bn256.G1).ScalarMult(g[0], a[0])
+	res := new(bn256.G1).ScalarMult(g[0], scalarAt(a, 0))
 	for i := 1; i < len(g); i++ {
-		res.Add(res, new(bn256.G1).ScalarMult(g[i], a[i]))
+		res.Add(res, new(bn256.G1).ScalarMult(g[i], scalarAt(a, i)))
 	}
 	return res
 }
 
-func vectorPointsAdd(a, b []*bn256.G1) []*bn256.G1 {
-	for len(a) < len(b) {
-		a = append(a, new(bn256.G1).ScalarBaseMult(bint(0)))
+func pointAt(v []*bn256.G1, i int) *bn256.G1 {
+	if i < len(v) {
+		return v[i]
 	}
 
-	for len(b) < len(a) {
-		b = append(b, new(bn256.G1).ScalarBaseMult(bint(0)))
-	}
+	return new(bn256.G1).ScalarBaseMult(bint(0))
+}
 
-	res := make([]*bn256.G1, len(a))
+func vectorPointsAdd(a, b []*bn256.G1) []*bn256.G1 {
+	res := make([]*bn256.G1, maxLen(len(a), len(b)))
 	for i := range res {
-		res[i] = new(bn256.G1).Add(a[i], b[i])
+		res[i] = new(bn256.G1).Add(pointAt(a, i), pointAt(b, i))
 	}
 	return res
 }
 
+// concatPoints returns the new slice a||b. Unlike append(a, b...) it never writes to the spare capacity of a,
+// which can be shared with the caller's public parameters.
+func concatPoints(a, b []*bn256.G1) []*bn256.G1 {
+	res := make([]*bn256.G1, 0, len(a)+len(b))
+	res = append(res, a...)
+	return append(res, b...)
+}
+
 func vectorPointMulOnScalar(g []*bn256.G1, a *big.Int) []*bn256.G1 {
 	res := make([]*bn256.G1, len(g))
 	for i := range res {
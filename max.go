@@ -0,0 +1,143 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// MaxNv returns the Nv dimension of the MaxPublic circuit for Nb bits: 1 + Nb.
+func MaxNv(Nb int) int {
+	return 1 + Nb
+}
+
+// ProveMax generates zero knowledge proof that the value m committed in maxCom is the maximum of the values a, b
+// committed in aCom and bCom: m - a and m - b lie in [0, 2^Nb) and (m - a) * (m - b) = 0. All commitments are
+// VCom = value*G + blinding*HVec[0]. The values a and b should lie in [0, 2^Nb) (e.g. proven with the range proofs),
+// otherwise the differences are taken modulo the group order. Use empty FiatShamirEngine for call.
+func ProveMax(public *MaxPublic, fs FiatShamirEngine, aCom, bCom, maxCom *bn256.G1, a, b, m, sa, sb, sm *big.Int) (*ArithmeticCircuitProof, error) {
+	if m.Cmp(a) < 0 || m.Cmp(b) < 0 || (m.Cmp(a) != 0 && m.Cmp(b) != 0) {
+		return nil, errors.New("invalid maximum: should be equal to the greatest of the values")
+	}
+
+	bound := new(big.Int).Lsh(bint(1), uint(public.Nb))
+
+	d1, d2 := new(big.Int).Sub(m, a), new(big.Int).Sub(m, b)
+	if d1.Cmp(bound) >= 0 || d2.Cmp(bound) >= 0 {
+		return nil, errors.New("invalid values: differences should be in [0, 2^Nb) range")
+	}
+
+	circuit := public.circuit()
+
+	bits := make([]*big.Int, 0, circuit.Nm)
+	for _, d := range []*big.Int{d1, d2} {
+		for i := 0; i < public.Nb; i++ {
+			bits = append(bits, bint(int(d.Bit(i))))
+		}
+	}
+
+	// The values are committed with zero padding, so the circuit commitments are equal to the values commitments
+	V := make([][]*big.Int, circuit.K)
+	for k := range V {
+		V[k] = zeroVector(circuit.Nv)
+	}
+
+	V[0][0], V[1][0], V[2][0] = a, b, m
+
+	private := &ArithmeticCircuitPrivate{
+		V:  V,
+		Sv: []*big.Int{sa, sb, sm, bint(0), bint(0)},
+		Wl: append(append([]*big.Int{}, bits...), d1),
+		Wr: append(append([]*big.Int{}, bits...), d2),
+		Wo: []*big.Int{m},
+	}
+
+	return ProveCircuit(circuit, []*bn256.G1{aCom, bCom, maxCom, ZeroCommitment(), ZeroCommitment()}, fs, private)
+}
+
+// VerifyMax verifies the proof that the value committed in maxCom is the maximum of the values committed in aCom and
+// bCom. If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyMax(public *MaxPublic, aCom, bCom, maxCom *bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	return VerifyCircuit(public.circuit(), []*bn256.G1{aCom, bCom, maxCom, ZeroCommitment(), ZeroCommitment()}, fs, proof)
+}
+
+// circuit builds the arithmetic circuit for m = max(a, b), where v[0][0] = a, v[1][0] = b, v[2][0] = m, v[3] and v[4]
+// are the ZeroCommitment values, wl = bits(m-a)||bits(m-b)||(m-a), wr = bits(m-a)||bits(m-b)||(m-b) and wo = m:
+// wl[i] * wr[i] = wl[i] for i < 2*Nb - every bit is boolean,
+// wl[2*Nb] * wr[2*Nb] = 0 - one of the differences is zero,
+// sum(wl[i] * 2^i) - wo + a = 0 and sum(wl[Nb+i] * 2^i) - wo + b = 0 - the differences are bits sums,
+// -wo + m = 0 - wo carries m to the rows above,
+// v[k][j] = 0 for k < 3, j > 0 - the values commitments have no other values.
+// The rows of the zero values v[3] and v[4] bind the witnesses:
+// wl[i] - wr[i] = 0 for i < 2*Nb, -wl[2*Nb] + sum(wl[i] * 2^i) = 0 and -wr[2*Nb] + sum(wl[Nb+i] * 2^i) = 0.
+func (p *MaxPublic) circuit() *ArithmeticCircuitPublic {
+	Nm, No, Nv, K := 2*p.Nb+1, 1, MaxNv(p.Nb), 5
+	Nw := Nm + Nm + No
+	wo := Nm + Nm
+
+	Wm := zeroMatrix(Nm, Nw)
+	for i := 0; i < 2*p.Nb; i++ {
+		Wm[i][i] = bint(1)
+	}
+
+	Wl := zeroMatrix(Nv*K, Nw)
+
+	// Rows of the committed values
+	rowA, rowB, rowM := Wl[0], Wl[Nv], Wl[2*Nv]
+	for i := 0; i < p.Nb; i++ {
+		rowA[i] = pow(bint(2), i)
+		rowB[p.Nb+i] = pow(bint(2), i)
+	}
+
+	rowA[wo], rowB[wo], rowM[wo] = bint(-1), bint(-1), bint(-1)
+
+	// Rows of the zero values
+	zero := Wl[3*Nv:]
+	for i := 0; i < 2*p.Nb; i++ {
+		zero[i][i] = bint(1)
+		zero[i][Nm+i] = bint(-1)
+	}
+
+	sum1, sum2 := zero[2*p.Nb], zero[2*p.Nb+1]
+	for i := 0; i < p.Nb; i++ {
+		sum1[i] = pow(bint(2), i)
+		sum2[p.Nb+i] = pow(bint(2), i)
+	}
+
+	sum1[2*p.Nb] = bint(-1)
+	sum2[Nm+2*p.Nb] = bint(-1)
+
+	res := &ArithmeticCircuitPublic{
+		Nm:   Nm,
+		Nl:   Nv * K,
+		Nv:   Nv,
+		Nw:   Nw,
+		No:   No,
+		K:    K,
+		G:    p.G,
+		GVec: p.GVec,
+		HVec: p.HVec,
+		Wm:   Wm,
+		Wl:   Wl,
+		Am:   zeroVector(Nm),
+		Al:   zeroVector(Nv * K),
+		Fl:   true,
+		Fm:   false,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
+	}
+
+	res.gTable.inherit(&p.gTable)
+	return res
+}
@@ -0,0 +1,142 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestMax(t *testing.T) {
+	Nb := 8
+	Nm, Nv := 2*Nb+1, MaxNv(Nb)
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 32)
+
+	public := &MaxPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nm],
+			HVec:  wnlaPublic.HVec[:Nv+StructuralSlots],
+			GVec_: wnlaPublic.GVec[Nm:],
+			HVec_: wnlaPublic.HVec[Nv+StructuralSlots:],
+		},
+		Nb: Nb,
+	}
+
+	a, b := bint(3), bint(5)
+	sa, sb, sm := MustRandScalar(), MustRandScalar(), MustRandScalar()
+
+	aCom, bCom, maxCom := public.CommitValue(a, sa), public.CommitValue(b, sb), public.CommitValue(b, sm)
+
+	proof, err := ProveMax(public, NewKeccakFS(), aCom, bCom, maxCom, a, b, b, sa, sb, sm)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyMax(public, aCom, bCom, maxCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// max(3, 5) = 4
+	wrongCom := public.CommitValue(bint(4), sm)
+	if err = VerifyMax(public, aCom, bCom, wrongCom, NewKeccakFS(), proof); err == nil {
+		panic("proof for the wrong maximum should fail")
+	}
+
+	if _, err = ProveMax(public, NewKeccakFS(), aCom, bCom, wrongCom, a, b, bint(4), sa, sb, sm); err == nil {
+		panic("wrong maximum should be rejected")
+	}
+
+	// Swapped and equal values
+	for _, c := range [][2]*big.Int{{b, a}, {b, b}} {
+		aCom, bCom, maxCom = public.CommitValue(c[0], sa), public.CommitValue(c[1], sb), public.CommitValue(b, sm)
+
+		if proof, err = ProveMax(public, NewKeccakFS(), aCom, bCom, maxCom, c[0], c[1], b, sa, sb, sm); err != nil {
+			panic(err)
+		}
+
+		if err = VerifyMax(public, aCom, bCom, maxCom, NewKeccakFS(), proof); err != nil {
+			panic(err)
+		}
+	}
+
+	if err = ValidatePartition(public.circuit()); err != nil {
+		panic(err)
+	}
+}
+
+func TestMaxForgedCommitment(t *testing.T) {
+	Nb := 8
+	Nm, Nv := 2*Nb+1, MaxNv(Nb)
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 32)
+
+	public := &MaxPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nm],
+			HVec:  wnlaPublic.HVec[:Nv+StructuralSlots],
+			GVec_: wnlaPublic.GVec[Nm:],
+			HVec_: wnlaPublic.HVec[Nv+StructuralSlots:],
+		},
+		Nb: Nb,
+	}
+
+	circuit := public.circuit()
+
+	// max(3, 5) = 4: m - b = -1 is the bits sum with the not boolean wl[Nb] = -1, wr[Nb] = 1, and the product
+	// of the differences is zero with wl[2*Nb] = wr[2*Nb] = 0. The values slots balance every linear row.
+	m := bint(4)
+
+	wl, wr := zeroVector(Nm), zeroVector(Nm)
+	wl[0], wr[0] = bint(1), bint(1)
+	wl[Nb], wr[Nb] = minus(bint(1)), bint(1)
+
+	w := append(append(append([]*big.Int{}, wl...), wr...), m)
+
+	private := &ArithmeticCircuitPrivate{
+		V:  make([][]*big.Int, circuit.K),
+		Sv: make([]*big.Int, circuit.K),
+		Wl: wl,
+		Wr: wr,
+		Wo: []*big.Int{m},
+	}
+
+	V := make([]*bn256.G1, circuit.K)
+	for k := range V {
+		private.V[k] = make([]*big.Int, circuit.Nv)
+		for j := range private.V[k] {
+			row := k*circuit.Nv + j
+			private.V[k][j] = minus(add(vectorMul(circuit.Wl[row], w), circuit.Al[row]))
+		}
+
+		private.Sv[k] = MustRandScalar()
+
+		var err error
+		if V[k], err = circuit.CommitCircuit(private.V[k], private.Sv[k]); err != nil {
+			panic(err)
+		}
+	}
+
+	// The verifier appends its own zero commitments
+	for k := 3; k < circuit.K; k++ {
+		V[k] = ZeroCommitment()
+	}
+
+	if private.V[0][0].Cmp(bint(3)) != 0 || private.V[1][0].Cmp(bint(5)) != 0 || private.V[2][0].Cmp(m) != 0 {
+		panic("forged commitments should commit to 3, 5 and 4")
+	}
+
+	proof, err := ProveCircuit(circuit, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyMax(public, V[0], V[1], V[2], NewKeccakFS(), proof); err == nil {
+		panic("proof for the forged commitments should fail")
+	}
+}
@@ -0,0 +1,176 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/cloudflare/bn256"
+)
+
+// ErrInvalidMerklePath is returned when the Merkle path does not lead from the commitment to the root.
+var ErrInvalidMerklePath = errors.New("invalid Merkle path")
+
+// MerkleRoot returns the Keccak256 Merkle root of the value commitments. The leaf is Keccak256(0x00 || V.Marshal()),
+// the node is Keccak256(0x01 || left || right). The last node of the level with the odd number of nodes is promoted
+// to the next level unchanged, so the different commitment lists never have the same root.
+func MerkleRoot(commitments []*bn256.G1) ([]byte, error) {
+	if len(commitments) == 0 {
+		return nil, errors.New("invalid commitments: should be non-empty")
+	}
+
+	level := make([][]byte, len(commitments))
+	for i := range commitments {
+		level[i] = merkleLeaf(commitments[i])
+	}
+
+	for len(level) > 1 {
+		level = merkleLevel(level)
+	}
+
+	return level[0], nil
+}
+
+// NewMerklePath returns the path from the index-th commitment to the MerkleRoot of the commitments.
+func NewMerklePath(commitments []*bn256.G1, index int) (*MerklePath, error) {
+	if index < 0 || index >= len(commitments) {
+		return nil, fmt.Errorf("invalid index: should be in [0, %d), got %d", len(commitments), index)
+	}
+
+	level := make([][]byte, len(commitments))
+	for i := range commitments {
+		level[i] = merkleLeaf(commitments[i])
+	}
+
+	path := &MerklePath{Index: index, Size: len(commitments)}
+
+	for i := index; len(level) > 1; i /= 2 {
+		if sibling := i ^ 1; sibling < len(level) {
+			path.Siblings = append(path.Siblings, level[sibling])
+		}
+
+		level = merkleLevel(level)
+	}
+
+	return path, nil
+}
+
+// VerifyMerklePath checks that the path leads from the commitment V to the root.
+// Returns ErrInvalidMerklePath if it does not.
+func VerifyMerklePath(root []byte, V *bn256.G1, path *MerklePath) error {
+	if path == nil || path.Size <= 0 || path.Index < 0 || path.Index >= path.Size {
+		return ErrInvalidMerklePath
+	}
+
+	node, siblings := merkleLeaf(V), path.Siblings
+
+	for i, size := path.Index, path.Size; size > 1; i, size = i/2, (size+1)/2 {
+		if i^1 >= size {
+			// the last node of the odd level is promoted
+			continue
+		}
+
+		if len(siblings) == 0 {
+			return ErrInvalidMerklePath
+		}
+
+		if i&1 == 0 {
+			node = merkleNode(node, siblings[0])
+		} else {
+			node = merkleNode(siblings[0], node)
+		}
+
+		siblings = siblings[1:]
+	}
+
+	if len(siblings) != 0 || !bytes.Equal(node, root) {
+		return ErrInvalidMerklePath
+	}
+
+	return nil
+}
+
+// BindMerkleRoot absorbs the hash of the Merkle root of the value commitments into the transcript, so the proof
+// generated with this transcript is valid only for the same root. ProveRangeMerkle and VerifyRangeMerkle call it.
+func BindMerkleRoot(fs FiatShamirEngine, root []byte) {
+	fs.AddNumber(HashToScalar([]byte("merkle-root"), root))
+}
+
+// ProveRangeMerkle generates the range proof for the index-th of the commitments bound to their MerkleRoot. The
+// proof carries the commitment and its Merkle path, so the verifier needs only the root. The commitment should be
+// public.CommitValue(private.X, private.S). Use empty FiatShamirEngine for call.
+func ProveRangeMerkle(public *ReciprocalPublic, fs FiatShamirEngine, commitments []*bn256.G1, index int, private *ReciprocalPrivate) (*MerkleRangeProof, error) {
+	path, err := NewMerklePath(commitments, index)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pointsEqual(commitments[index], public.CommitValue(private.X, private.S)) {
+		return nil, fmt.Errorf("invalid commitment %d: does not open to the private value", index)
+	}
+
+	root, err := MerkleRoot(commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	BindMerkleRoot(fs, root)
+
+	proof, err := ProveRange(public, fs, private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MerkleRangeProof{
+		ReciprocalProof: proof,
+		VCom:            commitments[index],
+		Path:            path,
+	}, nil
+}
+
+// VerifyRangeMerkle verifies the proof generated by ProveRangeMerkle against the Merkle root of the value
+// commitments. The commitment carried by the proof is checked with its Merkle path first.
+// If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyRangeMerkle(public *ReciprocalPublic, root []byte, fs FiatShamirEngine, proof *MerkleRangeProof) error {
+	if proof == nil || proof.ReciprocalProof == nil || proof.VCom == nil {
+		return errors.New("invalid proof: should have the range proof and the commitment")
+	}
+
+	if err := VerifyMerklePath(root, proof.VCom, proof.Path); err != nil {
+		return err
+	}
+
+	BindMerkleRoot(fs, root)
+	return VerifyRange(public, proof.VCom, fs, proof.ReciprocalProof)
+}
+
+func merkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i+1 < len(level); i += 2 {
+		next = append(next, merkleNode(level[i], level[i+1]))
+	}
+
+	if len(level)%2 == 1 {
+		next = append(next, level[len(level)-1])
+	}
+
+	return next
+}
+
+func merkleLeaf(V *bn256.G1) []byte {
+	h := newKeccakState()
+	h.Write([]byte{0})
+	h.Write(V.Marshal())
+	return h.Sum(nil)
+}
+
+func merkleNode(left, right []byte) []byte {
+	h := newKeccakState()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
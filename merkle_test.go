@@ -0,0 +1,106 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestMerklePath(t *testing.T) {
+	for size := 1; size <= 7; size++ {
+		commitments := make([]*bn256.G1, size)
+		for i := range commitments {
+			commitments[i] = MustRandPoint()
+		}
+
+		root, err := MerkleRoot(commitments)
+		if err != nil {
+			panic(err)
+		}
+
+		for i := range commitments {
+			path, err := NewMerklePath(commitments, i)
+			if err != nil {
+				panic(err)
+			}
+
+			if err = VerifyMerklePath(root, commitments[i], path); err != nil {
+				panic(err)
+			}
+
+			if err = VerifyMerklePath(root, MustRandPoint(), path); err == nil {
+				panic("path should be rejected for the other commitment")
+			}
+		}
+
+		if size > 1 {
+			shorter, _ := MerkleRoot(commitments[:size-1])
+			if bytes.Equal(root, shorter) {
+				panic("roots of the different lists should differ")
+			}
+		}
+	}
+}
+
+func TestRangeProofMerkle(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	commitments := []*bn256.G1{
+		public.CommitValue(bint(1), MustRandScalar()),
+		public.CommitValue(bint(2), MustRandScalar()),
+		public.CommitValue(private.X, private.S),
+		public.CommitValue(bint(3), MustRandScalar()),
+	}
+
+	root, err := MerkleRoot(commitments)
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := ProveRangeMerkle(public, NewKeccakFS(), commitments, 2, private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRangeMerkle(public, root, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// The same proof is not valid for the other root
+	otherRoot, _ := MerkleRoot(append([]*bn256.G1{MustRandPoint()}, commitments[1:]...))
+	if err = VerifyRangeMerkle(public, otherRoot, NewKeccakFS(), proof); err == nil {
+		panic("proof should be rejected for the other root")
+	}
+
+	// The path of the other commitment does not lead from VCom to the root
+	otherPath, _ := NewMerklePath(commitments, 1)
+	if err = VerifyRangeMerkle(public, root, NewKeccakFS(), &MerkleRangeProof{ReciprocalProof: proof.ReciprocalProof, VCom: proof.VCom, Path: otherPath}); err == nil {
+		panic("proof with the wrong path should be rejected")
+	}
+
+	// The range proof is bound to the root: it does not verify without it
+	if err = VerifyRange(public, proof.VCom, NewKeccakFS(), proof.ReciprocalProof); err == nil {
+		panic("proof should be bound to the root")
+	}
+
+	if _, err = ProveRangeMerkle(public, NewKeccakFS(), commitments, 1, private); err == nil {
+		panic("commitment that does not open to the private value should be rejected")
+	}
+}
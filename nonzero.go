@@ -0,0 +1,79 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// ProveNonZero generates zero knowledge proof that the value x committed in VCom = x*G + s*HVec[0] is not zero
+// by proving knowledge of the inverse x^-1 such that x * x^-1 = 1. Use empty FiatShamirEngine for call.
+func ProveNonZero(public *NonZeroPublic, fs FiatShamirEngine, x, s *big.Int) (*ArithmeticCircuitProof, error) {
+	x = new(big.Int).Mod(x, bn256.Order)
+	if x.Sign() == 0 {
+		return nil, errors.New("value is zero")
+	}
+
+	circuit := public.circuit()
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{x}},
+		Sv: []*big.Int{s},
+		Wl: []*big.Int{x},
+		Wr: []*big.Int{inv(x)},
+		Wo: []*big.Int{bint(1)},
+	}
+
+	V, err := circuit.CommitCircuit(private.V[0], private.Sv[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return ProveCircuit(circuit, []*bn256.G1{V}, fs, private)
+}
+
+// VerifyNonZero verifies the proof that the value committed in VCom is not zero. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyNonZero(public *NonZeroPublic, VCom *bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	return VerifyCircuit(public.circuit(), []*bn256.G1{VCom}, fs, proof)
+}
+
+// circuit builds the arithmetic circuit with one multiplication gate: wl * wr = 1, wl = v.
+// The output wo is not constrained.
+func (p *NonZeroPublic) circuit() *ArithmeticCircuitPublic {
+	Nm, No, Nv, K := 1, 1, 1, 1
+
+	res := &ArithmeticCircuitPublic{
+		Nm:   Nm,
+		Nl:   Nv * K,
+		Nv:   Nv,
+		Nw:   Nm + Nm + No,
+		No:   No,
+		K:    K,
+		G:    p.G,
+		GVec: p.GVec,
+		HVec: p.HVec,
+		Wm:   [][]*big.Int{{bint(0), bint(0), bint(0)}},
+		Wl:   [][]*big.Int{{bint(-1), bint(0), bint(0)}},
+		Am:   []*big.Int{bint(1)},
+		Al:   []*big.Int{bint(0)},
+		Fl:   true,
+		Fm:   false,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
+	}
+
+	res.gTable.inherit(&p.gTable)
+	return res
+}
@@ -0,0 +1,72 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestNonZero(t *testing.T) {
+	wnlaPublic := NewWeightNormLinearPublic(16, 1)
+
+	public := &NonZeroPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:1],
+			HVec:  wnlaPublic.HVec[:10],
+			GVec_: wnlaPublic.GVec[1:],
+			HVec_: wnlaPublic.HVec[10:],
+		},
+	}
+
+	x := bint(7)
+	s := MustRandScalar()
+
+	VCom := public.CommitValue(x, s)
+
+	proof, err := ProveNonZero(public, NewKeccakFS(), x, s)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyNonZero(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Proof does not fit the commitment to zero
+	if err := VerifyNonZero(public, public.CommitValue(bint(0), s), NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid for the commitment to zero")
+	}
+
+	if _, err := ProveNonZero(public, NewKeccakFS(), bint(0), s); err == nil {
+		panic("proof for zero value should not be generated")
+	}
+
+	if _, err := ProveNonZero(public, NewKeccakFS(), new(big.Int).Set(bn256.Order), s); err == nil {
+		panic("proof for zero value should not be generated")
+	}
+
+	// Dishonest prover uses arbitrary inverse for zero value
+	circuit := public.circuit()
+	VCom = public.CommitValue(bint(0), s)
+
+	proof, err = ProveCircuit(circuit, []*bn256.G1{VCom}, NewKeccakFS(), &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{{bint(0)}},
+		Sv: []*big.Int{s},
+		Wl: []*big.Int{bint(0)},
+		Wr: []*big.Int{bint(1)},
+		Wo: []*big.Int{bint(1)},
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyNonZero(public, VCom, NewKeccakFS(), proof); err == nil {
+		panic("proof for zero value should fail")
+	}
+}
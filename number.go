@@ -4,7 +4,11 @@
 // license that can be found in the LICENSE file.
 package bulletproofs
 
-import "math/big"
+import (
+	"crypto/subtle"
+	"errors"
+	"math/big"
+)
 
 func UInt64Hex(x uint64) []*big.Int {
 	resp := make([]*big.Int, 16)
@@ -15,13 +19,139 @@ func UInt64Hex(x uint64) []*big.Int {
 	return resp
 }
 
-func HexMapping(digits []*big.Int) []*big.Int {
-	resp := zeroVector(16)
+// BytesToDigits returns the base-256 digits of the value encoded by b in big-endian order. Digits are ordered from
+// the least significant one, as for UInt64Hex, so ReconstructFromDigits(BytesToDigits(b), 256) returns the value of b.
+//
+// The digits can not be proven with the reciprocal range proof directly: the base Np = 256 requires Nd >= 255 (see
+// ReciprocalGeneratorCount), while the value of b has only len(b) digits. Prove the value in base 16 instead: pass
+// new(big.Int).SetBytes(b) to ProveRangeFresh with Np = 16 and Nd = 2*len(b), or take the nibbles and multiplicities
+// from ReciprocalWitness. The value should be below bn256.Order, and the range [0, 2^256) of all 32 bytes covers
+// the whole scalar field, so prove the shorter range that the application needs.
+func BytesToDigits(b []byte) []*big.Int {
+	resp := make([]*big.Int, len(b))
+	for i := range b {
+		resp[i] = big.NewInt(int64(b[len(b)-1-i]))
+	}
+	return resp
+}
+
+// UInt64Digits returns Nd digits of x in the given base ordered from the least significant one.
+// The higher digits of x that do not fit into Nd digits are dropped.
+func UInt64Digits(x uint64, base int, Nd int) []*big.Int {
+	resp := make([]*big.Int, Nd)
+	for i := 0; i < Nd; i++ {
+		resp[i] = big.NewInt(int64(x % uint64(base)))
+		x /= uint64(base)
+	}
+	return resp
+}
+
+// valueDigits returns Nd digits of x in the given base ordered from the least significant one.
+// Returns error if x does not lie in [0, base^Nd).
+func valueDigits(x *big.Int, base int, Nd int) ([]*big.Int, error) {
+	if base < 2 {
+		return nil, errors.New("invalid base: should be at least 2")
+	}
+
+	if x.Sign() < 0 || x.Cmp(new(big.Int).Exp(bint(base), bint(Nd), nil)) >= 0 {
+		return nil, errors.New("invalid value: should be in [0, base^Nd) range")
+	}
+
+	resp := make([]*big.Int, Nd)
+	rest := new(big.Int).Set(x)
+	for i := range resp {
+		resp[i] = new(big.Int)
+		rest.DivMod(rest, bint(base), resp[i])
+	}
+
+	return resp, nil
+}
+
+// ReciprocalWitness returns the Nd digits of x in the given base ordered from the least significant one and their
+// multiplicities, as required by the Digits and M fields of ReciprocalPrivate with Np = base.
+// Returns error if x does not lie in [0, base^Nd).
+func ReciprocalWitness(x *big.Int, base, Nd int) (digits, multiplicities []*big.Int, err error) {
+	if digits, err = valueDigits(x, base, Nd); err != nil {
+		return nil, nil, err
+	}
+
+	return digits, DigitsMapping(digits, base), nil
+}
+
+// DigitsMapping returns the multiplicities of the digits in the given base: resp[d] is the count of digits equal to d.
+func DigitsMapping(digits []*big.Int, base int) []*big.Int {
+	counts := make([]int, base)
 
+	// Every digit is compared with every pole instead of indexing counts by the digit, so the memory accesses do not
+	// depend on the digits. The running time still may, see digitReciprocals.
 	for _, d := range digits {
-		dint := d.Int64()
-		resp[dint] = add(resp[dint], bint(1))
+		dint := int32(d.Int64())
+		for j := range counts {
+			counts[j] += subtle.ConstantTimeEq(dint, int32(j))
+		}
+	}
+
+	resp := make([]*big.Int, base)
+	for j := range resp {
+		resp[j] = bint(counts[j])
 	}
 
 	return resp
 }
+
+// digitReciprocals returns the poles r[j] = 1/(digits[j] + e) of the reciprocal argument.
+//
+// The witness computation avoids the value dependent operations and memory accesses on the secret digits: the poles
+// are inverted with batchInv, that performs the same Fermat exponentiation and multiplications for any values instead
+// of the ModInverse Euclid loop, and DigitsMapping scans all the poles for every digit instead of indexing by it. It
+// is still not constant-time: math/big reads the digits with Int64 and runs add, mul, Mod and Exp in the time that
+// depends on the operand values, so the running time may leak information about the secret digits.
+func digitReciprocals(digits []*big.Int, e *big.Int) []*big.Int {
+	poles := make([]*big.Int, len(digits))
+	for j := range poles {
+		poles[j] = add(digits[j], e)
+	}
+
+	return batchInv(poles)
+}
+
+// OptimalBase returns the power of two base and digits count Nd for the range proof of bitLen-bit values that
+// minimize the generators count. The reciprocal circuit uses Nd GVec and Nd+1+9 HVec generators, while the Np = base
+// multiplicities are committed in the Nd+1 value slots, so only the bases with base <= Nd+1 are considered.
+// The smaller base is returned for the same generators count.
+func OptimalBase(bitLen int) (base, Nd int) {
+	if bitLen < 1 {
+		bitLen = 1
+	}
+
+	best := -1
+	for b := 1; b <= bitLen; b++ {
+		nd := (bitLen + b - 1) / b
+		if (nd+1)>>b == 0 {
+			continue
+		}
+
+		if count := nd + nd + 1 + StructuralSlots; best < 0 || count < best {
+			best, base, Nd = count, 1<<b, nd
+		}
+	}
+
+	return base, Nd
+}
+
+func HexMapping(digits []*big.Int) []*big.Int {
+	return DigitsMapping(digits, 16)
+}
+
+// ReconstructFromDigits returns the value encoded by digits in the given base.
+// Digits are ordered from the least significant one (as returned by UInt64Hex).
+func ReconstructFromDigits(digits []*big.Int, base int) *big.Int {
+	res := bint(0)
+	b := bint(base)
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		res = add(mul(res, b), digits[i])
+	}
+
+	return res
+}
@@ -6,6 +6,7 @@ package bulletproofs
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 )
 
@@ -14,3 +15,135 @@ func TestUInt64Hex(t *testing.T) {
 	fmt.Println(UInt64Hex(x))             // [0 4 5 0 15 4 11 10 0 4 5 0 15 4 11 10]
 	fmt.Println(HexMapping(UInt64Hex(x))) // [4 0 0 0 4 2 0 0 0 0 2 2 0 0 0 2]
 }
+
+func TestReconstructFromDigits(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+
+	if ReconstructFromDigits(UInt64Hex(x), 16).Cmp(new(big.Int).SetUint64(x)) != 0 {
+		panic("test failed")
+	}
+}
+
+func TestBytesToDigits(t *testing.T) {
+	b := make([]byte, 32)
+	for i := range b {
+		b[i] = byte(i*7 + 1)
+	}
+
+	digits := BytesToDigits(b)
+	if len(digits) != 32 {
+		panic("test failed")
+	}
+
+	if digits[0].Cmp(bint(int(b[31]))) != 0 {
+		panic("digits should start from the least significant byte")
+	}
+
+	if ReconstructFromDigits(digits, 256).Cmp(new(big.Int).SetBytes(b)) != 0 {
+		panic("test failed")
+	}
+
+	// The base 256 does not fit into the 32 digits
+	public256 := newTestReciprocalPublic(t, len(b), 256)
+	if _, _, _, err := ProveRangeFresh(public256, NewKeccakFS(), new(big.Int).SetBytes(b)); err == nil {
+		panic("base 256 should be rejected for 32 digits")
+	}
+
+	// The same value is proven with the 64 nibbles in base 16
+	public := newTestReciprocalPublic(t, 2*len(b), 16)
+
+	proof, V, _, err := ProveRangeFresh(public, NewKeccakFS(), new(big.Int).SetBytes(b))
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRange(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
+
+func TestOptimalBase(t *testing.T) {
+	// Generators count Nd + (Nd+1+9) for the 64-bit values
+	counts := map[int]int{}
+	for _, b := range []struct{ base, Nd int }{{2, 64}, {4, 32}, {8, 22}, {16, 16}} {
+		counts[b.base] = 2*b.Nd + 10
+	}
+
+	base, Nd := OptimalBase(64)
+	if base != 16 || Nd != 16 {
+		panic(fmt.Sprintf("unexpected base %d and Nd %d", base, Nd))
+	}
+
+	for b, count := range counts {
+		if count < 2*Nd+10 {
+			panic(fmt.Sprintf("base %d uses less generators", b))
+		}
+	}
+
+	// Base 16 requires at least 15 digits, so the smaller base is used
+	if base, Nd = OptimalBase(8); base != 4 || Nd != 4 {
+		panic(fmt.Sprintf("unexpected base %d and Nd %d", base, Nd))
+	}
+
+	if base, Nd = OptimalBase(1); base != 2 || Nd != 1 {
+		panic(fmt.Sprintf("unexpected base %d and Nd %d", base, Nd))
+	}
+}
+
+func TestUInt64Digits(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+
+	for _, b := range []struct{ base, Nd int }{{2, 64}, {4, 32}, {16, 16}} {
+		digits := UInt64Digits(x, b.base, b.Nd)
+		if ReconstructFromDigits(digits, b.base).Cmp(new(big.Int).SetUint64(x)) != 0 {
+			panic("test failed")
+		}
+
+		if vectorMul(DigitsMapping(digits, b.base), oneVector(b.base)).Cmp(bint(b.Nd)) != 0 {
+			panic("multiplicities should sum up to the digits count")
+		}
+	}
+
+	if vectorSub(UInt64Digits(x, 16, 16), UInt64Hex(x))[5].Sign() != 0 {
+		panic("test failed")
+	}
+}
+
+func TestDigitReciprocals(t *testing.T) {
+	e := MustRandScalar()
+
+	for _, digits := range [][]*big.Int{
+		UInt64Hex(0xab4f0540ab4f0540),
+		UInt64Hex(0),
+		UInt64Digits(0xdeadbeef, 256, 4),
+		{bint(7)},
+		nil,
+	} {
+		r := digitReciprocals(digits, e)
+		if len(r) != len(digits) {
+			panic("test failed")
+		}
+
+		for j := range digits {
+			if r[j].Cmp(inv(add(digits[j], e))) != 0 {
+				panic(fmt.Sprintf("reciprocal %d does not match the straightforward computation", j))
+			}
+		}
+	}
+
+	digits := UInt64Digits(0xdeadbeef, 256, 4)
+
+	m := DigitsMapping(digits, 256)
+	for d := range m {
+		count := 0
+		for _, digit := range digits {
+			if digit.Int64() == int64(d) {
+				count++
+			}
+		}
+
+		if m[d].Cmp(bint(count)) != 0 {
+			panic(fmt.Sprintf("multiplicity of %d does not match the straightforward count", d))
+		}
+	}
+}
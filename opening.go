@@ -0,0 +1,55 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// ProveOpening generates the zero knowledge proof of knowledge of the opening x, s of the value commitment
+// VCom = x*G + s*HVec[0] (Schnorr-style Sigma protocol). Unlike the range proof it does not prove anything about
+// the value, but requires only a few scalar multiplications. Use empty FiatShamirEngine for call.
+func ProveOpening(public *ReciprocalPublic, fs FiatShamirEngine, x, s *big.Int) *OpeningProof {
+	kx, ks := MustRandScalar(), MustRandScalar()
+	T := public.CommitValue(kx, ks)
+
+	c := openingChallenge(fs, public, public.CommitValue(x, s), T)
+
+	return &OpeningProof{
+		T:  T,
+		Zx: add(kx, mul(c, x)),
+		Zs: add(ks, mul(c, s)),
+	}
+}
+
+// VerifyOpening verifies the proof generated by ProveOpening for the value commitment VCom. If err is nil then proof
+// is valid. Use empty FiatShamirEngine for call.
+func VerifyOpening(public *ReciprocalPublic, VCom *bn256.G1, fs FiatShamirEngine, proof *OpeningProof) error {
+	if proof.T == nil || proof.Zx == nil || proof.Zs == nil {
+		return errors.New("invalid proof: missing elements")
+	}
+
+	c := openingChallenge(fs, public, VCom, proof.T)
+
+	// Zx*G + Zs*HVec[0] = T + c*VCom
+	right := new(bn256.G1).ScalarMult(VCom, c)
+	right.Add(right, proof.T)
+
+	if !pointsEqual(public.CommitValue(proof.Zx, proof.Zs), right) {
+		return errors.New("failed to verify proof")
+	}
+
+	return nil
+}
+
+func openingChallenge(fs FiatShamirEngine, public *ReciprocalPublic, VCom, T *bn256.G1) *big.Int {
+	fs.AddPoint(public.G)
+	fs.AddPoint(public.HVec[0])
+	fs.AddPoint(VCom)
+	fs.AddPoint(T)
+	return fs.GetChallenge()
+}
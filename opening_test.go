@@ -0,0 +1,39 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"testing"
+)
+
+func TestOpening(t *testing.T) {
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	x, s := bint(42), MustRandScalar()
+	VCom := public.CommitValue(x, s)
+
+	proof := ProveOpening(public, NewKeccakFS(), x, s)
+
+	if err := VerifyOpening(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Proof for the wrong value
+	wrong := ProveOpening(public, NewKeccakFS(), bint(43), s)
+	if err := VerifyOpening(public, VCom, NewKeccakFS(), wrong); err == nil {
+		panic("proof for the wrong value should fail")
+	}
+
+	if err := VerifyOpening(public, new(bn256.G1).Add(VCom, public.G), NewKeccakFS(), proof); err == nil {
+		panic("proof for the other commitment should fail")
+	}
+
+	corrupted := *proof
+	corrupted.Zx = add(proof.Zx, bint(1))
+	if err := VerifyOpening(public, VCom, NewKeccakFS(), &corrupted); err == nil {
+		panic("corrupted proof should fail")
+	}
+}
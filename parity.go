@@ -0,0 +1,82 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// ProveParity generates zero knowledge proof that the value x committed in VCom = x*G + s*HVec[0] lies in [0, 2^Nb)
+// and its least significant bit is equal to parity (0 - even, 1 - odd). The bits circuit is reused, so use
+// BitsPublic.CommitValue to create the commitment. Use empty FiatShamirEngine for call.
+func ProveParity(public *BitsPublic, fs FiatShamirEngine, x *big.Int, s *big.Int, parity int) (*ArithmeticCircuitProof, error) {
+	if parity != 0 && parity != 1 {
+		return nil, errors.New("invalid parity: should be 0 or 1")
+	}
+
+	return ProveBitAt(public, fs, x, s, 0, parity)
+}
+
+// VerifyParity verifies the proof that the value committed in VCom lies in [0, 2^Nb) and has the given parity.
+// If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyParity(public *BitsPublic, VCom *bn256.G1, fs FiatShamirEngine, parity int, proof *ArithmeticCircuitProof) error {
+	if parity != 0 && parity != 1 {
+		return errors.New("invalid parity: should be 0 or 1")
+	}
+
+	return VerifyBitAt(public, VCom, fs, 0, parity, proof)
+}
+
+// ProveBitAt generates zero knowledge proof that the value x committed in VCom = x*G + s*HVec[0] lies in [0, 2^Nb)
+// and its bit at the position (0 - the least significant one) is equal to bitValue. Other bits stay hidden.
+// The bits circuit is reused, so use BitsPublic.CommitValue to create the commitment. Use empty FiatShamirEngine for call.
+func ProveBitAt(public *BitsPublic, fs FiatShamirEngine, x *big.Int, s *big.Int, position int, bitValue int) (*ArithmeticCircuitProof, error) {
+	circuit, err := public.bitAtCircuit(position, bitValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if x.Sign() < 0 || x.BitLen() > public.Nb {
+		return nil, errors.New("invalid value: should be in [0, 2^Nb) range")
+	}
+
+	bits := make([]*big.Int, public.Nb)
+	for i := range bits {
+		bits[i] = bint(int(x.Bit(i)))
+	}
+
+	return public.proveCircuit(circuit, fs, x, s, bits)
+}
+
+// VerifyBitAt verifies the proof that the value committed in VCom lies in [0, 2^Nb) and has the bit at the position
+// equal to bitValue. If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyBitAt(public *BitsPublic, VCom *bn256.G1, fs FiatShamirEngine, position int, bitValue int, proof *ArithmeticCircuitProof) error {
+	circuit, err := public.bitAtCircuit(position, bitValue)
+	if err != nil {
+		return err
+	}
+
+	return public.verifyCircuit(circuit, VCom, fs, proof)
+}
+
+// bitAtCircuit builds the bits decomposition circuit where the equality wl[i] - wr[i] + v[1][i] = 0 for i = position
+// is replaced with wl[i] - bitValue + v[1][i] = 0 over the ZeroCommitment v[1]. The right witness wr[i] stays constrained by wl[i] * wr[i] = wl[i] only, which is enough,
+// because the bits sum uses the left witnesses.
+func (p *BitsPublic) bitAtCircuit(position int, bitValue int) (*ArithmeticCircuitPublic, error) {
+	if bitValue != 0 && bitValue != 1 {
+		return nil, errors.New("invalid bit value: should be 0 or 1")
+	}
+
+	if position < 0 || position >= p.Nb {
+		return nil, errors.New("invalid position: should be in [0, Nb) range")
+	}
+
+	res := p.circuit()
+	res.Wl[res.Nv+position][res.Nm+position] = bint(0)
+	res.Al[res.Nv+position] = bint(-bitValue)
+	return res, nil
+}
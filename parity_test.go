@@ -0,0 +1,171 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestParity(t *testing.T) {
+	Nb := 4
+
+	wnlaPublic := NewWeightNormLinearPublic(16, 4)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+9],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+9:],
+		},
+		Nb: Nb,
+	}
+
+	x := bint(14)
+	s := MustRandScalar()
+	VCom := public.CommitValue(x, s)
+
+	proof, err := ProveParity(public, NewKeccakFS(), x, s, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyParity(public, VCom, NewKeccakFS(), 0, proof); err != nil {
+		panic(err)
+	}
+
+	// Proof of evenness does not prove oddness
+	if err := VerifyParity(public, VCom, NewKeccakFS(), 1, proof); err == nil {
+		panic("verification of the wrong parity should fail")
+	}
+
+	// 14 is not odd
+	if proof, err = ProveParity(public, NewKeccakFS(), x, s, 1); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyParity(public, VCom, NewKeccakFS(), 1, proof); err == nil {
+		panic("proof of oddness for 14 should fail")
+	}
+
+	x = bint(13)
+	if proof, err = ProveParity(public, NewKeccakFS(), x, s, 1); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyParity(public, public.CommitValue(x, s), NewKeccakFS(), 1, proof); err != nil {
+		panic(err)
+	}
+
+	if _, err := ProveParity(public, NewKeccakFS(), bint(16), s, 0); err == nil {
+		panic("value out of range should be rejected")
+	}
+}
+
+func TestBitAt(t *testing.T) {
+	Nb := 4
+
+	wnlaPublic := NewWeightNormLinearPublic(16, 4)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+StructuralSlots],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+StructuralSlots:],
+		},
+		Nb: Nb,
+	}
+
+	// 14 = 0b1110
+	x := bint(14)
+	s := MustRandScalar()
+	VCom := public.CommitValue(x, s)
+
+	proof, err := ProveBitAt(public, NewKeccakFS(), x, s, 3, 1)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyBitAt(public, VCom, NewKeccakFS(), 3, 1, proof); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyBitAt(public, VCom, NewKeccakFS(), 3, 0, proof); err == nil {
+		panic("verification of the wrong bit value should fail")
+	}
+
+	if err = VerifyBitAt(public, VCom, NewKeccakFS(), 2, 1, proof); err == nil {
+		panic("verification for the other position should fail")
+	}
+
+	// Bit 3 of 14 is not 0
+	if proof, err = ProveBitAt(public, NewKeccakFS(), x, s, 3, 0); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyBitAt(public, VCom, NewKeccakFS(), 3, 0, proof); err == nil {
+		panic("proof of the wrong bit value should fail")
+	}
+
+	if _, err = ProveBitAt(public, NewKeccakFS(), x, s, Nb, 0); err == nil {
+		panic("position out of range should be rejected")
+	}
+}
+
+func TestParityForgedCommitment(t *testing.T) {
+	Nb := 4
+
+	wnlaPublic := NewWeightNormLinearPublic(16, 4)
+
+	public := &BitsPublic{
+		ValueCommitmentPublic: ValueCommitmentPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:Nb],
+			HVec:  wnlaPublic.HVec[:Nb+1+StructuralSlots],
+			GVec_: wnlaPublic.GVec[Nb:],
+			HVec_: wnlaPublic.HVec[Nb+1+StructuralSlots:],
+		},
+		Nb: Nb,
+	}
+
+	circuit, err := public.bitAtCircuit(0, 1)
+	if err != nil {
+		panic(err)
+	}
+
+	// VCom = 14*G + s*HVec[0] + 1*HVec[9]: the HVec[9] slot balances wl[0] - 1 for the even value
+	x, s := bint(14), MustRandScalar()
+	bits := []*big.Int{bint(0), bint(1), bint(1), bint(1)}
+
+	v := zeroVector(circuit.Nv)
+	v[0], v[1] = x, bint(1)
+
+	forged, err := circuit.CommitCircuit(v, s)
+	if err != nil {
+		panic(err)
+	}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{v, zeroVector(circuit.Nv)},
+		Sv: []*big.Int{s, bint(0)},
+		Wl: bits,
+		Wr: bits,
+		Wo: []*big.Int{bint(0)},
+	}
+
+	proof, err := ProveCircuit(circuit, []*bn256.G1{forged, ZeroCommitment()}, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyParity(public, forged, NewKeccakFS(), 1, proof); err == nil {
+		panic("proof of oddness for the forged commitment should fail")
+	}
+}
@@ -0,0 +1,139 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// CommitVector creates a commitment for the vector x and blinding s: VCom = x[0]*G + s*HVec[0] + <x[1:], HVec[9:]>.
+func (p *PermutationPublic) CommitVector(x []*big.Int, s *big.Int) *bn256.G1 {
+	res := new(bn256.G1).ScalarMult(p.G, x[0])
+	res.Add(res, new(bn256.G1).ScalarMult(p.HVec[0], s))
+	res.Add(res, vectorPointScalarMul(p.HVec[StructuralSlots:], x[1:]))
+	return res
+}
+
+// ProvePermutation generates zero knowledge proof that the committed vector x is a permutation of the public vector Y
+// using the product argument: prod(x[i] + gamma) = prod(Y[i] + gamma) for the random challenge gamma.
+// Use empty FiatShamirEngine for call.
+func ProvePermutation(public *PermutationPublic, fs FiatShamirEngine, x []*big.Int, s *big.Int) (*ArithmeticCircuitProof, error) {
+	if len(public.Y) < 2 {
+		return nil, errors.New("invalid public vector: should contain at least 2 values")
+	}
+
+	if len(x) != len(public.Y) {
+		return nil, errors.New("invalid vector length: should be equal to the public vector length")
+	}
+
+	V := public.CommitVector(x, s)
+
+	gamma := public.challenge(fs, V)
+	circuit := public.circuit(gamma)
+
+	// Running products: wl[0] = x[0] + gamma, wl[i+1] = wl[i] * wr[i], wr[i] = x[i+1] + gamma
+	wl := make([]*big.Int, circuit.Nm)
+	wr := make([]*big.Int, circuit.Nm)
+
+	wl[0] = add(x[0], gamma)
+	for i := range wr {
+		wr[i] = add(x[i+1], gamma)
+
+		if i+1 < len(wl) {
+			wl[i+1] = mul(wl[i], wr[i])
+		}
+	}
+
+	return ProveCircuit(circuit, []*bn256.G1{V}, fs, &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{x},
+		Sv: []*big.Int{s},
+		Wl: wl,
+		Wr: wr,
+		Wo: []*big.Int{bint(0)},
+	})
+}
+
+// VerifyPermutation verifies the proof that the vector committed in VCom is a permutation of the public vector Y.
+// If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyPermutation(public *PermutationPublic, VCom *bn256.G1, fs FiatShamirEngine, proof *ArithmeticCircuitProof) error {
+	if len(public.Y) < 2 {
+		return errors.New("invalid public vector: should contain at least 2 values")
+	}
+
+	gamma := public.challenge(fs, VCom)
+	return VerifyCircuit(public.circuit(gamma), []*bn256.G1{VCom}, fs, proof)
+}
+
+// challenge absorbs the public vector and the vector commitment and returns the product argument challenge gamma.
+func (p *PermutationPublic) challenge(fs FiatShamirEngine, VCom *bn256.G1) *big.Int {
+	for _, y := range p.Y {
+		fs.AddNumber(y)
+	}
+
+	fs.AddPoint(VCom)
+	return fs.GetChallenge()
+}
+
+// circuit builds the product argument arithmetic circuit for the challenge gamma:
+// wl[i] * wr[i] = wl[i+1] for i < Nm-1 and wl[Nm-1] * wr[Nm-1] = prod(Y[i] + gamma),
+// v[0] + gamma - wl[0] = 0 and v[i+1] + gamma - wr[i] = 0.
+// The output wo is not constrained.
+func (p *PermutationPublic) circuit(gamma *big.Int) *ArithmeticCircuitPublic {
+	N := len(p.Y)
+	Nm, No, Nv, K := N-1, 1, N, 1
+	Nw := Nm + Nm + No
+
+	product := bint(1)
+	for _, y := range p.Y {
+		product = mul(product, add(y, gamma))
+	}
+
+	Wm := zeroMatrix(Nm, Nw)
+	am := zeroVector(Nm)
+	for i := 0; i < Nm-1; i++ {
+		Wm[i][i+1] = bint(1)
+	}
+	am[Nm-1] = product
+
+	Wl := zeroMatrix(Nv*K, Nw)
+	al := zeroVector(Nv * K)
+
+	Wl[0][0] = bint(-1)
+	al[0] = gamma
+
+	for i := 0; i < Nm; i++ {
+		Wl[i+1][i+Nm] = bint(-1)
+		al[i+1] = gamma
+	}
+
+	return &ArithmeticCircuitPublic{
+		Nm:   Nm,
+		Nl:   Nv * K,
+		Nv:   Nv,
+		Nw:   Nw,
+		No:   No,
+		K:    K,
+		G:    p.G,
+		GVec: p.GVec,
+		HVec: p.HVec,
+		Wm:   Wm,
+		Wl:   Wl,
+		Am:   am,
+		Al:   al,
+		Fl:   true,
+		Fm:   false,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
+	}
+}
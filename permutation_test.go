@@ -0,0 +1,54 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestPermutation(t *testing.T) {
+	Y := []*big.Int{bint(1), bint(2), bint(3)}
+
+	Nm := len(Y) - 1
+	Nv := len(Y)
+
+	wnlaPublic := NewWeightNormLinearPublic(16, 2)
+
+	public := &PermutationPublic{
+		G:     wnlaPublic.G,
+		GVec:  wnlaPublic.GVec[:Nm],
+		HVec:  wnlaPublic.HVec[:Nv+9],
+		Y:     Y,
+		GVec_: wnlaPublic.GVec[Nm:],
+		HVec_: wnlaPublic.HVec[Nv+9:],
+	}
+
+	x := []*big.Int{bint(3), bint(1), bint(2)}
+	s := MustRandScalar()
+
+	VCom := public.CommitVector(x, s)
+
+	proof, err := ProvePermutation(public, NewKeccakFS(), x, s)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyPermutation(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Not a permutation
+	x = []*big.Int{bint(1), bint(1), bint(3)}
+	VCom = public.CommitVector(x, s)
+
+	if proof, err = ProvePermutation(public, NewKeccakFS(), x, s); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyPermutation(public, VCom, NewKeccakFS(), proof); err == nil {
+		panic("proof for not a permutation should fail")
+	}
+}
@@ -0,0 +1,27 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"time"
+)
+
+// Profiler receives the durations of the circuit proving phases. ProveCircuit reports the phases to the
+// ArithmeticCircuitPublic.Profiler in order: "commitOL", "commitR", "matrices", "polynomial" and "wnla".
+type Profiler interface {
+	Phase(name string, duration time.Duration)
+}
+
+// profilePhase starts the phase and returns the function that reports its duration to the profiler p, if not nil.
+func profilePhase(p Profiler, name string) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		p.Phase(name, time.Since(start))
+	}
+}
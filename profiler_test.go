@@ -0,0 +1,56 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type recordingProfiler struct {
+	phases []string
+}
+
+func (r *recordingProfiler) Phase(name string, duration time.Duration) {
+	if duration < 0 {
+		panic("negative duration")
+	}
+
+	r.phases = append(r.phases, name)
+}
+
+func TestProfiler(t *testing.T) {
+	public, private, V := newTestCircuit()
+
+	recorder := &recordingProfiler{}
+	public.Profiler = recorder
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyCircuit(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	expected := []string{"commitOL", "commitR", "matrices", "polynomial", "wnla"}
+	if fmt.Sprint(recorder.phases) != fmt.Sprint(expected) {
+		panic(fmt.Sprintf("unexpected phases: %v", recorder.phases))
+	}
+
+	// Disabled profiler is not invoked
+	public.Profiler = nil
+	recorder.phases = nil
+
+	if _, err := ProveCircuit(public, V, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if len(recorder.phases) != 0 {
+		panic("disabled profiler should not be invoked")
+	}
+}
@@ -0,0 +1,150 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// FromR1CS translates the R1CS <a[i], z> * <b[i], z> = <c[i], z> over the assignment z = (1, z_1, ..., z_{n-1})
+// into the arithmetic circuit with Nm = m constraints, No = n-1, Nv = max(n-1, 2m) and K = 2, where v[0] is the
+// committed assignment and v[1] is the ZeroCommitment:
+// wo[j-1] = z_j - the variables are committed in v[0][j-1] and copied into wo by the rows -wo[j-1] + v[0][j-1] = 0,
+// v[0][j] = 0 for j >= n-1 - the commitment has no other values,
+// wl[i] = <a[i], z> and wr[i] = <b[i], z> - the rows with the zero values v[1][i] and v[1][m+i],
+// wl[i] * wr[i] = <c[i], z> - the multiplicative constraint over wo.
+// The generators are not set: fill G, GVec (Nm), HVec (Nv+StructuralSlots) and the padding GVec_, HVec_ before use.
+// Use R1CSAssignment to create the private values and the committed vector, and verify with the commitments
+// {CommitCircuit(private.V[0], s), ZeroCommitment()}.
+func FromR1CS(a, b, c [][]*big.Int) (*ArithmeticCircuitPublic, error) {
+	m, n, err := r1csShape(a, b, c)
+	if err != nil {
+		return nil, err
+	}
+
+	Nm, No, Nv, K := m, n-1, r1csNv(m, n), 2
+	Nw := Nm + Nm + No
+
+	Wm := zeroMatrix(Nm, Nw)
+	Am := zeroVector(Nm)
+
+	Wl := zeroMatrix(Nv*K, Nw)
+	Al := zeroVector(Nv * K)
+
+	for j := 0; j < No; j++ {
+		Wl[j][Nm+Nm+j] = bint(-1)
+	}
+
+	for i := 0; i < m; i++ {
+		Am[i] = new(big.Int).Mod(c[i][0], bn256.Order)
+
+		rowA, rowB := Nv+i, Nv+m+i
+		Wl[rowA][i] = bint(-1)
+		Wl[rowB][Nm+i] = bint(-1)
+		Al[rowA] = new(big.Int).Mod(a[i][0], bn256.Order)
+		Al[rowB] = new(big.Int).Mod(b[i][0], bn256.Order)
+
+		for j := 1; j < n; j++ {
+			Wm[i][Nm+Nm+j-1] = new(big.Int).Mod(c[i][j], bn256.Order)
+			Wl[rowA][Nm+Nm+j-1] = new(big.Int).Mod(a[i][j], bn256.Order)
+			Wl[rowB][Nm+Nm+j-1] = new(big.Int).Mod(b[i][j], bn256.Order)
+		}
+	}
+
+	return &ArithmeticCircuitPublic{
+		Nm: Nm,
+		Nl: Nv * K,
+		Nv: Nv,
+		Nw: Nw,
+		No: No,
+		K:  K,
+		Wm: Wm,
+		Wl: Wl,
+		Am: Am,
+		Al: Al,
+		Fl: true,
+		Fm: false,
+		F: func(typ PartitionType, index int) *int {
+			if typ == PartitionLL && index < No {
+				return &index
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+// R1CSAssignment returns the private values of the FromR1CS circuit for the satisfying assignment z with z[0] = 1 and
+// the blinding s of the committed vector. Create the commitment with CommitCircuit(private.V[0], s), the second one is
+// the ZeroCommitment.
+// Returns error if z does not satisfy the R1CS.
+func R1CSAssignment(a, b, c [][]*big.Int, z []*big.Int, s *big.Int) (*ArithmeticCircuitPrivate, error) {
+	m, n, err := r1csShape(a, b, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(z) != n {
+		return nil, fmt.Errorf("invalid assignment: should have %d values, got %d", n, len(z))
+	}
+
+	if new(big.Int).Mod(z[0], bn256.Order).Cmp(bint(1)) != 0 {
+		return nil, errors.New("invalid assignment: z[0] should be 1")
+	}
+
+	wl, wr := make([]*big.Int, m), make([]*big.Int, m)
+	for i := 0; i < m; i++ {
+		wl[i], wr[i] = vectorMul(a[i], z), vectorMul(b[i], z)
+
+		if mul(wl[i], wr[i]).Cmp(vectorMul(c[i], z)) != 0 {
+			return nil, fmt.Errorf("invalid assignment: constraint %d is not satisfied", i)
+		}
+	}
+
+	wo := make([]*big.Int, n-1)
+	for j := range wo {
+		wo[j] = new(big.Int).Mod(z[j+1], bn256.Order)
+	}
+
+	v := zeroVector(r1csNv(m, n))
+	copy(v, wo)
+
+	return &ArithmeticCircuitPrivate{
+		V:  [][]*big.Int{v, zeroVector(len(v))},
+		Sv: []*big.Int{s, bint(0)},
+		Wl: wl,
+		Wr: wr,
+		Wo: wo,
+	}, nil
+}
+
+// r1csNv returns the Nv dimension of the FromR1CS circuit: the committed variables and the rows of wl and wr
+// should fit into Nv values.
+func r1csNv(m, n int) int {
+	return maxLen(n-1, 2*m)
+}
+
+// r1csShape returns the constraints count m and the variables count n (including the constant) of the R1CS matrices.
+func r1csShape(a, b, c [][]*big.Int) (m, n int, err error) {
+	m = len(a)
+	if m == 0 || len(a[0]) < 2 {
+		return 0, 0, errors.New("invalid R1CS: should have at least one constraint and one variable")
+	}
+
+	n = len(a[0])
+	for _, matrix := range []struct {
+		name string
+		m    [][]*big.Int
+	}{{"A", a}, {"B", b}, {"C", c}} {
+		if err = checkMatrix(matrix.name, matrix.m, m, n); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return m, n, nil
+}
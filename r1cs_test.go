@@ -0,0 +1,140 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestFromR1CS(t *testing.T) {
+	// x * y = z over z = (1, x, y, z)
+	a := [][]*big.Int{{bint(0), bint(1), bint(0), bint(0)}}
+	b := [][]*big.Int{{bint(0), bint(0), bint(1), bint(0)}}
+	c := [][]*big.Int{{bint(0), bint(0), bint(0), bint(1)}}
+
+	public, err := FromR1CS(a, b, c)
+	if err != nil {
+		panic(err)
+	}
+
+	if public.Nm != 1 || public.No != 3 || public.Nv != 3 || public.K != 2 {
+		panic("unexpected circuit dimensions")
+	}
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public.G = wnla.G
+	public.GVec = wnla.GVec[:public.Nm]
+	public.HVec = wnla.HVec[:public.Nv+StructuralSlots]
+	public.GVec_ = wnla.GVec[public.Nm:]
+	public.HVec_ = wnla.HVec[public.Nv+StructuralSlots:]
+
+	if err = ValidatePartition(public); err != nil {
+		panic(err)
+	}
+
+	private, err := R1CSAssignment(a, b, c, []*big.Int{bint(1), bint(3), bint(5), bint(15)}, MustRandScalar())
+	if err != nil {
+		panic(err)
+	}
+
+	V, err := public.CommitCircuit(private.V[0], private.Sv[0])
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := ProveCircuit(public, []*bn256.G1{V, ZeroCommitment()}, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, []*bn256.G1{V, ZeroCommitment()}, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Commitment to the other assignment
+	other, err := public.CommitCircuit([]*big.Int{bint(3), bint(5), bint(16)}, private.Sv[0])
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, []*bn256.G1{other, ZeroCommitment()}, NewKeccakFS(), proof); err == nil {
+		panic("proof for the other commitment should fail")
+	}
+
+	if _, err = R1CSAssignment(a, b, c, []*big.Int{bint(1), bint(3), bint(5), bint(16)}, MustRandScalar()); err == nil {
+		panic("unsatisfying assignment should be rejected")
+	}
+
+	if _, err = FromR1CS(a, b, [][]*big.Int{{bint(0), bint(1)}}); err == nil {
+		panic("matrices of different shapes should be rejected")
+	}
+}
+
+func TestFromR1CSForgedCommitment(t *testing.T) {
+	// x * y = z over z = (1, x, y, z)
+	a := [][]*big.Int{{bint(0), bint(1), bint(0), bint(0)}}
+	b := [][]*big.Int{{bint(0), bint(0), bint(1), bint(0)}}
+	c := [][]*big.Int{{bint(0), bint(0), bint(0), bint(1)}}
+
+	public, err := FromR1CS(a, b, c)
+	if err != nil {
+		panic(err)
+	}
+
+	wnla := NewWeightNormLinearPublic(16, 1)
+
+	public.G = wnla.G
+	public.GVec = wnla.GVec[:public.Nm]
+	public.HVec = wnla.HVec[:public.Nv+StructuralSlots]
+	public.GVec_ = wnla.GVec[public.Nm:]
+	public.HVec_ = wnla.HVec[public.Nv+StructuralSlots:]
+
+	// 3 * 5 = 16 with wl = 3 and wr = 16/3: the values of every linear row are chosen to balance it, so the
+	// rows of wl and wr would hold if their values were committed by the prover with HVec[9:] components
+	z := []*big.Int{bint(3), bint(5), bint(16)}
+	w := []*big.Int{bint(3), mul(bint(16), inv(bint(3))), z[0], z[1], z[2]}
+
+	private := &ArithmeticCircuitPrivate{
+		V:  make([][]*big.Int, public.K),
+		Sv: []*big.Int{MustRandScalar(), MustRandScalar()},
+		Wl: w[:1],
+		Wr: w[1:2],
+		Wo: z,
+	}
+
+	V := make([]*bn256.G1, public.K)
+	for k := range V {
+		private.V[k] = make([]*big.Int, public.Nv)
+		for j := range private.V[k] {
+			row := k*public.Nv + j
+			private.V[k][j] = minus(add(vectorMul(public.Wl[row], w), public.Al[row]))
+		}
+
+		if V[k], err = public.CommitCircuit(private.V[k], private.Sv[k]); err != nil {
+			panic(err)
+		}
+	}
+
+	if private.V[1][1].Sign() == 0 {
+		panic("the row of wr should need the nonzero value")
+	}
+
+	proof, err := ProveCircuit(public, V, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyCircuit(public, []*bn256.G1{V[0], ZeroCommitment()}, NewKeccakFS(), proof); err == nil {
+		panic("proof with the nonzero values in place of the zero commitment should fail")
+	}
+
+	// The proof holds only for the second commitment chosen by the prover
+	if err = VerifyCircuit(public, []*bn256.G1{V[0], V[1]}, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
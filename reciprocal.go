@@ -5,185 +5,1027 @@
 package bulletproofs
 
 import (
+	"errors"
+	"fmt"
 	"github.com/cloudflare/bn256"
+	"io"
 	"math/big"
 )
 
 func (p *ReciprocalPublic) CommitValue(v *big.Int, s *big.Int) *bn256.G1 {
-	res := new(bn256.G1).ScalarMult(p.G, v)
+	res := p.precomputeG().mul(v)
 	res.Add(res, new(bn256.G1).ScalarMult(p.HVec[0], s))
 	return res
 }
 
+// CommitValueMulti creates a commitment for the value v with several blinding contributions on distinct generators:
+// VCom = v*G + sum(blindings[i]*hs[i]). The hs generators should not have a known discrete logarithm relation to G.
+func (p *ReciprocalPublic) CommitValueMulti(v *big.Int, blindings []*big.Int, hs []*bn256.G1) *bn256.G1 {
+	res := p.precomputeG().mul(v)
+	res.Add(res, vectorPointScalarMul(hs, blindings))
+	return res
+}
+
 func (p *ReciprocalPublic) CommitPoles(r []*big.Int, s *big.Int) *bn256.G1 {
 	res := new(bn256.G1).ScalarMult(p.HVec[0], s)
-	res.Add(res, vectorPointScalarMul(p.HVec[9:], r))
+	res.Add(res, vectorPointScalarMul(p.HVec[StructuralSlots:], r))
 	return res
 }
 
+// CommitSession precomputes windowed multiplication tables for G and HVec[0] to speed up creation of many
+// value commitments with the same public parameters.
+type CommitSession struct {
+	g, h *FixedBaseMul
+}
+
+func NewCommitSession(public *ReciprocalPublic) *CommitSession {
+	return &CommitSession{
+		g: NewFixedBaseMul(public.G, 4),
+		h: NewFixedBaseMul(public.HVec[0], 4),
+	}
+}
+
+// Commit creates a commitment for the value v and blinding s: VCom = v*G + s*HVec[0]. Equals to the
+// ReciprocalPublic.CommitValue result.
+func (c *CommitSession) Commit(v *big.Int, s *big.Int) *bn256.G1 {
+	return new(bn256.G1).Add(c.g.Mul(v), c.h.Mul(s))
+}
+
+// OpenValueCommit checks that commitment opens to the value x with blinding s: commitment = x*G + s*HVec[0].
+// This is NOT a zero-knowledge operation: it requires the opening and should be used only for testing and debugging.
+func OpenValueCommit(public *ReciprocalPublic, commitment *bn256.G1, x, s *big.Int) bool {
+	return pointsEqual(public.CommitValue(x, s), commitment)
+}
+
+// CommitValueFromDigits creates a value commitment for the value reconstructed from digits in the given base,
+// so the committed value is always consistent with the digits used for proving.
+func CommitValueFromDigits(public *ReciprocalPublic, digits []*big.Int, base int, s *big.Int) *bn256.G1 {
+	return public.CommitValue(ReconstructFromDigits(digits, base), s)
+}
+
 // ProveRange generates zero knowledge proof that corresponding to the committed digits vector value lies in [0, 2^n) range.
+// If private.Xs is not empty, the proof is generated for all the values in it using K = len(Xs) dimension of the circuit
+// and returned proof contains one poles commitment per value in Vs.
 // Use empty FiatShamirEngine for call.
-func ProveRange(public *ReciprocalPublic, fs FiatShamirEngine, private *ReciprocalPrivate) *ReciprocalProof {
-	vCom := public.CommitValue(private.X, private.S)
-	fs.AddPoint(vCom)
+func ProveRange(public *ReciprocalPublic, fs FiatShamirEngine, private *ReciprocalPrivate) (*ReciprocalProof, error) {
+	if len(private.Xs) > 0 {
+		return proveRangeMulti(public, fs, private)
+	}
+
+	proof, _, err := ProveRangeWithBlinding(public, fs, private)
+	return proof, err
+}
+
+// ProveRangeFresh samples the blinding, commits to the value x and generates the range proof for the commitment.
+// The digits and multiplicities are derived from x in the base Np, so x should lie in [0, Np^Nd).
+// Use empty FiatShamirEngine for call.
+func ProveRangeFresh(public *ReciprocalPublic, fs FiatShamirEngine, x *big.Int) (*ReciprocalProof, *bn256.G1, *big.Int, error) {
+	digits, m, err := ReciprocalWitness(x, public.Np, public.Nd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).Set(x),
+		M:      m,
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
 
-	e := fs.GetChallenge()
+	proof, err := ProveRange(public, fs, private)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	Nm := public.Nd
-	No := public.Np
+	return proof, public.CommitValue(private.X, private.S), private.S, nil
+}
 
-	Nv := public.Nd + 1
-	Nl := Nv
-	Nw := public.Nd + public.Nd + public.Np
+// ProveRangeMultiBlinding generates the range proof for the commitment created by CommitValueMulti. The value x
+// should lie in [0, Np^Nd). The range proof is generated for the rebased commitment with the summed blinding
+// x*G + sum(blindings)*HVec[0], and the same transcript is then used to prove that the difference with VCom is
+// spanned by hs and HVec[0]. Use empty FiatShamirEngine for call.
+func ProveRangeMultiBlinding(public *ReciprocalPublic, fs FiatShamirEngine, x *big.Int, blindings []*big.Int, hs []*bn256.G1) (*MultiBlindingRangeProof, error) {
+	if len(blindings) == 0 || len(blindings) != len(hs) {
+		return nil, errors.New("invalid blindings: should be non-empty and have the same length as hs")
+	}
 
-	r := make([]*big.Int, public.Nd)
-	for j := range r {
-		r[j] = inv(add(private.Digits[j], e))
+	digits, err := valueDigits(x, public.Np, public.Nd)
+	if err != nil {
+		return nil, err
 	}
 
-	rBlind := MustRandScalar()
-	rCom := public.CommitPoles(r, rBlind)
+	s := bint(0)
+	for _, b := range blindings {
+		s = add(s, b)
+	}
 
-	v := []*big.Int{private.X}
-	v = append(v, r...)
+	proof, err := ProveRange(public, fs, &ReciprocalPrivate{
+		X:      x,
+		M:      DigitsMapping(digits, public.Np),
+		Digits: digits,
+		S:      s,
+	})
 
-	wL := private.Digits
-	wR := r
-	wO := private.M
+	if err != nil {
+		return nil, err
+	}
 
-	am := oneVector(Nm)
-	Wm := zeroMatrix(Nm, Nw)
+	VCom := public.CommitValueMulti(x, blindings, hs)
+	rebased := public.CommitValue(x, s)
 
-	for i := 0; i < Nm; i++ {
-		Wm[i][i+Nm] = minus(e)
+	// VCom - rebased = sum(blindings[i]*hs[i]) - s*HVec[0]
+	k := make([]*big.Int, len(hs))
+	for i := range k {
+		k[i] = MustRandScalar()
 	}
+	k0 := MustRandScalar()
 
-	al := zeroVector(Nl)
-	Wl := zeroMatrix(Nl, Nw)
+	T := vectorPointScalarMul(hs, k)
+	T.Add(T, new(bn256.G1).ScalarMult(public.HVec[0], k0))
 
-	// v
-	base := bint(public.Np)
-	for i := 0; i < Nm; i++ {
-		Wl[0][i] = minus(pow(base, i))
+	c := multiBlindingChallenge(fs, hs, VCom, rebased, T)
+
+	return &MultiBlindingRangeProof{
+		ReciprocalProof: proof,
+		Rebased:         rebased,
+		T:               T,
+		Z:               vectorAdd(k, vectorMulOnScalar(blindings, c)),
+		Z0:              sub(k0, mul(c, s)),
+	}, nil
+}
+
+// VerifyRangeMultiBlinding verifies the proof generated by ProveRangeMultiBlinding for the commitment VCom created by
+// CommitValueMulti with the same hs generators. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyRangeMultiBlinding(public *ReciprocalPublic, VCom *bn256.G1, hs []*bn256.G1, fs FiatShamirEngine, proof *MultiBlindingRangeProof) error {
+	if proof.ReciprocalProof == nil || proof.Rebased == nil || proof.T == nil || proof.Z0 == nil {
+		return errors.New("invalid proof: missing elements")
 	}
 
-	// r
-	for i := 0; i < Nm; i++ {
-		for j := 0; j < Nm; j++ {
-			Wl[i+1][j+Nm] = bint(1)
+	if len(hs) == 0 || len(proof.Z) != len(hs) {
+		return errors.New("invalid proof: responses count should match the hs generators count")
+	}
+
+	if err := VerifyRange(public, proof.Rebased, fs, proof.ReciprocalProof); err != nil {
+		return err
+	}
+
+	c := multiBlindingChallenge(fs, hs, VCom, proof.Rebased, proof.T)
+
+	// sum(Z[i]*hs[i]) + Z0*HVec[0] = T + c*(VCom - rebased)
+	left := vectorPointScalarMul(hs, proof.Z)
+	left.Add(left, new(bn256.G1).ScalarMult(public.HVec[0], proof.Z0))
+
+	right := new(bn256.G1).Add(VCom, negPoint(proof.Rebased))
+	right.ScalarMult(right, c)
+	right.Add(right, proof.T)
+
+	if !pointsEqual(left, right) {
+		return errors.New("representation proof failed: commitment does not match the rebased one")
+	}
+
+	return nil
+}
+
+func multiBlindingChallenge(fs FiatShamirEngine, hs []*bn256.G1, VCom, rebased, T *bn256.G1) *big.Int {
+	fs.AddNumber(bint(len(hs)))
+	for _, h := range hs {
+		fs.AddPoint(h)
+	}
+
+	fs.AddPoint(VCom)
+	fs.AddPoint(rebased)
+	fs.AddPoint(T)
+	return fs.GetChallenge()
+}
+
+// ProveRangeWithBlinding generates the same proof as ProveRange for a single value and additionally returns the
+// aggregate blinding S + rBlind of the commitment VCom + proof.V verified by the circuit, so the coordinator can
+// reconcile the homomorphic sums of the commitments. The value stays hidden, but the aggregate blinding together with
+// S reveals the blinding of the poles commitment proof.V, so it should be shared only within the trusted aggregation
+// context. Use empty FiatShamirEngine for call.
+func ProveRangeWithBlinding(public *ReciprocalPublic, fs FiatShamirEngine, private *ReciprocalPrivate) (*ReciprocalProof, *big.Int, error) {
+	if len(private.Xs) > 0 {
+		return nil, nil, errors.New("blinding can be returned only for the single value proof")
+	}
+
+	aggregated := public.aggregated()
+
+	proof, Sv, err := proveAggregatedRange(aggregated, aggregated.linearTemplate(), fs, &AggregatedReciprocalPrivate{
+		X:           []*big.Int{private.X},
+		M:           private.M,
+		Digits:      [][]*big.Int{private.Digits},
+		S:           []*big.Int{private.S},
+		NonHiding:   private.NonHiding,
+		Rand:        private.Rand,
+		DebugChecks: private.DebugChecks,
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &ReciprocalProof{
+		ArithmeticCircuitProof: proof.ArithmeticCircuitProof,
+		V:                      proof.V[0],
+	}, Sv[0], nil
+}
+
+func proveRangeMulti(public *ReciprocalPublic, fs FiatShamirEngine, private *ReciprocalPrivate) (*ReciprocalProof, error) {
+	K := len(private.Xs)
+	if len(private.Ms) != K || len(private.DigitsList) != K || len(private.Ss) != K {
+		return nil, errors.New("invalid private values: Xs, Ms, DigitsList and Ss should have the same length")
+	}
+
+	// Multiplicities of the digits of all values are summed up, because the circuit has one set of public poles
+	M := zeroVector(public.Np)
+	for _, m := range private.Ms {
+		if len(m) != public.Np {
+			return nil, errors.New("invalid multiplicities length: should be equal to Np")
+		}
+
+		for j := range M {
+			M[j] = add(M[j], m[j])
 		}
 	}
 
-	for i := 0; i < Nm; i++ {
-		Wl[i+1][i+Nm] = bint(0)
+	proof, err := ProveAggregatedRange(public.aggregatedMulti(K), fs, &AggregatedReciprocalPrivate{
+		X:           private.Xs,
+		M:           M,
+		Digits:      private.DigitsList,
+		S:           private.Ss,
+		NonHiding:   private.NonHiding,
+		Rand:        private.Rand,
+		DebugChecks: private.DebugChecks,
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < Nm; i++ {
-		for j := 0; j < No; j++ {
-			Wl[i+1][j+2*Nm] = minus(inv(add(e, bint(j))))
+	return &ReciprocalProof{
+		ArithmeticCircuitProof: proof.ArithmeticCircuitProof,
+		Vs:                     proof.V,
+	}, nil
+}
+
+// VerifyRange verifies BP++ reciprocal argument range proof on arithmetic circuits. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyRange(public *ReciprocalPublic, V *bn256.G1, fs FiatShamirEngine, proof *ReciprocalProof) error {
+	if proof.V == nil {
+		return errors.New("invalid proof: missing poles commitment, use VerifyRangeMulti for the proof of several values")
+	}
+
+	return VerifyAggregatedRange(public.aggregated(), []*bn256.G1{V}, fs, &AggregatedReciprocalProof{
+		ArithmeticCircuitProof: proof.ArithmeticCircuitProof,
+		V:                      []*bn256.G1{proof.V},
+	})
+}
+
+// FirstChallenge returns the reciprocal challenge e that VerifyRange derives for the value commitment V with the same
+// FiatShamirEngine, see AggregatedReciprocalPublic.FirstChallenge. Returns nil if the proof has no poles commitment.
+// Use empty FiatShamirEngine for call.
+func (p *ReciprocalPublic) FirstChallenge(V *bn256.G1, fs FiatShamirEngine, proof *ReciprocalProof) *big.Int {
+	if proof == nil || proof.V == nil {
+		return nil
+	}
+
+	return p.aggregated().FirstChallenge([]*bn256.G1{V}, fs, &AggregatedReciprocalProof{
+		ArithmeticCircuitProof: proof.ArithmeticCircuitProof,
+		V:                      []*bn256.G1{proof.V},
+	})
+}
+
+// ProveRangeExternal generates the range proof for the bn256 value commitment bound to the external commitment ext
+// of the same value. The proof covers only the bn256 range statement: the relation between ext and the bn256 value
+// commitment is not proven and should be verified out-of-band. Use empty FiatShamirEngine for call.
+func ProveRangeExternal(public *ReciprocalPublic, fs FiatShamirEngine, ext ExternalCommitment, private *ReciprocalPrivate) (*ReciprocalProof, error) {
+	BindExternalCommitment(fs, ext)
+	return ProveRange(public, fs, private)
+}
+
+// VerifyRangeExternal verifies the proof generated by ProveRangeExternal for the value commitment V and the external
+// commitment ext. The proof is rejected for any other external commitment. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyRangeExternal(public *ReciprocalPublic, V *bn256.G1, ext ExternalCommitment, fs FiatShamirEngine, proof *ReciprocalProof) error {
+	BindExternalCommitment(fs, ext)
+	return VerifyRange(public, V, fs, proof)
+}
+
+// VerifyRangeMarshaled verifies BP++ reciprocal argument range proof for the encoded value commitment. The commitment
+// is decoded and validated with UnmarshalCommitment before verification. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyRangeMarshaled(public *ReciprocalPublic, vBytes []byte, fs FiatShamirEngine, proof *ReciprocalProof) error {
+	V, err := UnmarshalCommitment(vBytes)
+	if err != nil {
+		return err
+	}
+
+	return VerifyRange(public, V, fs, proof)
+}
+
+// VerifyRangeCoords verifies BP++ reciprocal argument range proof for the value commitment given by the affine
+// coordinates cx, cy, e.g. from the EVM calldata. The commitment is constructed with G1FromCoords, and the identity
+// is rejected. If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyRangeCoords(public *ReciprocalPublic, cx, cy *big.Int, fs FiatShamirEngine, proof *ReciprocalProof) error {
+	V, err := G1FromCoords(cx, cy)
+	if err != nil {
+		return err
+	}
+
+	if cx.Sign() == 0 && cy.Sign() == 0 {
+		return errors.New("invalid commitment: identity point")
+	}
+
+	return VerifyRange(public, V, fs, proof)
+}
+
+// SameStatement reports whether the proofs a and b for the value commitments aCom and bCom prove the same statement:
+// the commitments are equal and the proofs have the same parameters digest. The proofs do not carry the generators,
+// so the digest covers the shape fixed by the ReciprocalPublic: the WNLA rounds count, the final vectors lengths,
+// the layout and the number of the committed values. The random proof elements (CL, CR, CO, CS, the poles commitments
+// and the WNLA rounds) are not compared. It does not verify the proofs.
+func SameStatement(a, b *ReciprocalProof, aCom, bCom *bn256.G1) bool {
+	if a == nil || b == nil || aCom == nil || bCom == nil {
+		return false
+	}
+
+	da, db := a.paramsDigest(), b.paramsDigest()
+	return da != nil && db != nil && da.Cmp(db) == 0 && pointsEqual(aCom, bCom)
+}
+
+// paramsDigest returns HashToScalar of the proof shape, or nil if the proof is malformed.
+func (p *ReciprocalProof) paramsDigest() *big.Int {
+	if p.ArithmeticCircuitProof == nil || p.WNLA == nil {
+		return nil
+	}
+
+	flattened := 0
+	if p.WNLA.IsFlattened() {
+		flattened = 1
+	}
+
+	enc := &encoder{}
+	enc.length(len(p.WNLA.X))
+	enc.length(len(p.WNLA.L))
+	enc.length(len(p.WNLA.N))
+	enc.length(flattened)
+	enc.length(len(p.Vs))
+	return HashToScalar(enc.buf)
+}
+
+// VerifyRangeReader reads the range proof encoded with ReciprocalProof.Marshal from r and verifies it for the value
+// commitment V. The proof is decoded incrementally: points and scalars are read as they arrive and vectors are limited
+// by their length prefixes, so the encoded proof is never buffered as a whole. Exactly the proof bytes are consumed
+// from r, so several proofs can be read from the same stream. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyRangeReader(public *ReciprocalPublic, V *bn256.G1, r io.Reader, fs FiatShamirEngine) error {
+	proof := &ReciprocalProof{}
+	if err := (&decoder{r: r}).reciprocal(proof); err != nil {
+		return err
+	}
+
+	return VerifyRange(public, V, fs, proof)
+}
+
+// FindRangeCommitment verifies the range proof against every candidate value commitment and returns the index of the
+// first candidate the proof is valid for. The value commitment is absorbed into the transcript, so the proof is valid
+// for at most one of the distinct candidates. Returns -1 and error if the proof is not valid for any of them.
+// This is not an OR-proof: the matched candidate is revealed to the verifier. newFS should return
+// empty FiatShamirEngine.
+func FindRangeCommitment(public *ReciprocalPublic, candidates []*bn256.G1, newFS func() FiatShamirEngine, proof *ReciprocalProof) (int, error) {
+	for i, V := range candidates {
+		if err := VerifyRange(public, V, newFS(), proof); err == nil {
+			return i, nil
 		}
 	}
 
-	circuit := &ArithmeticCircuitPublic{
-		Nm:   Nm,
-		Nl:   Nl,
-		Nv:   Nv,
-		Nw:   Nw,
-		No:   No,
-		K:    1,
-		G:    public.G,
-		GVec: public.GVec,
-		HVec: public.HVec,
-		Wm:   Wm,
-		Wl:   Wl,
-		Am:   am,
-		Al:   al,
-		Fl:   true,
-		Fm:   false,
-		F: func(typ PartitionType, index int) *int {
-			if typ == PartitionLL && index < No { // map all to ll
-				return &index
-			}
+	return -1, errors.New("proof is not valid for any of the candidates")
+}
 
-			return nil
-		},
-		GVec_: public.GVec_,
-		HVec_: public.HVec_,
+// ErrRangeSumMismatch is returned by VerifyRangeBatchSum when the sum of the value commitments differs from the target.
+var ErrRangeSumMismatch = errors.New("sum of the value commitments does not match the target")
+
+// VerifyRangeBatchSum verifies the range proofs of the value commitments and checks that the commitments sum up to
+// targetSum homomorphically: sum(commitments) == targetSum, so the committed values and blindings sum up to the target
+// ones. Returns ErrRangeSumMismatch if the sum check fails, or *BatchVerifyError with the smallest index of the failed
+// proof. The sum is checked first, as it is much cheaper. newFS should return empty FiatShamirEngine.
+func VerifyRangeBatchSum(public *ReciprocalPublic, commitments []*bn256.G1, targetSum *bn256.G1, proofs []*ReciprocalProof, newFS func() FiatShamirEngine) error {
+	if len(commitments) != len(proofs) {
+		return errors.New("invalid length for commitments: should be equal to the proofs count")
 	}
 
-	prv := &ArithmeticCircuitPrivate{
-		V:  [][]*big.Int{v},
-		Sv: []*big.Int{add(private.S, rBlind)},
-		Wl: wL,
-		Wr: wR,
-		Wo: wO,
+	sum := new(bn256.G1).ScalarBaseMult(bint(0))
+	for _, V := range commitments {
+		sum.Add(sum, V)
+	}
+
+	if !pointsEqual(sum, targetSum) {
+		return ErrRangeSumMismatch
+	}
+
+	for i := range proofs {
+		if err := VerifyRange(public, commitments[i], newFS(), proofs[i]); err != nil {
+			return &BatchVerifyError{Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// BatchVerifyRangeMixed verifies range proofs generated for the different public parameters (e.g. different digits
+// count) over the same curve. The final WNLA equations of the proofs (see VerifyWNLAFast) are derived in parallel
+// using up to GOMAXPROCS goroutines and checked at once: they are combined with the random weights into the single
+// multi-scalar multiplication. If the combined check fails, the equations are checked one by one to find the failed
+// item. Returns *BatchVerifyError with the smallest index of the failed item.
+func BatchVerifyRangeMixed(items []RangeVerifyItem) error {
+	return BatchVerifyRangeMixedN(items, 0)
+}
+
+// BatchVerifyRangeMixedN works as BatchVerifyRangeMixed using up to workers goroutines.
+// Non-positive workers means GOMAXPROCS. The result does not depend on the workers count.
+func BatchVerifyRangeMixedN(items []RangeVerifyItem, workers int) error {
+	type equation struct {
+		points  []*bn256.G1
+		scalars []*big.Int
+		com     *bn256.G1
+		err     error
+	}
+
+	eqs := make([]equation, len(items))
+
+	parallelFor(len(items), workers, func(i int) {
+		eq := &eqs[i]
+		eq.points, eq.scalars, eq.com, eq.err = rangeFinalEquation(items[i].Public, items[i].V, items[i].FS, items[i].Proof)
+	})
+
+	// sum(w[i]*(sum(scalars[i]*points[i]) - com[i])) = 0 for the random weights w
+	var points []*bn256.G1
+	var scalars []*big.Int
+
+	combined := true
+	for _, eq := range eqs {
+		if eq.err != nil {
+			combined = false
+			break
+		}
+
+		w := MustRandScalar()
+
+		points = append(append(points, eq.points...), eq.com)
+		for _, v := range eq.scalars {
+			scalars = append(scalars, mul(w, v))
+		}
+		scalars = append(scalars, minus(w))
+	}
+
+	if combined && pointsEqual(multiScalarMul(points, scalars), new(bn256.G1).ScalarBaseMult(bint(0))) {
+		return nil
+	}
+
+	for i, eq := range eqs {
+		if eq.err == nil && !pointsEqual(multiScalarMul(eq.points, eq.scalars), eq.com) {
+			eq.err = errors.New("failed to verify proof")
+		}
+
+		if eq.err != nil {
+			return &BatchVerifyError{Index: i, Err: eq.err}
+		}
+	}
+
+	return nil
+}
+
+// rangeFinalEquation validates the proof as VerifyRange and returns its final WNLA equation, see
+// VerifyScratch.finalEquation.
+func rangeFinalEquation(public *ReciprocalPublic, V *bn256.G1, fs FiatShamirEngine, proof *ReciprocalProof) ([]*bn256.G1, []*big.Int, *bn256.G1, error) {
+	if proof.V == nil {
+		return nil, nil, nil, errors.New("invalid proof: missing poles commitment, use VerifyRangeMulti for the proof of several values")
+	}
+
+	circuit, V_, err := public.aggregated().rangeCircuit([]*bn256.G1{V}, fs, []*bn256.G1{proof.V})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err = circuit.checkVerify(V_, fs, proof.ArithmeticCircuitProof); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return newVerifyScratch(circuit).finalEquation(V_, fs, proof.ArithmeticCircuitProof)
+}
+
+// VerifyRangeMulti verifies BP++ reciprocal argument range proof for the several values generated by ProveRange
+// with not empty private.Xs. V contains one value commitment per value. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call.
+func VerifyRangeMulti(public *ReciprocalPublic, V []*bn256.G1, fs FiatShamirEngine, proof *ReciprocalProof) error {
+	return VerifyAggregatedRange(public.aggregatedMulti(len(V)), V, fs, &AggregatedReciprocalProof{
+		ArithmeticCircuitProof: proof.ArithmeticCircuitProof,
+		V:                      proof.Vs,
+	})
+}
+
+// ProveBalancedRange generates the range proof for all input and output values and proves that sum of the inputs
+// equals to the sum of the outputs. The range proof is generated for the values of inputs||outputs in the K dimension
+// circuit, so public should contain generators for (len(inputs)+len(outputs))*Nd digits. The balance is proven by the
+// commitments homomorphism: the difference of the commitments sums should commit to zero under HVec[0] only.
+// Use empty FiatShamirEngine for call.
+func ProveBalancedRange(public *ReciprocalPublic, fs FiatShamirEngine, inputs, outputs []RangeItem) (*BalancedRangeProof, error) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return nil, errors.New("invalid items: inputs and outputs should not be empty")
+	}
+
+	items := append(append([]RangeItem{}, inputs...), outputs...)
+
+	private := &ReciprocalPrivate{}
+	for _, item := range items {
+		digits, err := valueDigits(item.X, public.Np, public.Nd)
+		if err != nil {
+			return nil, err
+		}
+
+		private.Xs = append(private.Xs, item.X)
+		private.Ms = append(private.Ms, DigitsMapping(digits, public.Np))
+		private.DigitsList = append(private.DigitsList, digits)
+		private.Ss = append(private.Ss, item.S)
+	}
+
+	// r = sum(inputs blindings) - sum(outputs blindings), the values difference should be zero
+	r, diff := bint(0), bint(0)
+	for i, item := range items {
+		if i < len(inputs) {
+			r, diff = add(r, item.S), add(diff, item.X)
+		} else {
+			r, diff = sub(r, item.S), sub(diff, item.X)
+		}
+	}
+
+	if diff.Sign() != 0 {
+		return nil, errors.New("invalid items: inputs sum should be equal to the outputs sum")
+	}
+
+	proof, err := ProveRange(public, fs, private)
+	if err != nil {
+		return nil, err
+	}
+
+	V := make([]*bn256.G1, len(items))
+	for i, item := range items {
+		V[i] = public.CommitValue(item.X, item.S)
+	}
+
+	k := MustRandScalar()
+	T := new(bn256.G1).ScalarMult(public.HVec[0], k)
+	c := balanceChallenge(fs, balanceCommitment(V[:len(inputs)], V[len(inputs):]), T)
+
+	return &BalancedRangeProof{
+		ReciprocalProof: proof,
+		T:               T,
+		Z:               add(k, mul(c, r)),
+	}, nil
+}
+
+// VerifyBalancedRange verifies the proof generated by ProveBalancedRange for the inputs and outputs value
+// commitments. If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyBalancedRange(public *ReciprocalPublic, inputs, outputs []*bn256.G1, fs FiatShamirEngine, proof *BalancedRangeProof) error {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return errors.New("invalid commitments: inputs and outputs should not be empty")
+	}
+
+	if proof.ReciprocalProof == nil || proof.T == nil || proof.Z == nil {
+		return errors.New("invalid proof: missing elements")
+	}
+
+	if err := VerifyRangeMulti(public, concatPoints(inputs, outputs), fs, proof.ReciprocalProof); err != nil {
+		return err
+	}
+
+	D := balanceCommitment(inputs, outputs)
+	c := balanceChallenge(fs, D, proof.T)
+
+	// Z*HVec[0] = T + c*D
+	right := new(bn256.G1).ScalarMult(D, c)
+	right.Add(right, proof.T)
+
+	if !pointsEqual(new(bn256.G1).ScalarMult(public.HVec[0], proof.Z), right) {
+		return errors.New("balance proof failed: inputs sum is not equal to the outputs sum")
+	}
+
+	return nil
+}
+
+// balanceCommitment returns sum(inputs) - sum(outputs).
+func balanceCommitment(inputs, outputs []*bn256.G1) *bn256.G1 {
+	res := new(bn256.G1).ScalarBaseMult(bint(0))
+	for _, V := range inputs {
+		res.Add(res, V)
+	}
+
+	for _, V := range outputs {
+		res.Add(res, negPoint(V))
 	}
 
-	V := circuit.CommitCircuit(prv.V[0], prv.Sv[0])
+	return res
+}
+
+func balanceChallenge(fs FiatShamirEngine, D, T *bn256.G1) *big.Int {
+	fs.AddPoint(D)
+	fs.AddPoint(T)
+	return fs.GetChallenge()
+}
+
+// RangeReprover generates new range proofs for the same value under the rerandomized value commitments.
+//
+// An existing ReciprocalProof can not be rerandomized soundly: the value commitment is absorbed into the transcript
+// before the challenge e that defines the circuit, and the resulting circuit commitment is bound to CL, CR, CO, CS and
+// the WNLA rounds by further challenges. Changing the blinding changes all challenges, so the proof has to be
+// regenerated (reusing the proof points would also make proofs linkable). RangeReprover caches the challenge
+// independent part of the circuit to make regeneration cheaper.
+type RangeReprover struct {
+	public   *AggregatedReciprocalPublic
+	private  *ReciprocalPrivate
+	template [][]*big.Int
+}
+
+func NewRangeReprover(public *ReciprocalPublic, private *ReciprocalPrivate) *RangeReprover {
+	aggregated := public.aggregated()
+
+	return &RangeReprover{
+		public:   aggregated,
+		private:  private,
+		template: aggregated.linearTemplate(),
+	}
+}
+
+// Reprove generates the range proof for the value commitment with blinding shifted by delta:
+// VCom' = VCom + delta*HVec[0]. Returns the proof and VCom'. Use empty FiatShamirEngine for call.
+func (r *RangeReprover) Reprove(fs FiatShamirEngine, delta *big.Int) (*ReciprocalProof, *bn256.G1, error) {
+	s := add(r.private.S, delta)
+
+	proof, _, err := proveAggregatedRange(r.public, r.template, fs, &AggregatedReciprocalPrivate{
+		X:           []*big.Int{r.private.X},
+		M:           r.private.M,
+		Digits:      [][]*big.Int{r.private.Digits},
+		S:           []*big.Int{s},
+		NonHiding:   r.private.NonHiding,
+		Rand:        r.private.Rand,
+		DebugChecks: r.private.DebugChecks,
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
 
 	return &ReciprocalProof{
-		ArithmeticCircuitProof: ProveCircuit(circuit, []*bn256.G1{V}, fs, prv),
+		ArithmeticCircuitProof: proof.ArithmeticCircuitProof,
+		V:                      proof.V[0],
+	}, r.public.CommitValue(r.private.X, s), nil
+}
+
+// ReciprocalGeneratorCount returns the generators count of the ReciprocalPublic for Nd digits in the base Np:
+// gLen = Nd for GVec, hLen = Nd+1+StructuralSlots for HVec (the value and the Nd poles are committed in the Nd+1
+// value slots), and paddedLen = the power of two HVec||HVec_ length. Np does not affect the count, because the Np
+// multiplicities share the same Nd+1 slots in the proof commitments, so Np should not exceed Nd+1: the range provers
+// and verifiers return error for the larger base. GVec||GVec_ is padded to the power of two of gLen, so use
+// NewWeightNormLinearPublic(paddedLen, powerOfTwo(gLen)) or any larger lengths to create the generators.
+func ReciprocalGeneratorCount(Nd, Np int) (gLen, hLen, paddedLen int) {
+	gLen = Nd
+	hLen = Nd + 1 + StructuralSlots
+	return gLen, hLen, powerOfTwo(hLen)
+}
+
+func (p *ReciprocalPublic) aggregated() *AggregatedReciprocalPublic {
+	res := &AggregatedReciprocalPublic{
+		G:     p.G,
+		GVec:  p.GVec,
+		HVec:  p.HVec,
+		Nd:    []int{p.Nd},
+		Np:    p.Np,
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
+	}
+
+	res.gTable.inherit(&p.gTable)
+	return res
+}
+
+// aggregatedMulti returns the aggregated parameters for K values of Nd digits each.
+func (p *ReciprocalPublic) aggregatedMulti(K int) *AggregatedReciprocalPublic {
+	res := p.aggregated()
+	res.Nd = make([]int, K)
+	for k := range res.Nd {
+		res.Nd[k] = p.Nd
+	}
+
+	return res
+}
+
+// CommitValue creates a commitment for the value v and blinding s: VCom = v*G + s*HVec[0].
+func (p *AggregatedReciprocalPublic) CommitValue(v *big.Int, s *big.Int) *bn256.G1 {
+	res := p.precomputeG().mul(v)
+	res.Add(res, new(bn256.G1).ScalarMult(p.HVec[0], s))
+	return res
+}
+
+// ProveAggregatedRange generates zero knowledge proof that every committed value lies in [0, Np^Nd[i]) range.
+// The values can have different digits count, but share the base. Use empty FiatShamirEngine for call.
+func ProveAggregatedRange(public *AggregatedReciprocalPublic, fs FiatShamirEngine, private *AggregatedReciprocalPrivate) (*AggregatedReciprocalProof, error) {
+	proof, _, err := proveAggregatedRange(public, public.linearTemplate(), fs, private)
+	return proof, err
+}
+
+// proveAggregatedRange returns the proof and the blindings of the commitments VCom[k] + proof.V[k].
+func proveAggregatedRange(public *AggregatedReciprocalPublic, template [][]*big.Int, fs FiatShamirEngine, private *AggregatedReciprocalPrivate) (*AggregatedReciprocalProof, []*big.Int, error) {
+	if err := public.checkBase(); err != nil {
+		return nil, nil, err
+	}
+
+	public.absorb(fs)
+
+	for k := range private.X {
+		fs.AddPoint(public.CommitValue(private.X[k], private.S[k]))
+	}
+
+	e := reciprocalChallenge(fs, public.Np)
+
+	circuit := public.circuitFromTemplate(template, e)
+
+	prv := &ArithmeticCircuitPrivate{
+		V:         make([][]*big.Int, circuit.K),
+		Sv:        make([]*big.Int, circuit.K),
+		Wo:        private.M,
+		NonHiding: private.NonHiding,
+		Rand:      private.Rand,
+	}
+
+	rCom := make([]*bn256.G1, circuit.K)
+
+	for k := range private.X {
+		r := digitReciprocals(private.Digits[k][:public.Nd[k]], e)
+
+		rBlind := blindingSource(!private.NonHiding, private.Rand)()
+
+		// Pad poles with zeros up to the Nv-1
+		rv := zeroVector(circuit.Nv - 1)
+		copy(rv, r)
+
+		var err error
+		if rCom[k], err = circuit.CommitCircuit(append([]*big.Int{bint(0)}, rv...), rBlind); err != nil {
+			return nil, nil, err
+		}
+
+		if private.DebugChecks {
+			if err := checkPoles(private.Digits[k][:public.Nd[k]], e, r); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		prv.V[k] = append([]*big.Int{private.X[k]}, rv...)
+		prv.Sv[k] = add(private.S[k], rBlind)
+		prv.Wl = append(prv.Wl, private.Digits[k]...)
+		prv.Wr = append(prv.Wr, r...)
+	}
+
+	V := make([]*bn256.G1, circuit.K)
+	for k := range V {
+		var err error
+		if V[k], err = circuit.CommitCircuit(prv.V[k], prv.Sv[k]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	proof, err := ProveCircuit(circuit, V, fs, prv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &AggregatedReciprocalProof{
+		ArithmeticCircuitProof: proof,
 		V:                      rCom,
+	}, prv.Sv, nil
+}
+
+// checkPoles checks the reciprocal gates of the witness: r[j] * (digits[j] + e) = 1 for every digit.
+func checkPoles(digits []*big.Int, e *big.Int, r []*big.Int) error {
+	for j := range digits {
+		if mul(r[j], add(digits[j], e)).Cmp(bint(1)) != 0 {
+			return fmt.Errorf("inconsistent poles: r[%d] * (digits[%d] + e) should be 1", j, j)
+		}
 	}
+
+	return nil
 }
 
-// VerifyRange verifies BP++ reciprocal argument range proof on arithmetic circuits. If err is nil then proof is valid.
+// VerifyAggregatedRange verifies BP++ reciprocal argument range proof for several values. If err is nil then proof is valid.
 // Use empty FiatShamirEngine for call.
-func VerifyRange(public *ReciprocalPublic, V *bn256.G1, fs FiatShamirEngine, proof *ReciprocalProof) error {
-	fs.AddPoint(V)
+func VerifyAggregatedRange(public *AggregatedReciprocalPublic, V []*bn256.G1, fs FiatShamirEngine, proof *AggregatedReciprocalProof) error {
+	circuit, V_, err := public.rangeCircuit(V, fs, proof.V)
+	if err != nil {
+		return err
+	}
 
-	e := fs.GetChallenge()
+	return VerifyCircuit(circuit, V_, fs, proof.ArithmeticCircuitProof)
+}
 
-	Nm := public.Nd
-	No := public.Np
+// rangeCircuit validates the parameters, absorbs the value commitments V and returns the circuit for the derived
+// challenge e and its commitments V + poles commitments.
+func (p *AggregatedReciprocalPublic) rangeCircuit(V []*bn256.G1, fs FiatShamirEngine, poles []*bn256.G1) (*ArithmeticCircuitPublic, []*bn256.G1, error) {
+	if len(V) != len(p.Nd) || len(poles) != len(p.Nd) {
+		return nil, nil, errors.New("invalid length for V vectors: should be equal to the values count")
+	}
 
-	Nv := public.Nd + 1
-	Nl := Nv
-	Nw := public.Nd + public.Nd + public.Np
+	if err := p.checkBase(); err != nil {
+		return nil, nil, err
+	}
 
-	am := oneVector(Nm)
-	Wm := zeroMatrix(Nm, Nw)
+	e := p.deriveE(fs, V)
 
-	for i := 0; i < Nm; i++ {
-		Wm[i][i+Nm] = minus(e)
+	V_ := make([]*bn256.G1, len(V))
+	for k := range V_ {
+		V_[k] = new(bn256.G1).Add(V[k], poles[k])
 	}
 
-	al := zeroVector(Nl)
-	Wl := zeroMatrix(Nl, Nw)
+	return p.circuit(e), V_, nil
+}
 
-	// v
-	base := bint(public.Np)
-	for i := 0; i < Nm; i++ {
-		Wl[0][i] = minus(pow(base, i))
+// FirstChallenge returns the reciprocal challenge e that VerifyAggregatedRange derives for the value commitments
+// V with the same FiatShamirEngine, e.g. to index the proofs. Only the public parameters and V are absorbed before e,
+// so it does not depend on the proof elements and is computed without the verification. Returns nil for the proof
+// that the verification rejects before e is derived or for the proof generated with the other engine (see
+// ArithmeticCircuitProof.Engine). Use empty FiatShamirEngine for call.
+func (p *AggregatedReciprocalPublic) FirstChallenge(V []*bn256.G1, fs FiatShamirEngine, proof *AggregatedReciprocalProof) *big.Int {
+	if proof == nil || len(V) != len(p.Nd) || len(proof.V) != len(p.Nd) {
+		return nil
 	}
 
-	// r
-	for i := 0; i < Nm; i++ {
-		for j := 0; j < Nm; j++ {
-			Wl[i+1][j+Nm] = bint(1)
+	if proof.ArithmeticCircuitProof != nil && checkEngine(fs, proof.ArithmeticCircuitProof) != nil {
+		return nil
+	}
+
+	return p.deriveE(fs, V)
+}
+
+// deriveE absorbs the public parameters and the value commitments V and returns the reciprocal challenge e.
+func (p *AggregatedReciprocalPublic) deriveE(fs FiatShamirEngine, V []*bn256.G1) *big.Int {
+	p.absorb(fs)
+
+	for k := range V {
+		fs.AddPoint(V[k])
+	}
+
+	return reciprocalChallenge(fs, p.Np)
+}
+
+// absorb binds the transcript to the public parameters: the values count, digits counts, base and generators digest,
+// so the proof can not be verified against the parameters with the other base or generators.
+func (p *AggregatedReciprocalPublic) absorb(fs FiatShamirEngine) {
+	fs.AddNumber(bint(len(p.Nd)))
+	for _, nd := range p.Nd {
+		fs.AddNumber(bint(nd))
+	}
+
+	fs.AddNumber(bint(p.Np))
+	fs.AddNumber(p.generatorsDigest())
+}
+
+// generatorsDigest returns HashToScalar of the encoded G, GVec, HVec, GVec_ and HVec_.
+func (p *AggregatedReciprocalPublic) generatorsDigest() *big.Int {
+	enc := &encoder{}
+	enc.point(p.G)
+	enc.points(p.GVec)
+	enc.points(p.HVec)
+	enc.points(p.GVec_)
+	enc.points(p.HVec_)
+	return HashToScalar(enc.buf)
+}
+
+// reciprocalChallenge returns the challenge e such that e+j != 0 for every pole j in [0, Np), otherwise
+// the poles 1/(e+j) (and reciprocals of the corresponding digits) are not defined. On collision the next
+// challenge is derived, so prover and verifier get the same e.
+func reciprocalChallenge(fs FiatShamirEngine, Np int) *big.Int {
+	for {
+		e := fs.GetChallenge()
+
+		collision := false
+		for j := 0; j < Np; j++ {
+			if add(e, bint(j)).Sign() == 0 {
+				collision = true
+			}
+		}
+
+		if !collision {
+			return e
 		}
 	}
+}
 
-	for i := 0; i < Nm; i++ {
-		Wl[i+1][i+Nm] = bint(0)
+// circuit builds the reciprocal arithmetic circuit for the challenge e.
+func (p *AggregatedReciprocalPublic) circuit(e *big.Int) *ArithmeticCircuitPublic {
+	return p.circuitFromTemplate(p.linearTemplate(), e)
+}
+
+// dimensions returns Nm and Nv of the reciprocal circuit.
+func (p *AggregatedReciprocalPublic) dimensions() (Nm, Nv int) {
+	maxNd := 0
+	for _, nd := range p.Nd {
+		Nm += nd
+		if nd > maxNd {
+			maxNd = nd
+		}
+	}
+
+	return Nm, maxNd + 1
+}
+
+// checkBase checks that the Np multiplicities fit into the Nv = max(Nd)+1 value slots, see ReciprocalGeneratorCount.
+func (p *AggregatedReciprocalPublic) checkBase() error {
+	if _, Nv := p.dimensions(); p.Np > Nv {
+		return fmt.Errorf("invalid base: Np = %d should not exceed max(Nd)+1 = %d", p.Np, Nv)
 	}
 
+	return nil
+}
+
+// linearTemplate returns the challenge independent part of the Wl matrix: the digits reconstruction rows
+// and the poles sum rows without the public poles.
+func (p *AggregatedReciprocalPublic) linearTemplate() [][]*big.Int {
+	Nm, Nv := p.dimensions()
+	Wl := zeroMatrix(Nv*len(p.Nd), Nm+Nm+p.Np)
+
+	base := bint(p.Np)
+
+	// offset of the first digit of the k-th value in the witness
+	offset := 0
+
+	for k, nd := range p.Nd {
+		// v
+		for i := 0; i < nd; i++ {
+			Wl[k*Nv][offset+i] = minus(pow(base, i))
+		}
+
+		// r
+		for i := 0; i < nd; i++ {
+			for j := 0; j < Nm; j++ {
+				if j != offset+i {
+					Wl[k*Nv+i+1][j+Nm] = bint(1)
+				}
+			}
+		}
+
+		// rows for the padded poles stay zero
+
+		offset += nd
+	}
+
+	return Wl
+}
+
+// circuitFromTemplate builds the reciprocal arithmetic circuit for the challenge e using the copy of linearTemplate result.
+func (p *AggregatedReciprocalPublic) circuitFromTemplate(template [][]*big.Int, e *big.Int) *ArithmeticCircuitPublic {
+	K := len(p.Nd)
+	Nm, Nv := p.dimensions()
+
+	No := p.Np
+
+	Nl := Nv * K
+	Nw := Nm + Nm + No
+
+	am := oneVector(Nm)
+	Wm := zeroMatrix(Nm, Nw)
+
 	for i := 0; i < Nm; i++ {
-		for j := 0; j < No; j++ {
-			Wl[i+1][j+2*Nm] = minus(inv(add(e, bint(j))))
+		Wm[i][i+Nm] = minus(e)
+	}
+
+	al := zeroVector(Nl)
+	Wl := make([][]*big.Int, Nl)
+	for i := range Wl {
+		Wl[i] = append([]*big.Int{}, template[i]...)
+	}
+
+	poles := make([]*big.Int, No)
+	for j := range poles {
+		poles[j] = minus(inv(add(e, bint(j))))
+	}
+
+	for k, nd := range p.Nd {
+		for i := 0; i < nd; i++ {
+			for j := 0; j < No; j++ {
+				Wl[k*Nv+i+1][j+2*Nm] = poles[j]
+			}
 		}
 	}
 
-	circuit := &ArithmeticCircuitPublic{
+	res := &ArithmeticCircuitPublic{
 		Nm:   Nm,
 		Nl:   Nl,
 		Nv:   Nv,
 		Nw:   Nw,
 		No:   No,
-		K:    1,
-		G:    public.G,
-		GVec: public.GVec,
-		HVec: public.HVec,
+		K:    K,
+		G:    p.G,
+		GVec: p.GVec,
+		HVec: p.HVec,
 		Wm:   Wm,
 		Wl:   Wl,
 		Am:   am,
@@ -197,9 +1039,10 @@ func VerifyRange(public *ReciprocalPublic, V *bn256.G1, fs FiatShamirEngine, pro
 
 			return nil
 		},
-		GVec_: public.GVec_,
-		HVec_: public.HVec_,
+		GVec_: p.GVec_,
+		HVec_: p.HVec_,
 	}
 
-	return VerifyCircuit(circuit, []*bn256.G1{new(bn256.G1).Add(V, proof.V)}, fs, proof.ArithmeticCircuitProof)
+	res.gTable.inherit(&p.gTable)
+	return res
 }
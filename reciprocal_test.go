@@ -5,8 +5,14 @@
 package bulletproofs
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/cloudflare/bn256"
 	"github.com/davecgh/go-spew/spew"
+	"io"
 	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -24,31 +30,1214 @@ func TestReciprocalRangeProofUInt64(t *testing.T) {
 	Nd := 16 // digits size
 	Np := 16 // base size
 
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	private := &ReciprocalPrivate{
+		X:      X,
+		M:      m,
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	VCom := public.CommitValue(private.X, private.S)
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+	spew.Dump(proof)
+
+	if err := VerifyRange(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
+
+func TestCommitValueFromDigits(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	s := MustRandScalar()
+
+	if !bytes.Equal(CommitValueFromDigits(public, digits, 16, s).Marshal(), public.CommitValue(ReconstructFromDigits(digits, 16), s).Marshal()) {
+		panic("test failed")
+	}
+
+	if !bytes.Equal(CommitValueFromDigits(public, digits, 16, s).Marshal(), public.CommitValue(new(big.Int).SetUint64(x), s).Marshal()) {
+		panic("test failed")
+	}
+}
+
+func TestAggregatedReciprocalRangeProof(t *testing.T) {
+	x32 := uint64(0xab4f0540)
+	x64 := uint64(0xab4f0540ab4f0540)
+
+	d32 := UInt64Hex(x32)[:8] // 32-bit value is encoded in 8 hex digits
+	d64 := UInt64Hex(x64)     // 16
+
+	Nd := []int{8, 16}
+	Np := 16
+
+	Nm := 8 + 16
+	Nv := 16 + 1
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 32)
+
+	public := &AggregatedReciprocalPublic{
+		G:     wnlaPublic.G,
+		GVec:  wnlaPublic.GVec[:Nm],
+		HVec:  wnlaPublic.HVec[:Nv+9],
+		Nd:    Nd,
+		Np:    Np,
+		GVec_: wnlaPublic.GVec[Nm:],
+		HVec_: wnlaPublic.HVec[Nv+9:],
+	}
+
+	private := &AggregatedReciprocalPrivate{
+		X:      []*big.Int{new(big.Int).SetUint64(x32), new(big.Int).SetUint64(x64)},
+		M:      HexMapping(append(append([]*big.Int{}, d32...), d64...)),
+		Digits: [][]*big.Int{d32, d64},
+		S:      []*big.Int{MustRandScalar(), MustRandScalar()},
+	}
+
+	V := []*bn256.G1{
+		public.CommitValue(private.X[0], private.S[0]),
+		public.CommitValue(private.X[1], private.S[1]),
+	}
+
+	proof, err := ProveAggregatedRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyAggregatedRange(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// 64-bit value presented as 60-bit one
+	public.Nd = []int{8, 15}
+	public.GVec = wnlaPublic.GVec[:23]
+	public.GVec_ = wnlaPublic.GVec[23:]
+	public.HVec = wnlaPublic.HVec[:16+9]
+	public.HVec_ = wnlaPublic.HVec[16+9:]
+
+	private.Digits = [][]*big.Int{d32, d64[:15]}
+	private.M = HexMapping(append(append([]*big.Int{}, d32...), d64[:15]...))
+
+	if proof, err = ProveAggregatedRange(public, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyAggregatedRange(public, V, NewKeccakFS(), proof); err == nil {
+		panic("proof for 64-bit value presented as 60-bit should fail")
+	}
+}
+
+func TestReciprocalRangeProofMultiValue(t *testing.T) {
+	x1 := uint64(0xab4f0540ab4f0540)
+	x2 := uint64(0x1234567890abcdef)
+
+	d1 := UInt64Hex(x1)
+	d2 := UInt64Hex(x2)
+
+	Nd := 16
+	Np := 16
+	K := 2
+
+	public := newTestReciprocalPublicMulti(t, Nd, Np, K)
+
+	private := &ReciprocalPrivate{
+		Xs:         []*big.Int{new(big.Int).SetUint64(x1), new(big.Int).SetUint64(x2)},
+		Ms:         [][]*big.Int{HexMapping(d1), HexMapping(d2)},
+		DigitsList: [][]*big.Int{d1, d2},
+		Ss:         []*big.Int{MustRandScalar(), MustRandScalar()},
+	}
+
+	V := []*bn256.G1{
+		public.CommitValue(private.Xs[0], private.Ss[0]),
+		public.CommitValue(private.Xs[1], private.Ss[1]),
+	}
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(proof.Vs) != K {
+		panic("proof should contain one poles commitment per value")
+	}
+
+	if err := VerifyRangeMulti(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRange(public, V[0], NewKeccakFS(), proof); err == nil {
+		panic("multi-value proof should not be accepted as a single value proof")
+	}
+
+	// Swapped value commitments
+	if err := VerifyRangeMulti(public, []*bn256.G1{V[1], V[0]}, NewKeccakFS(), proof); err == nil {
+		panic("proof for swapped commitments should fail")
+	}
+}
+
+func TestOpenValueCommit(t *testing.T) {
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	x := bint(42)
+	s := MustRandScalar()
+	com := public.CommitValue(x, s)
+
+	if !OpenValueCommit(public, com, x, s) {
+		panic("commitment should open")
+	}
+
+	if OpenValueCommit(public, com, bint(43), s) {
+		panic("commitment should not open to other value")
+	}
+
+	if OpenValueCommit(public, com, x, add(s, bint(1))) {
+		panic("commitment should not open with other blinding")
+	}
+}
+
+func TestRangeReprover(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	reprover := NewRangeReprover(public, private)
+
+	delta := MustRandScalar()
+
+	proof, VCom, err := reprover.Reprove(NewKeccakFS(), delta)
+	if err != nil {
+		panic(err)
+	}
+
+	expected := new(bn256.G1).Add(public.CommitValue(private.X, private.S), new(bn256.G1).ScalarMult(public.HVec[0], delta))
+	if !bytes.Equal(VCom.Marshal(), expected.Marshal()) {
+		panic("rerandomized commitment mismatch")
+	}
+
+	if err := VerifyRange(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRange(public, public.CommitValue(private.X, private.S), NewKeccakFS(), proof); err == nil {
+		panic("proof should not verify for the original commitment")
+	}
+}
+
+func TestReciprocalChallengeCollision(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	// The first challenge e = -4 collides with the pole 4 (and the digit 4 of the value)
+	challenges := []*big.Int{minus(bint(4))}
+	for i := 0; i < 64; i++ {
+		challenges = append(challenges, MustRandScalar())
+	}
+
+	proof, err := ProveRange(public, &riggedFS{challenges: challenges}, private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRange(public, public.CommitValue(private.X, private.S), &riggedFS{challenges: challenges}, proof); err != nil {
+		panic(err)
+	}
+}
+
+func TestCommitSession(t *testing.T) {
+	public := newTestReciprocalPublic(t, 16, 16)
+
+	session := NewCommitSession(public)
+
+	for _, v := range []*big.Int{bint(0), bint(1), minus(bint(1)), MustRandScalar(), MustRandScalar()} {
+		s := MustRandScalar()
+		if !bytes.Equal(session.Commit(v, s).Marshal(), public.CommitValue(v, s).Marshal()) {
+			panic("test failed")
+		}
+	}
+}
+
+func BenchmarkCommitValue(b *testing.B) {
+	public := newTestReciprocalPublic(b, 16, 16)
+
+	v, s := MustRandScalar(), MustRandScalar()
+
+	b.Run("ScalarMult", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < 10000; i++ {
+				res := new(bn256.G1).ScalarMult(public.G, v)
+				res.Add(res, new(bn256.G1).ScalarMult(public.HVec[0], s))
+			}
+		}
+	})
+
+	b.Run("precomputeG", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < 10000; i++ {
+				public.CommitValue(v, s)
+			}
+		}
+	})
+
+	b.Run("session", func(b *testing.B) {
+		session := NewCommitSession(public)
+		b.ResetTimer()
+
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < 10000; i++ {
+				session.Commit(v, s)
+			}
+		}
+	})
+}
+
+func TestVerifyRangeMarshaled(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	vBytes := public.CommitValue(private.X, private.S).Marshal()
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRangeMarshaled(public, vBytes, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Point is not on the curve
+	corrupted := append([]byte{}, vBytes...)
+	corrupted[len(corrupted)-1] ^= 1
+
+	// Not canonical encoding of the same point: x + p
+	fieldP, _ := new(big.Int).SetString("21888242871839275222246405745257275088696311157297823662689037794645226208583", 10)
+	notCanonical := append([]byte{}, vBytes...)
+	new(big.Int).Add(new(big.Int).SetBytes(vBytes[:32]), fieldP).FillBytes(notCanonical[:32])
+
+	for _, data := range [][]byte{corrupted, notCanonical, vBytes[:32], make([]byte, 64)} {
+		if err := VerifyRangeMarshaled(public, data, NewKeccakFS(), proof); err == nil {
+			panic("invalid commitment encoding should fail")
+		}
+	}
+}
+
+func TestProveRangeWithBlinding(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	VCom := public.CommitValue(private.X, private.S)
+
+	proof, blinding, err := ProveRangeWithBlinding(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRange(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Repeat the challenge derivation to get the poles committed in proof.V
+	fs := NewKeccakFS()
+	public.aggregated().absorb(fs)
+	fs.AddPoint(VCom)
+	e := reciprocalChallenge(fs, Np)
+
+	r := make([]*big.Int, Nd)
+	for j := range r {
+		r[j] = inv(add(digits[j], e))
+	}
+
+	// VCom + proof.V = x*G + blinding*HVec[0] + <r, HVec[9:]>
+	expected := public.CommitValue(private.X, blinding)
+	expected.Add(expected, vectorPointScalarMul(public.HVec[9:], r))
+
+	if !bytes.Equal(expected.Marshal(), new(bn256.G1).Add(VCom, proof.V).Marshal()) {
+		panic("blinding does not reconstruct the commitment")
+	}
+}
+
+func TestVerifyRangeFingerprint(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	VCom := public.CommitValue(private.X, private.S)
+
+	verify := func(fs FiatShamirEngine, proof *ReciprocalProof) [32]byte {
+		if err := VerifyRange(public, VCom, fs, proof); err != nil {
+			panic(err)
+		}
+
+		return fs.(interface{ Fingerprint() [32]byte }).Fingerprint()
+	}
+
+	for _, engine := range []func() FiatShamirEngine{NewKeccakFS, func() FiatShamirEngine { return NewMerlinFS("range") }} {
+		proof1, err := ProveRange(public, engine(), private)
+		if err != nil {
+			panic(err)
+		}
+
+		proof2, err := ProveRange(public, engine(), private)
+		if err != nil {
+			panic(err)
+		}
+
+		f1 := verify(engine(), proof1)
+
+		if f1 != verify(engine(), proof1) {
+			panic("fingerprints of the same verification should be equal")
+		}
+
+		if f1 == verify(engine(), proof2) {
+			panic("fingerprints of different proofs should differ")
+		}
+	}
+}
+
+func TestBatchVerifyRangeMixed(t *testing.T) {
+	// Poles are committed along with the digits, so the base should not exceed Nd+1:
+	// 32-bit values use 16 digits in base 4, 64-bit values use 16 digits in base 16.
+	item := func(Nd, Np int, x uint64) RangeVerifyItem {
+		public := newTestReciprocalPublic(t, Nd, Np)
+
+		digits := make([]*big.Int, Nd)
+		m := zeroVector(Np)
+		for i, v := 0, x; i < Nd; i++ {
+			digits[i] = new(big.Int).SetUint64(v % uint64(Np))
+			m[v%uint64(Np)] = add(m[v%uint64(Np)], bint(1))
+			v /= uint64(Np)
+		}
+
+		private := &ReciprocalPrivate{
+			X:      new(big.Int).SetUint64(x),
+			M:      m,
+			Digits: digits,
+			S:      MustRandScalar(),
+		}
+
+		proof, err := ProveRange(public, NewKeccakFS(), private)
+		if err != nil {
+			panic(err)
+		}
+
+		return RangeVerifyItem{
+			Public: public,
+			V:      public.CommitValue(private.X, private.S),
+			FS:     NewKeccakFS(),
+			Proof:  proof,
+		}
+	}
+
+	items := []RangeVerifyItem{
+		item(16, 4, 0xab4f0540),
+		item(16, 16, 0xab4f0540ab4f0540),
+		item(16, 4, 0x12345678),
+	}
+
+	if err := BatchVerifyRangeMixed(items); err != nil {
+		panic(err)
+	}
+
+	// 64-bit value presented as 32-bit one
+	invalid := item(16, 4, 0xab4f0540ab4f0540)
+
+	for i := range items {
+		items[i].FS = NewKeccakFS()
+	}
+
+	items = append(items[:2], invalid, items[2])
+
+	var batchErr *BatchVerifyError
+	if err := BatchVerifyRangeMixed(items); !errors.As(err, &batchErr) || batchErr.Index != 2 {
+		panic("batch verification should fail for the item 2")
+	}
+	for _, workers := range []int{-1, 1, 2, 3, 8} {
+		for i := range items {
+			items[i].FS = NewKeccakFS()
+		}
+
+		if err := BatchVerifyRangeMixedN(items, workers); !errors.As(err, &batchErr) || batchErr.Index != 2 {
+			panic(fmt.Sprintf("batch verification with %d workers should fail for the item 2", workers))
+		}
+
+		valid := append(append([]RangeVerifyItem{}, items[:2]...), items[3:]...)
+		for i := range valid {
+			valid[i].FS = NewKeccakFS()
+		}
+
+		if err := BatchVerifyRangeMixedN(valid, workers); err != nil {
+			panic(err)
+		}
+	}
+
+	// The tampered final WNLA scalar is caught by the combined check
+	valid := append(append([]RangeVerifyItem{}, items[:2]...), items[3:]...)
+	for i := range valid {
+		valid[i].FS = NewKeccakFS()
+	}
+
+	wnla := *valid[0].Proof.WNLA
+	wnla.L = append([]*big.Int{add(wnla.L[0], bint(1))}, wnla.L[1:]...)
+	circuit := *valid[0].Proof.ArithmeticCircuitProof
+	circuit.WNLA = &wnla
+	valid[0].Proof = &ReciprocalProof{ArithmeticCircuitProof: &circuit, V: valid[0].Proof.V}
+
+	if err := BatchVerifyRangeMixed(valid); !errors.As(err, &batchErr) || batchErr.Index != 0 {
+		panic("batch verification should fail for the tampered item 0")
+	}
+}
+
+func TestDebugChecksPoles(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	Nd, Np := 16, 16
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	private := &ReciprocalPrivate{
+		X:           new(big.Int).SetUint64(x),
+		M:           HexMapping(digits),
+		Digits:      digits,
+		S:           MustRandScalar(),
+		DebugChecks: true,
+	}
+
+	VCom := public.CommitValue(private.X, private.S)
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRange(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// The digit -e has no pole: the batch inversion of the zero gives zero poles
+	e := public.aggregated().deriveE(NewKeccakFS(), []*bn256.G1{VCom})
+	private.Digits = append([]*big.Int{minus(e)}, digits[1:]...)
+
+	if _, err = ProveRange(public, NewKeccakFS(), private); err == nil || !strings.Contains(err.Error(), "inconsistent poles") {
+		panic("inconsistent poles should be rejected")
+	}
+
+	// Without the checks the invalid proof is produced
+	private.DebugChecks = false
+
+	if proof, err = ProveRange(public, NewKeccakFS(), private); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRange(public, VCom, NewKeccakFS(), proof); err == nil {
+		panic("proof for the inconsistent poles should fail")
+	}
+}
+
+func TestReciprocalRangeProofUInt64Base4(t *testing.T) {
+	x := uint64(0xab4f0540ab4f0540)
+
+	Nd, Np := 32, 4
+	digits := UInt64Digits(x, Np, Nd)
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      DigitsMapping(digits, Np),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRange(public, public.CommitValue(private.X, private.S), NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+}
+
+func TestReciprocalTranscriptBinding(t *testing.T) {
+	x := uint64(0xab4f)
+	Nd := 16
+
+	wnlaPublic := NewWeightNormLinearPublic(32, 16)
+
+	newPublic := func(Np int, gens *WeightNormLinearPublic) *ReciprocalPublic {
+		return testReciprocalPublicFrom(gens, Nd, Np, 1)
+	}
+
+	public16 := newPublic(16, wnlaPublic)
+	digits := UInt64Digits(x, 16, Nd)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      DigitsMapping(digits, 16),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	proof, err := ProveRange(public16, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	V := public16.CommitValue(private.X, private.S)
+
+	if err := VerifyRange(public16, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// The same commitment and generators, but base 4
+	if err := VerifyRange(newPublic(4, wnlaPublic), V, NewKeccakFS(), proof); err == nil {
+		panic("proof for base 16 should fail for base 4 parameters")
+	}
+
+	// The same G, GVec and HVec, but other WNLA padding generators
+	other := NewWeightNormLinearPublic(32, 16)
+	other.G = wnlaPublic.G
+	copy(other.GVec[:Nd], wnlaPublic.GVec[:Nd])
+	copy(other.HVec[:Nd+1+9], wnlaPublic.HVec[:Nd+1+9])
+
+	if err := VerifyRange(newPublic(16, other), V, NewKeccakFS(), proof); err == nil {
+		panic("proof should fail for the other generators")
+	}
+}
+
+func TestProveRangeFresh(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	x := new(big.Int).SetUint64(0xab4f0540ab4f0540)
+
+	proof, V, s, err := ProveRangeFresh(public, NewKeccakFS(), x)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := VerifyRange(public, V, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	if !OpenValueCommit(public, V, x, s) {
+		panic("commitment should open to the value with the returned blinding")
+	}
+
+	if _, _, _, err := ProveRangeFresh(public, NewKeccakFS(), new(big.Int).Lsh(bint(1), 64)); err == nil {
+		panic("value out of range should be rejected")
+	}
+}
+
+func TestFindRangeCommitment(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	x := new(big.Int).SetUint64(0xab4f0540ab4f0540)
+
+	proof, V, s, err := ProveRangeFresh(public, NewKeccakFS(), x)
+	if err != nil {
+		panic(err)
+	}
+
+	candidates := []*bn256.G1{
+		public.CommitValue(add(x, bint(1)), s),
+		V,
+		public.CommitValue(x, add(s, bint(1))),
+	}
+
+	i, err := FindRangeCommitment(public, candidates, NewKeccakFS, proof)
+	if err != nil {
+		panic(err)
+	}
+
+	if i != 1 {
+		panic(fmt.Sprintf("unexpected candidate %d", i))
+	}
+
+	if i, err := FindRangeCommitment(public, []*bn256.G1{candidates[0], candidates[2]}, NewKeccakFS, proof); err == nil || i != -1 {
+		panic("proof should not match any candidate")
+	}
+}
+
+func TestRangeMultiBlinding(t *testing.T) {
+	Nd, Np := 16, 16
+
 	wnlaPublic := NewWeightNormLinearPublic(32, 16)
 
+	public := testReciprocalPublicFrom(wnlaPublic, Nd, Np, 1)
+
+	// Two parties contribute blindings on their own generators
+	hs := []*bn256.G1{wnlaPublic.HVec[30], wnlaPublic.HVec[31]}
+	blindings := []*big.Int{MustRandScalar(), MustRandScalar()}
+
+	x := new(big.Int).SetUint64(0xab4f0540ab4f0540)
+	VCom := public.CommitValueMulti(x, blindings, hs)
+
+	proof, err := ProveRangeMultiBlinding(public, NewKeccakFS(), x, blindings, hs)
+	if err != nil {
+		panic(err)
+	}
+
+	if !pointsEqual(proof.Rebased, public.CommitValue(x, add(blindings[0], blindings[1]))) {
+		panic("rebased commitment should use the summed blinding")
+	}
+
+	if err = VerifyRangeMultiBlinding(public, VCom, hs, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	other := public.CommitValueMulti(add(x, bint(1)), blindings, hs)
+	if err = VerifyRangeMultiBlinding(public, other, hs, NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid for the other value")
+	}
+
+	if err = VerifyRangeMultiBlinding(public, VCom, []*bn256.G1{hs[1], hs[0]}, NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid for the other generators")
+	}
+}
+
+func TestBalancedRange(t *testing.T) {
+	Nd, Np, K := 16, 16, 4
+
+	public := newTestReciprocalPublicMulti(t, Nd, Np, K)
+
+	item := func(x uint64) RangeItem {
+		return RangeItem{X: new(big.Int).SetUint64(x), S: MustRandScalar()}
+	}
+
+	commit := func(items []RangeItem) []*bn256.G1 {
+		res := make([]*bn256.G1, len(items))
+		for i := range items {
+			res[i] = public.CommitValue(items[i].X, items[i].S)
+		}
+		return res
+	}
+
+	inputs := []RangeItem{item(1000), item(234)}
+	outputs := []RangeItem{item(1200), item(34)}
+
+	proof, err := ProveBalancedRange(public, NewKeccakFS(), inputs, outputs)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyBalancedRange(public, commit(inputs), commit(outputs), NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// Outputs exceed the inputs
+	unbalanced := []RangeItem{outputs[0], item(35)}
+
+	if _, err = ProveBalancedRange(public, NewKeccakFS(), inputs, unbalanced); err == nil {
+		panic("unbalanced items should not be proven")
+	}
+
+	if err = VerifyBalancedRange(public, commit(inputs), commit(unbalanced), NewKeccakFS(), proof); err == nil {
+		panic("proof should not be valid for the unbalanced outputs")
+	}
+}
+
+func TestNonHidingRangeProof(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	private := &ReciprocalPrivate{
+		X:         new(big.Int).SetUint64(x),
+		M:         HexMapping(digits),
+		Digits:    digits,
+		S:         bint(0),
+		NonHiding: true,
+	}
+
+	V := public.CommitValue(private.X, private.S)
+	if !pointsEqual(V, new(bn256.G1).ScalarMult(public.G, private.X)) {
+		panic("commitment should be plain x*G")
+	}
+
+	proof1, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	proof2, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(proof1.Marshal(), proof2.Marshal()) {
+		panic("non-hiding proofs of the same witness should be identical")
+	}
+
+	if err = VerifyRange(public, V, NewKeccakFS(), proof1); err != nil {
+		panic(err)
+	}
+
+	// Hiding is enabled by default
+	private.NonHiding = false
+
+	proof3, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if bytes.Equal(proof1.Marshal(), proof3.Marshal()) {
+		panic("hiding proofs should be randomized")
+	}
+}
+
+func TestReciprocalGeneratorCount(t *testing.T) {
+	for _, c := range []struct{ Nd, Np, gLen, hLen, paddedLen int }{
+		{16, 16, 16, 26, 32}, // uint64 in hex digits
+		{32, 16, 32, 42, 64}, // uint128 in hex digits
+	} {
+		gLen, hLen, paddedLen := ReciprocalGeneratorCount(c.Nd, c.Np)
+		if gLen != c.gLen || hLen != c.hLen || paddedLen != c.paddedLen {
+			panic(fmt.Sprintf("Nd = %d: unexpected counts %d, %d, %d", c.Nd, gLen, hLen, paddedLen))
+		}
+
+		wnlaPublic := NewWeightNormLinearPublic(paddedLen, powerOfTwo(gLen))
+
+		public := &ReciprocalPublic{
+			G:     wnlaPublic.G,
+			GVec:  wnlaPublic.GVec[:gLen],
+			HVec:  wnlaPublic.HVec[:hLen],
+			Nd:    c.Nd,
+			Np:    c.Np,
+			GVec_: wnlaPublic.GVec[gLen:],
+			HVec_: wnlaPublic.HVec[hLen:],
+		}
+
+		x := new(big.Int).Lsh(bint(0xab4f0540), uint(4*c.Nd-32))
+
+		proof, V, _, err := ProveRangeFresh(public, NewKeccakFS(), x)
+		if err != nil {
+			panic(err)
+		}
+
+		if err = VerifyRange(public, V, NewKeccakFS(), proof); err != nil {
+			panic(err)
+		}
+	}
+
+	// The Np = 16 multiplicities do not fit into the Nd+1 = 9 value slots
+	gLen, hLen, paddedLen := ReciprocalGeneratorCount(8, 16)
+	wnlaPublic := NewWeightNormLinearPublic(paddedLen, powerOfTwo(gLen))
+
 	public := &ReciprocalPublic{
 		G:     wnlaPublic.G,
-		GVec:  wnlaPublic.GVec[:Nd],
-		HVec:  wnlaPublic.HVec[:Nd+1+9],
-		Nd:    Nd,
-		Np:    Np,
-		GVec_: wnlaPublic.GVec[Nd:],
-		HVec_: wnlaPublic.HVec[Nd+1+9:],
+		GVec:  wnlaPublic.GVec[:gLen],
+		HVec:  wnlaPublic.HVec[:hLen],
+		Nd:    8,
+		Np:    16,
+		GVec_: wnlaPublic.GVec[gLen:],
+		HVec_: wnlaPublic.HVec[hLen:],
+	}
+
+	if _, _, _, err := ProveRangeFresh(public, NewKeccakFS(), bint(0xab4f0540)); err == nil || !strings.Contains(err.Error(), "invalid base") {
+		panic("base larger than Nd+1 should be rejected by the prover")
+	}
+
+	proof := &ReciprocalProof{ArithmeticCircuitProof: &ArithmeticCircuitProof{}, V: MustRandPoint()}
+	if err := VerifyRange(public, MustRandPoint(), NewKeccakFS(), proof); err == nil || !strings.Contains(err.Error(), "invalid base") {
+		panic("base larger than Nd+1 should be rejected by the verifier")
+	}
+}
+
+func TestVerifyRangeReader(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	proof, V, _, err := ProveRangeFresh(public, NewKeccakFS(), new(big.Int).SetUint64(0xab4f0540ab4f0540))
+	if err != nil {
+		panic(err)
+	}
+
+	data := proof.Marshal()
+
+	// Two proofs are sent in small chunks one after another
+	r, w := io.Pipe()
+	go func() {
+		for _, chunk := range [][]byte{data, data} {
+			for len(chunk) > 0 {
+				n := min(len(chunk), 7)
+				if _, err := w.Write(chunk[:n]); err != nil {
+					panic(err)
+				}
+				chunk = chunk[n:]
+			}
+		}
+		w.Close()
+	}()
+
+	if err = VerifyRangeReader(public, V, r, NewKeccakFS()); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRangeReader(public, new(bn256.G1).Add(V, public.G), r, NewKeccakFS()); err == nil {
+		panic("proof should not be valid for the other commitment")
+	}
+
+	if err = VerifyRangeReader(public, V, bytes.NewReader(data[:len(data)-1]), NewKeccakFS()); err == nil {
+		panic("truncated proof should be rejected")
+	}
+}
+
+func TestReciprocalWitness(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	x := new(big.Int).SetUint64(MustRandScalar().Uint64())
+
+	digits, m, err := ReciprocalWitness(x, Np, Nd)
+	if err != nil {
+		panic(err)
 	}
 
 	private := &ReciprocalPrivate{
-		X:      X,
+		X:      x,
 		M:      m,
 		Digits: digits,
 		S:      MustRandScalar(),
 	}
 
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRange(public, public.CommitValue(private.X, private.S), NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	if _, _, err = ReciprocalWitness(new(big.Int).Lsh(bint(1), 64), Np, Nd); err == nil {
+		panic("value out of range should be rejected")
+	}
+}
+
+// mockExternalCommitment is the opaque external commitment verified out-of-band
+type mockExternalCommitment []byte
+
+func (m mockExternalCommitment) Scheme() string {
+	return "mock-secp256k1-pedersen"
+}
+
+func (m mockExternalCommitment) Bytes() []byte {
+	return m
+}
+
+func TestRangeExternalCommitment(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	x := uint64(0xab4f0540ab4f0540)
+	digits := UInt64Hex(x)
+
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
 	VCom := public.CommitValue(private.X, private.S)
 
-	proof := ProveRange(public, NewKeccakFS(), private)
-	spew.Dump(proof)
+	ext := mockExternalCommitment{0x02, 0xab, 0x4f, 0x05, 0x40}
 
-	if err := VerifyRange(public, VCom, NewKeccakFS(), proof); err != nil {
+	proof, err := ProveRangeExternal(public, NewKeccakFS(), ext, private)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRangeExternal(public, VCom, ext, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRangeExternal(public, VCom, mockExternalCommitment{0x03, 0xab, 0x4f, 0x05, 0x40}, NewKeccakFS(), proof); err == nil {
+		panic("proof for the other external commitment should fail")
+	}
+
+	if err = VerifyRange(public, VCom, NewKeccakFS(), proof); err == nil {
+		panic("proof bound to the external commitment should fail without it")
+	}
+}
+
+func TestVerifyRangeBatchSum(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	values := []int{15, 20, 65}
+	total, totalBlinding := bint(0), bint(0)
+
+	commitments := make([]*bn256.G1, len(values))
+	proofs := make([]*ReciprocalProof, len(values))
+
+	for i, v := range values {
+		proof, V, s, err := ProveRangeFresh(public, NewKeccakFS(), bint(v))
+		if err != nil {
+			panic(err)
+		}
+
+		commitments[i], proofs[i] = V, proof
+		total, totalBlinding = add(total, bint(v)), add(totalBlinding, s)
+	}
+
+	target := public.CommitValue(total, totalBlinding)
+
+	if err := VerifyRangeBatchSum(public, commitments, target, proofs, NewKeccakFS); err != nil {
+		panic(err)
+	}
+
+	// Single commitment is off by one
+	off := append([]*bn256.G1{}, commitments...)
+	off[1] = new(bn256.G1).Add(off[1], public.G)
+
+	if err := VerifyRangeBatchSum(public, off, target, proofs, NewKeccakFS); !errors.Is(err, ErrRangeSumMismatch) {
+		panic(fmt.Sprintf("expected sum mismatch, got %v", err))
+	}
+
+	// The sum matches, but the commitment does not match its proof
+	off[2] = new(bn256.G1).Add(off[2], negPoint(public.G))
+
+	var batchErr *BatchVerifyError
+	if err := VerifyRangeBatchSum(public, off, target, proofs, NewKeccakFS); !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		panic(fmt.Sprintf("expected proof 1 to fail, got %v", err))
+	}
+}
+
+func TestVerifyRangeCoords(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	proof, V, _, err := ProveRangeFresh(public, NewKeccakFS(), bint(1000))
+	if err != nil {
+		panic(err)
+	}
+
+	data := V.Marshal()
+	cx, cy := new(big.Int).SetBytes(data[:32]), new(big.Int).SetBytes(data[32:])
+
+	if err = VerifyRangeCoords(public, cx, cy, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	if err = VerifyRangeCoords(public, cx, new(big.Int).Add(cy, bint(1)), NewKeccakFS(), proof); err == nil {
+		panic("off-curve commitment should be rejected")
+	}
+
+	if err = VerifyRangeCoords(public, bint(0), bint(0), NewKeccakFS(), proof); err == nil {
+		panic("identity commitment should be rejected")
+	}
+}
+
+func TestSameStatement(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	prove := func(x uint64, s *big.Int) (*ReciprocalProof, *bn256.G1) {
+		digits := UInt64Hex(x)
+
+		private := &ReciprocalPrivate{
+			X:      new(big.Int).SetUint64(x),
+			M:      HexMapping(digits),
+			Digits: digits,
+			S:      s,
+		}
+
+		proof, err := ProveRange(public, NewKeccakFS(), private)
+		if err != nil {
+			panic(err)
+		}
+
+		return proof, public.CommitValue(private.X, private.S)
+	}
+
+	s := MustRandScalar()
+	a, aCom := prove(0xab4f0540ab4f0540, s)
+	b, bCom := prove(0xab4f0540ab4f0540, s)
+
+	if bytes.Equal(a.Marshal(), b.Marshal()) {
+		panic("independent proofs should differ")
+	}
+
+	if !SameStatement(a, b, aCom, bCom) {
+		panic("independent proofs of the same commitment should be the same statement")
+	}
+
+	c, cCom := prove(0x0540ab4f0540ab4f, s)
+	if SameStatement(a, c, aCom, cCom) {
+		panic("proofs of the different values should not be the same statement")
+	}
+
+	short := &ReciprocalProof{ArithmeticCircuitProof: &ArithmeticCircuitProof{WNLA: &WeightNormLinearArgumentProof{
+		R: a.WNLA.R[1:],
+		X: a.WNLA.X[1:],
+		L: a.WNLA.L,
+		N: a.WNLA.N,
+	}}}
+
+	if SameStatement(a, short, aCom, aCom) {
+		panic("proofs for the different params should not be the same statement")
+	}
+}
+
+func TestFirstChallenge(t *testing.T) {
+	Nd, Np := 16, 16
+
+	public := newTestReciprocalPublic(t, Nd, Np)
+
+	proof, V, _, err := ProveRangeFresh(public, NewKeccakFS(), bint(1000))
+	if err != nil {
+		panic(err)
+	}
+
+	fs := &recordingFS{FiatShamirEngine: NewKeccakFS()}
+	if err = VerifyRange(public, V, fs, proof); err != nil {
+		panic(err)
+	}
+
+	var first string
+	for _, op := range fs.ops {
+		if strings.HasPrefix(op, "challenge ") {
+			first = op
+			break
+		}
+	}
+
+	if e := public.FirstChallenge(V, NewKeccakFS(), proof); e == nil || fmt.Sprintf("challenge %x", e) != first {
+		panic("first challenge does not match the verification transcript")
+	}
+
+	if public.FirstChallenge(V, NewKeccakFS(), &ReciprocalProof{ArithmeticCircuitProof: proof.ArithmeticCircuitProof}) != nil {
+		panic("proof without the poles commitment should be rejected")
+	}
+
+	if public.FirstChallenge(new(bn256.G1).Add(V, public.G), NewKeccakFS(), proof).Cmp(public.FirstChallenge(V, NewKeccakFS(), proof)) == 0 {
+		panic("challenge should depend on the commitment")
+	}
+
+	// The challenge of the Merlin proof is derived with the Merlin transcript
+	merlinProof, V, _, err := ProveRangeFresh(public, NewMerlinFS("range"), bint(1000))
+	if err != nil {
+		panic(err)
+	}
+
+	merlin := &recordingFS{FiatShamirEngine: NewMerlinFS("range")}
+	if err = VerifyRange(public, V, merlin, merlinProof); err != nil {
 		panic(err)
 	}
+
+	for _, op := range merlin.ops {
+		if strings.HasPrefix(op, "challenge ") {
+			first = op
+			break
+		}
+	}
+
+	if e := public.FirstChallenge(V, NewMerlinFS("range"), merlinProof); e == nil || fmt.Sprintf("challenge %x", e) != first {
+		panic("first challenge does not match the Merlin verification transcript")
+	}
+
+	if public.FirstChallenge(V, NewKeccakFS(), merlinProof) != nil {
+		panic("proof of the other engine should be rejected")
+	}
+}
+
+// newTestReciprocalPublic returns the public parameters with the random generators for the range proof of Nd digits
+// in base Np.
+func newTestReciprocalPublic(t testing.TB, Nd, Np int) *ReciprocalPublic {
+	t.Helper()
+	return newTestReciprocalPublicMulti(t, Nd, Np, 1)
+}
+
+// newTestReciprocalPublicMulti returns the public parameters with the random generators for the range proof of K
+// values of Nd digits in base Np, see ReciprocalPrivate.Xs.
+func newTestReciprocalPublicMulti(t testing.TB, Nd, Np, K int) *ReciprocalPublic {
+	t.Helper()
+	gens := NewWeightNormLinearPublic(powerOfTwo(Nd+1+StructuralSlots), powerOfTwo(K*Nd))
+	return testReciprocalPublicFrom(gens, Nd, Np, K)
+}
+
+// testReciprocalPublicFrom splits gens into the public parameters for the range proof of K values of Nd digits
+// in base Np: the first K*Nd GVec and Nd+1+StructuralSlots HVec generators are used in the circuit directly.
+func testReciprocalPublicFrom(gens *WeightNormLinearPublic, Nd, Np, K int) *ReciprocalPublic {
+	gLen, hLen := K*Nd, Nd+1+StructuralSlots
+
+	return &ReciprocalPublic{
+		G:     gens.G,
+		GVec:  gens.GVec[:gLen],
+		HVec:  gens.HVec[:hLen],
+		Nd:    Nd,
+		Np:    Np,
+		GVec_: gens.GVec[gLen:],
+		HVec_: gens.HVec[hLen:],
+	}
 }
@@ -0,0 +1,97 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import "math/big"
+
+// Scalar is the immutable element of the scalar field. All operations reduce the result modulo the field order and
+// return new values, so scalars never share the underlying *big.Int. The zero value is the zero scalar.
+type Scalar struct {
+	v *big.Int
+}
+
+// NewScalar returns the scalar equal to v modulo the field order. v is copied.
+func NewScalar(v *big.Int) Scalar {
+	return Scalar{v: new(big.Int).Mod(v, fieldOrder)}
+}
+
+// ScalarFromInt returns the scalar equal to v modulo the field order.
+func ScalarFromInt(v int) Scalar {
+	return Scalar{v: bint(v)}
+}
+
+// BigInt returns the copy of the scalar value in [0, order).
+func (s Scalar) BigInt() *big.Int {
+	if s.v == nil {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).Set(s.v)
+}
+
+func (s Scalar) Add(x Scalar) Scalar {
+	return Scalar{v: add(s.v, x.v)}
+}
+
+func (s Scalar) Sub(x Scalar) Scalar {
+	return Scalar{v: sub(s.v, x.v)}
+}
+
+func (s Scalar) Mul(x Scalar) Scalar {
+	return Scalar{v: mul(s.v, x.v)}
+}
+
+func (s Scalar) Neg() Scalar {
+	return Scalar{v: minus(zeroIfNil(s.v))}
+}
+
+// Inv returns the multiplicative inverse. Panics for zero, as it has no inverse.
+func (s Scalar) Inv() Scalar {
+	if s.IsZero() {
+		panic("bulletproofs: inverse of zero scalar")
+	}
+
+	return Scalar{v: inv(s.v)}
+}
+
+func (s Scalar) IsZero() bool {
+	return s.v == nil || s.v.Sign() == 0
+}
+
+func (s Scalar) Equal(x Scalar) bool {
+	return s.BigInt().Cmp(x.BigInt()) == 0
+}
+
+func (s Scalar) String() string {
+	return s.BigInt().String()
+}
+
+// ScalarsFromBigInts converts the vector of *big.Int into the vector of scalars.
+func ScalarsFromBigInts(v []*big.Int) []Scalar {
+	return mapSlice(v, NewScalar)
+}
+
+// ScalarsToBigInts converts the vector of scalars into the vector of *big.Int.
+func ScalarsToBigInts(v []Scalar) []*big.Int {
+	return mapSlice(v, Scalar.BigInt)
+}
+
+// ScalarMatrixVectorMul is the Scalar variant of MatrixVectorMul.
+func ScalarMatrixVectorMul(m [][]Scalar, v []Scalar) []Scalar {
+	return ScalarsFromBigInts(MatrixVectorMul(mapSlice(m, ScalarsToBigInts), ScalarsToBigInts(v)))
+}
+
+// ScalarVectorMatrixMul is the Scalar variant of VectorMatrixMul.
+func ScalarVectorMatrixMul(v []Scalar, m [][]Scalar) []Scalar {
+	return ScalarsFromBigInts(VectorMatrixMul(ScalarsToBigInts(v), mapSlice(m, ScalarsToBigInts)))
+}
+
+func mapSlice[T, U any](v []T, f func(T) U) []U {
+	res := make([]U, len(v))
+	for i := range v {
+		res[i] = f(v[i])
+	}
+	return res
+}
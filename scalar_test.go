@@ -0,0 +1,75 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestScalar(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		x, y := MustRandScalar(), MustRandScalar()
+		sx, sy := NewScalar(x), NewScalar(y)
+
+		if sx.Add(sy).BigInt().Cmp(add(x, y)) != 0 ||
+			sx.Sub(sy).BigInt().Cmp(sub(x, y)) != 0 ||
+			sx.Mul(sy).BigInt().Cmp(mul(x, y)) != 0 ||
+			sx.Neg().BigInt().Cmp(minus(x)) != 0 ||
+			sx.Inv().BigInt().Cmp(inv(x)) != 0 {
+			panic("test failed")
+		}
+	}
+
+	// Reduction of the input and no aliasing
+	x := new(big.Int).Add(bn256.Order, big.NewInt(5))
+	s := NewScalar(x)
+	x.SetInt64(7)
+
+	if !s.Equal(ScalarFromInt(5)) {
+		panic("test failed")
+	}
+
+	s.BigInt().SetInt64(9)
+	if !s.Equal(ScalarFromInt(5)) {
+		panic("test failed")
+	}
+
+	var zero Scalar
+	if !zero.IsZero() || !zero.Add(s).Equal(s) || !zero.Neg().IsZero() || !ScalarFromInt(-1).Add(ScalarFromInt(1)).IsZero() {
+		panic("test failed")
+	}
+}
+
+func TestScalarMatrixVectorMul(t *testing.T) {
+	w := []*big.Int{bint(3), bint(5), bint(15), bint(8)}
+	Wl := [][]*big.Int{
+		{bint(0), bint(1), bint(0), bint(0)},
+		{bint(0), bint(-1), bint(1), bint(0)},
+	}
+
+	m := mapSlice(Wl, ScalarsFromBigInts)
+
+	expected := MatrixVectorMul(Wl, w)
+	res := ScalarsToBigInts(ScalarMatrixVectorMul(m, ScalarsFromBigInts(w)))
+
+	for i := range expected {
+		if res[i].Cmp(expected[i]) != 0 {
+			panic("test failed")
+		}
+	}
+
+	v := []*big.Int{bint(1), bint(2)}
+
+	expected = VectorMatrixMul(v, Wl)
+	res = ScalarsToBigInts(ScalarVectorMatrixMul(ScalarsFromBigInts(v), m))
+
+	for i := range expected {
+		if res[i].Cmp(expected[i]) != 0 {
+			panic("test failed")
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Minimal STROBE-128 implementation that covers operations used by Merlin transcripts:
+// meta-AD, AD and PRF. Follows the reference implementation from the merlin crate.
+
+const strobeR = 166
+
+const (
+	strobeFlagI = 1 << iota
+	strobeFlagA
+	strobeFlagC
+	strobeFlagT
+	strobeFlagM
+	strobeFlagK
+)
+
+type strobe128 struct {
+	state    [200]byte
+	pos      byte
+	posBegin byte
+	curFlags byte
+}
+
+func newStrobe128(protocolLabel []byte) *strobe128 {
+	s := &strobe128{}
+	copy(s.state[0:6], []byte{1, strobeR + 2, 1, 0, 1, 96})
+	copy(s.state[6:18], "STROBEv1.0.2")
+	s.permute()
+
+	s.metaAD(protocolLabel, false)
+	return s
+}
+
+func (s *strobe128) metaAD(data []byte, more bool) {
+	s.beginOp(strobeFlagM|strobeFlagA, more)
+	s.absorb(data)
+}
+
+func (s *strobe128) ad(data []byte, more bool) {
+	s.beginOp(strobeFlagA, more)
+	s.absorb(data)
+}
+
+func (s *strobe128) prf(dst []byte, more bool) {
+	s.beginOp(strobeFlagI|strobeFlagA|strobeFlagC, more)
+	s.squeeze(dst)
+}
+
+func (s *strobe128) runF() {
+	s.state[s.pos] ^= s.posBegin
+	s.state[s.pos+1] ^= 0x04
+	s.state[strobeR+1] ^= 0x80
+	s.permute()
+	s.pos = 0
+	s.posBegin = 0
+}
+
+func (s *strobe128) absorb(data []byte) {
+	for _, b := range data {
+		s.state[s.pos] ^= b
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) squeeze(dst []byte) {
+	for i := range dst {
+		dst[i] = s.state[s.pos]
+		s.state[s.pos] = 0
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) beginOp(flags byte, more bool) {
+	if more {
+		if s.curFlags != flags {
+			panic("strobe: continued operation with different flags")
+		}
+		return
+	}
+
+	if flags&strobeFlagT != 0 {
+		panic("strobe: transport operations are not supported")
+	}
+
+	oldBegin := s.posBegin
+	s.posBegin = s.pos + 1
+	s.curFlags = flags
+	s.absorb([]byte{oldBegin, flags})
+
+	if flags&(strobeFlagC|strobeFlagK) != 0 && s.pos != 0 {
+		s.runF()
+	}
+}
+
+func (s *strobe128) permute() {
+	var a [25]uint64
+	for i := range a {
+		a[i] = binary.LittleEndian.Uint64(s.state[i*8:])
+	}
+
+	keccakF1600(&a)
+
+	for i := range a {
+		binary.LittleEndian.PutUint64(s.state[i*8:], a[i])
+	}
+}
+
+var keccakRC = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var keccakRotc = [24]int{1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44}
+
+var keccakPiln = [24]int{10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state.
+func keccakF1600(a *[25]uint64) {
+	var bc [5]uint64
+
+	for round := 0; round < 24; round++ {
+		// Theta
+		for i := 0; i < 5; i++ {
+			bc[i] = a[i] ^ a[i+5] ^ a[i+10] ^ a[i+15] ^ a[i+20]
+		}
+
+		for i := 0; i < 5; i++ {
+			t := bc[(i+4)%5] ^ bits.RotateLeft64(bc[(i+1)%5], 1)
+			for j := 0; j < 25; j += 5 {
+				a[j+i] ^= t
+			}
+		}
+
+		// Rho and Pi
+		t := a[1]
+		for i := 0; i < 24; i++ {
+			j := keccakPiln[i]
+			bc[0] = a[j]
+			a[j] = bits.RotateLeft64(t, keccakRotc[i])
+			t = bc[0]
+		}
+
+		// Chi
+		for j := 0; j < 25; j += 5 {
+			for i := 0; i < 5; i++ {
+				bc[i] = a[j+i]
+			}
+			for i := 0; i < 5; i++ {
+				a[j+i] ^= (^bc[(i+1)%5]) & bc[(i+2)%5]
+			}
+		}
+
+		// Iota
+		a[0] ^= keccakRC[round]
+	}
+}
@@ -0,0 +1,149 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// TestVector contains the reciprocal range proof public parameters, witness, commitment, proof and
+// the expected verification result. All points are encoded with bn256.G1 Marshal, scalars as 32 bytes big-endian
+// and proof with ReciprocalProof Marshal. Public parameters are derived from the seed (see DeterministicPoints):
+// GVec and HVec contain all generators, the first Nd and Nd+1+StructuralSlots of them are used in the circuit directly.
+type TestVector struct {
+	Seed       []byte   `json:"seed"`
+	Nd         int      `json:"nd"`
+	Np         int      `json:"np"`
+	G          []byte   `json:"g"`
+	GVec       [][]byte `json:"g_vec"`
+	HVec       [][]byte `json:"h_vec"`
+	X          []byte   `json:"x"`
+	S          []byte   `json:"s"`
+	Commitment []byte   `json:"commitment"`
+	Proof      []byte   `json:"proof"`
+	Accept     bool     `json:"accept"`
+}
+
+// GenerateTestVectors generates the set of uint64 reciprocal range proof test vectors. Generators, blinding values
+// and the proofs randomness (see DeterministicReader) are derived from the seed, so the vectors are byte-identical
+// for the same seed.
+// Vectors with Accept = false contain the valid proof with the commitment to another value.
+func GenerateTestVectors(seed []byte) ([]TestVector, error) {
+	const (
+		Nd = 16
+		Np = 16
+	)
+
+	gens := NewWeightNormLinearPublicFromSeed(seed, powerOfTwo(Nd+1+StructuralSlots), powerOfTwo(Nd))
+
+	public := &ReciprocalPublic{
+		G:     gens.G,
+		GVec:  gens.GVec[:Nd],
+		HVec:  gens.HVec[:Nd+1+StructuralSlots],
+		Nd:    Nd,
+		Np:    Np,
+		GVec_: gens.GVec[Nd:],
+		HVec_: gens.HVec[Nd+1+StructuralSlots:],
+	}
+
+	values := []uint64{0, 1, 0xab4f0540ab4f0540, 1<<64 - 1}
+
+	res := make([]TestVector, 0, 2*len(values))
+
+	for i, x := range values {
+		digits := UInt64Hex(x)
+
+		private := &ReciprocalPrivate{
+			X:      new(big.Int).SetUint64(x),
+			M:      HexMapping(digits),
+			Digits: digits,
+			S:      DeterministicScalar(seed, fmt.Sprintf("S%d", i)),
+			Rand:   DeterministicReader(seed, fmt.Sprintf("R%d", i)),
+		}
+
+		proof, err := ProveRange(public, NewKeccakFS(), private)
+		if err != nil {
+			return nil, err
+		}
+
+		vector := TestVector{
+			Seed:       seed,
+			Nd:         Nd,
+			Np:         Np,
+			G:          gens.G.Marshal(),
+			GVec:       marshalPoints(gens.GVec),
+			HVec:       marshalPoints(gens.HVec),
+			X:          scalarTo32Byte(private.X),
+			S:          scalarTo32Byte(private.S),
+			Commitment: public.CommitValue(private.X, private.S).Marshal(),
+			Proof:      proof.Marshal(),
+			Accept:     true,
+		}
+
+		// The same proof for the commitment to the other value
+		invalid := vector
+		invalid.X = scalarTo32Byte(add(private.X, bint(1)))
+		invalid.Commitment = public.CommitValue(add(private.X, bint(1)), private.S).Marshal()
+		invalid.Accept = false
+
+		res = append(res, vector, invalid)
+	}
+
+	return res, nil
+}
+
+// Public returns the reciprocal public parameters of the test vector.
+func (v *TestVector) Public() (*ReciprocalPublic, error) {
+	G := new(bn256.G1)
+	if _, err := G.Unmarshal(v.G); err != nil {
+		return nil, err
+	}
+
+	GVec, err := unmarshalPoints(v.GVec)
+	if err != nil {
+		return nil, err
+	}
+
+	HVec, err := unmarshalPoints(v.HVec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(GVec) < v.Nd || len(HVec) < v.Nd+1+StructuralSlots {
+		return nil, errors.New("invalid test vector: not enough generators")
+	}
+
+	return &ReciprocalPublic{
+		G:     G,
+		GVec:  GVec[:v.Nd],
+		HVec:  HVec[:v.Nd+1+StructuralSlots],
+		Nd:    v.Nd,
+		Np:    v.Np,
+		GVec_: GVec[v.Nd:],
+		HVec_: HVec[v.Nd+1+StructuralSlots:],
+	}, nil
+}
+
+func marshalPoints(points []*bn256.G1) [][]byte {
+	res := make([][]byte, len(points))
+	for i := range res {
+		res[i] = points[i].Marshal()
+	}
+	return res
+}
+
+func unmarshalPoints(data [][]byte) ([]*bn256.G1, error) {
+	res := make([]*bn256.G1, len(data))
+	for i := range res {
+		res[i] = new(bn256.G1)
+		if _, err := res[i].Unmarshal(data[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,192 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+	"testing"
+)
+
+func TestGenerateTestVectors(t *testing.T) {
+	vectors, err := GenerateTestVectors([]byte("bulletproofs test vectors"))
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := json.Marshal(vectors)
+	if err != nil {
+		panic(err)
+	}
+
+	var decoded []TestVector
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		panic(err)
+	}
+
+	if len(decoded) != len(vectors) {
+		panic("test failed")
+	}
+
+	for _, v := range decoded {
+		public, err := v.Public()
+		if err != nil {
+			panic(err)
+		}
+
+		V := new(bn256.G1)
+		if _, err := V.Unmarshal(v.Commitment); err != nil {
+			panic(err)
+		}
+
+		proof := &ReciprocalProof{}
+		if err := proof.Unmarshal(v.Proof); err != nil {
+			panic(err)
+		}
+
+		if err := VerifyRange(public, V, NewKeccakFS(), proof); (err == nil) != v.Accept {
+			panic("unexpected verification result")
+		}
+	}
+
+	// The vectors are byte-identical for the same seed, including the proofs
+	again, err := GenerateTestVectors([]byte("bulletproofs test vectors"))
+	if err != nil {
+		panic(err)
+	}
+
+	againData, err := json.Marshal(again)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(againData, data) {
+		panic("test vectors are not deterministic")
+	}
+
+	// Golden digest of the corpus: any change of the parameters derivation, the prover or the encoding breaks it
+	h := newKeccakState()
+	h.Write(data)
+	if hex.EncodeToString(h.Sum(nil)) != "60543561520ad3637d62e392bd01aca30cae95b3b320fae5fd4651c4e125ec11" {
+		panic("test vectors differ from the golden corpus")
+	}
+}
+
+// TestRangeProofAcrossProcesses runs the prover and verifier that share nothing but the seed and the message bytes:
+// seed -> params -> proof -> bytes -> params check -> verify.
+func TestRangeProofAcrossProcesses(t *testing.T) {
+	seed := []byte("bulletproofs integration test")
+
+	message, err := integrationProver(seed, 0xab4f0540ab4f0540)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := integrationVerifier(seed, message); err != nil {
+		panic(err)
+	}
+
+	// Any corruption of the message should be detected
+	for _, i := range []int{10, len(message) / 2, len(message) - 1} {
+		corrupted := append([]byte{}, message...)
+		corrupted[i] ^= 1
+
+		if err := integrationVerifier(seed, corrupted); err == nil {
+			panic("corrupted message should be rejected")
+		}
+	}
+
+	if err := integrationVerifier([]byte("another seed"), message); err == nil {
+		panic("message for the parameters from another seed should be rejected")
+	}
+}
+
+func integrationPublic(seed []byte) *ReciprocalPublic {
+	const Nd, Np = 16, 16
+
+	gens := NewWeightNormLinearPublicFromSeed(seed, powerOfTwo(Nd+1+StructuralSlots), powerOfTwo(Nd))
+	return testReciprocalPublicFrom(gens, Nd, Np, 1)
+}
+
+// integrationProver returns the message: G || GVec || HVec || commitment || proof.
+func integrationProver(seed []byte, x uint64) ([]byte, error) {
+	public := integrationPublic(seed)
+
+	digits := UInt64Hex(x)
+	private := &ReciprocalPrivate{
+		X:      new(big.Int).SetUint64(x),
+		M:      HexMapping(digits),
+		Digits: digits,
+		S:      MustRandScalar(),
+	}
+
+	proof, err := ProveRange(public, NewKeccakFS(), private)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &encoder{}
+	enc.point(public.G)
+	enc.points(concatPoints(public.GVec, public.GVec_))
+	enc.points(concatPoints(public.HVec, public.HVec_))
+	enc.point(public.CommitValue(private.X, private.S))
+	enc.buf = append(enc.buf, proof.Marshal()...)
+	return enc.buf, nil
+}
+
+func integrationVerifier(seed []byte, message []byte) error {
+	public := integrationPublic(seed)
+
+	r := bytes.NewReader(message)
+	dec := &decoder{r: r}
+
+	G, err := dec.point()
+	if err != nil {
+		return err
+	}
+
+	GVec, err := dec.points()
+	if err != nil {
+		return err
+	}
+
+	HVec, err := dec.points()
+	if err != nil {
+		return err
+	}
+
+	if !pointsEqual(G, public.G) ||
+		!bytes.Equal(encodePoints(GVec), encodePoints(concatPoints(public.GVec, public.GVec_))) ||
+		!bytes.Equal(encodePoints(HVec), encodePoints(concatPoints(public.HVec, public.HVec_))) {
+		return errors.New("prover parameters differ from the parameters derived from the seed")
+	}
+
+	vBytes, err := dec.read(pointSize)
+	if err != nil {
+		return err
+	}
+
+	proofBytes := make([]byte, r.Len())
+	if _, err := r.Read(proofBytes); err != nil {
+		return err
+	}
+
+	proof := &ReciprocalProof{}
+	if err := proof.Unmarshal(proofBytes); err != nil {
+		return err
+	}
+
+	return VerifyRangeMarshaled(public, vBytes, NewKeccakFS(), proof)
+}
+
+func encodePoints(points []*bn256.G1) []byte {
+	enc := &encoder{}
+	enc.points(points)
+	return enc.buf
+}
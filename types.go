@@ -5,7 +5,10 @@
 package bulletproofs
 
 import (
+	"errors"
+	"fmt"
 	"github.com/cloudflare/bn256"
+	"io"
 	"math/big"
 )
 
@@ -14,6 +17,7 @@ import (
 // Nm = Nd, No = Np
 // Nv = 1 + Nd
 // G and HVec[0] will be used for the value commitment: VCom = value*G + blinding*HVec[0]
+// For the proof of K values (see ReciprocalPrivate.Xs) Nm = K*Nd.
 type ReciprocalPublic struct {
 	G      *bn256.G1
 	GVec   []*bn256.G1 // Nm
@@ -23,6 +27,9 @@ type ReciprocalPublic struct {
 	// Vectors of points that will be used in WNLA protocol
 	GVec_ []*bn256.G1 // 2^n - Nm
 	HVec_ []*bn256.G1 // 2^n - (Nv+9)
+
+	// gTable is the precomputed multiplication table of G for the value commitments
+	gTable precomputedG
 }
 
 type ReciprocalPrivate struct {
@@ -30,11 +37,216 @@ type ReciprocalPrivate struct {
 	M      []*big.Int
 	Digits []*big.Int
 	S      *big.Int // Blinding value (secret)
+
+	// Several committed values of Nd digits each, used instead of X, M, Digits and S when not empty
+	Xs         []*big.Int
+	Ms         [][]*big.Int
+	DigitsList [][]*big.Int
+	Ss         []*big.Int
+
+	// NonHiding disables the random blinding of the poles commitments and of the circuit proof, see
+	// ArithmeticCircuitPrivate.NonHiding. The proofs are NOT zero-knowledge, set S to zero for the plain x*G commitment.
+	NonHiding bool
+
+	// Rand is the source of the blinding scalars, see ArithmeticCircuitPrivate.Rand.
+	Rand io.Reader
+
+	// DebugChecks enables the consistency checks of the prover's intermediate values, see
+	// AggregatedReciprocalPrivate.DebugChecks.
+	DebugChecks bool
 }
 
 type ReciprocalProof struct {
 	*ArithmeticCircuitProof
 	V *bn256.G1
+
+	// Poles commitments for each value, used instead of V for the proof of several values
+	Vs []*bn256.G1
+}
+
+// MultiBlindingRangeProof proves that the commitment VCom = x*G + sum(blindings[i]*hs[i]) holds the value in range.
+// The range proof is generated for the rebased commitment V = x*G + sum(blindings)*HVec[0], and T, Z, Z0 prove the
+// knowledge of the representation of VCom - V over hs and HVec[0], so both commitments hold the same value.
+type MultiBlindingRangeProof struct {
+	*ReciprocalProof
+	Rebased *bn256.G1
+
+	T  *bn256.G1
+	Z  []*big.Int // len(hs)
+	Z0 *big.Int
+}
+
+// VectorRangeProof proves that every element of the vector committed with CommitVector lies in range. The range
+// proof is generated for the rebased commitments Rebased[k] = x[k]*G + r[k]*HVec[0] of the elements, and T, Ts, Zx,
+// Zr, Zs prove that the vector commitment and the rebased commitments hold the same elements.
+type VectorRangeProof struct {
+	*ReciprocalProof
+	Rebased []*bn256.G1
+
+	T      *bn256.G1   // <kx, gs> + ks*HVec[0]
+	Ts     []*bn256.G1 // kx[k]*G + kr[k]*HVec[0]
+	Zx, Zr []*big.Int
+	Zs     *big.Int
+}
+
+// OpeningProof proves the knowledge of the opening x, s of the value commitment VCom = x*G + s*HVec[0]:
+// T = kx*G + ks*HVec[0], Zx = kx + c*x, Zs = ks + c*s for the challenge c.
+type OpeningProof struct {
+	T      *bn256.G1
+	Zx, Zs *big.Int
+}
+
+// MerklePath is the path from the Index-th of Size leaves to the Merkle root: the sibling hashes from the leaf level
+// up, the levels where the node is promoted without a sibling are skipped.
+type MerklePath struct {
+	Index, Size int
+	Siblings    [][]byte
+}
+
+// MerkleRangeProof is the range proof for the commitment VCom bound to the Merkle root of the value commitments.
+type MerkleRangeProof struct {
+	*ReciprocalProof
+	VCom *bn256.G1
+	Path *MerklePath
+}
+
+// RangeItem contains the opening of the value commitment VCom = X*G + S*HVec[0].
+type RangeItem struct {
+	X *big.Int // Committed value
+	S *big.Int // Blinding value (secret)
+}
+
+// BalancedRangeProof proves that all input and output values are in range and that the inputs sum equals to the
+// outputs sum. T and Z prove the knowledge of r such that sum(inputs) - sum(outputs) = r*HVec[0].
+type BalancedRangeProof struct {
+	*ReciprocalProof
+
+	T *bn256.G1
+	Z *big.Int
+}
+
+// RangeVerifyItem contains the range proof with its public parameters and value commitment for the batch verification.
+// Use empty FiatShamirEngine for FS.
+type RangeVerifyItem struct {
+	Public *ReciprocalPublic
+	V      *bn256.G1
+	FS     FiatShamirEngine
+	Proof  *ReciprocalProof
+}
+
+// BatchVerifyError describes the failed item of the batch verification.
+type BatchVerifyError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchVerifyError) Error() string {
+	return fmt.Sprintf("batch verification failed for item %d: %s", e.Index, e.Err)
+}
+
+func (e *BatchVerifyError) Unwrap() error {
+	return e.Err
+}
+
+// AggregatedReciprocalPublic dimensions:
+// Nd - count of private poles (digits) for each of K = len(Nd) committed values, Np - count of public poles
+// (number system base, shared between all values).
+// Nm = sum(Nd), No = Np
+// Nv = 1 + max(Nd), the commitment vectors of the values with fewer digits are padded with zeros.
+type AggregatedReciprocalPublic struct {
+	G    *bn256.G1
+	GVec []*bn256.G1 // Nm
+	HVec []*bn256.G1 // Nv+9
+	Nd   []int
+	Np   int
+
+	// Vectors of points that will be used in WNLA protocol
+	GVec_ []*bn256.G1 // 2^n - Nm
+	HVec_ []*bn256.G1 // 2^n - (Nv+9)
+
+	// gTable is the precomputed multiplication table of G for the value commitments
+	gTable precomputedG
+}
+
+type AggregatedReciprocalPrivate struct {
+	X      []*big.Int   // Committed values
+	M      []*big.Int   // Multiplicities of all digits
+	Digits [][]*big.Int // Digits of each value, len(Digits[i]) = Nd[i]
+	S      []*big.Int   // Blinding values (secret)
+
+	// NonHiding disables the random blinding of the proof, see ArithmeticCircuitPrivate.NonHiding.
+	NonHiding bool
+
+	// Rand is the source of the blinding scalars, see ArithmeticCircuitPrivate.Rand.
+	Rand io.Reader
+
+	// DebugChecks enables the consistency checks of the prover's intermediate values: the poles should satisfy
+	// r[j] * (digits[j] + e) = 1. The prover returns an error on mismatch instead of producing an invalid proof.
+	DebugChecks bool
+}
+
+type AggregatedReciprocalProof struct {
+	*ArithmeticCircuitProof
+	V []*bn256.G1
+}
+
+// ValueCommitmentPublic contains the generators of the circuits over the committed values.
+// G and HVec[0] will be used for the value commitment: VCom = value*G + blinding*HVec[0]
+type ValueCommitmentPublic struct {
+	G    *bn256.G1
+	GVec []*bn256.G1 // Nm
+	HVec []*bn256.G1 // Nv+9
+
+	// Vectors of points that will be used in WNLA protocol
+	GVec_ []*bn256.G1 // 2^n - Nm
+	HVec_ []*bn256.G1 // 2^n - (Nv+9)
+
+	// gTable is the precomputed multiplication table of G for the value commitments
+	gTable precomputedG
+}
+
+// NonZeroPublic dimensions: Nm = 1, No = 1, Nv = 1.
+type NonZeroPublic struct {
+	ValueCommitmentPublic
+}
+
+// LinearRelationPublic dimensions: Nm = 1, No = 1, Nv = 1, K = 2.
+type LinearRelationPublic struct {
+	ValueCommitmentPublic
+}
+
+// BitsPublic dimensions:
+// Nb - count of bits of the committed value.
+// Nm = Nb, No = 1, K = 2 (the value commitment and the ZeroCommitment)
+// Nv = 1 + Nb
+type BitsPublic struct {
+	ValueCommitmentPublic
+	Nb int
+}
+
+// MaxPublic dimensions:
+// Nb - count of bits of the differences max-a and max-b.
+// Nm = 2*Nb+1, No = 1, K = 5 (the three values commitments and two ZeroCommitment)
+// Nv = 1 + Nb
+type MaxPublic struct {
+	ValueCommitmentPublic
+	Nb int
+}
+
+// PermutationPublic dimensions:
+// N = len(Y) - size of the committed vector (N > 1).
+// Nm = N - 1, No = 1
+// Nv = N
+// Committed vector x uses G, HVec[0] and HVec[9:]: VCom = x[0]*G + blinding*HVec[0] + <x[1:], HVec[9:]>
+type PermutationPublic struct {
+	G    *bn256.G1
+	GVec []*bn256.G1 // Nm
+	HVec []*bn256.G1 // Nv+9
+	Y    []*big.Int  // Public vector
+
+	// Vectors of points that will be used in WNLA protocol
+	GVec_ []*bn256.G1 // 2^n - Nm
+	HVec_ []*bn256.G1 // 2^n - (Nv+9)
 }
 
 type PartitionType int
@@ -48,6 +260,15 @@ const (
 
 type PartitionF = func(typ PartitionType, index int) *int
 
+// StructuralSlots is the count of the HVec generators reserved by the arithmetic circuit protocol for the blinding
+// terms of CL, CR, CO, CS and the value commitments. HVec[0] is also used as the blinding base of the value commitments,
+// and the committed vectors v[1:] use HVec[StructuralSlots:], so HVec should contain at least StructuralSlots+Nv points.
+const StructuralSlots = 9
+
+// ArithmeticCircuitPublic describes the BP++ arithmetic circuit. Nm = 0 is a supported configuration for the purely
+// linear circuits: GVec, Wm and Am are empty then, and wl, wr of the witness are empty too. No = 0 is supported for the
+// circuits without outputs: the wo columns of Wm, Wl and the witness wo are empty, and F maps nothing.
+// The minimal supported circuit has K = 1 and Nv = 1 (one committed value) with Nm = No = 0.
 type ArithmeticCircuitPublic struct {
 	Nm, Nl, Nv, Nw, No int // Nw = Nm + Nm + No (for L, R, O parts), Nl = Nv * K
 	K                  int // Count of witness vectors v.
@@ -69,6 +290,24 @@ type ArithmeticCircuitPublic struct {
 	// Vectors of points that will be used in WNLA protocol
 	GVec_ []*bn256.G1 // 2^n - Nm
 	HVec_ []*bn256.G1 // 2^n - (Nv+9)
+
+	// Profiler receives the durations of the proving phases, nil disables profiling
+	Profiler Profiler
+
+	// gTable is the precomputed multiplication table of G for the commitments
+	gTable precomputedG
+}
+
+// CircuitSummary contains the arithmetic circuit dimensions and generators requirements.
+type CircuitSummary struct {
+	Nm, Nl, Nv, Nw, No, K int
+
+	GVecLen int // required GVec length: Nm
+	HVecLen int // required HVec length: 9+Nv
+
+	// Padded to the power of two lengths of GVec||GVec_ and HVec||HVec_ used in WNLA protocol
+	PaddedGVecLen int
+	PaddedHVecLen int
 }
 
 type ArithmeticCircuitPrivate struct {
@@ -77,11 +316,46 @@ type ArithmeticCircuitPrivate struct {
 	Wl []*big.Int   // Nm
 	Wr []*big.Int   // Nm
 	Wo []*big.Int   // No
+
+	// NonHiding disables the random blinding of the proof commitments CL, CR, CO and CS, hiding is enabled by default.
+	//
+	// WARNING: the non-hiding proofs are NOT zero-knowledge. The commitments are plain combinations of the witness,
+	// so the proofs reveal the witness to anyone able to brute-force it. Use it only for testing and public audit,
+	// where the reproducible proofs are required: the proofs of the same witness and Sv are byte-identical.
+	NonHiding bool
+
+	// Rand is the source of the blinding scalars, crypto/rand.Reader if nil. Set it to DeterministicReader for the
+	// reproducible hiding proofs.
+	Rand io.Reader
 }
 
 type ArithmeticCircuitProof struct {
 	CL, CR, CO, CS *bn256.G1
 	WNLA           *WeightNormLinearArgumentProof
+
+	// ID of the FiatShamirEngine that generated the proof, empty if unknown. It is encoded with the proof.
+	Engine string
+}
+
+// Metadata is the per-proof data bound into the transcript, e.g. for the replay protection. NotAfter is the
+// expiry Unix timestamp in seconds.
+type Metadata struct {
+	Nonce    []byte
+	NotAfter int64
+
+	// Now returns the current Unix timestamp in seconds the verifier checks NotAfter against, time.Now if nil.
+	// It is not bound into the transcript.
+	Now func() int64
+}
+
+// CircuitChallenges contains the Fiat-Shamir challenges of the arithmetic circuit protocol in the order of derivation.
+// Ro also defines the WNLA weights: Mu = Ro^2 batches the multiplication constraints, so it can not be a fixed
+// public parameter and has to be derived after CL, CR and CO are committed. Otherwise, the prover can choose
+// the witness that violates several constraints with errors cancelling each other. Circuits built for the range
+// proofs use it the same way.
+type CircuitChallenges struct {
+	Ro, Lambda, Beta, Delta *big.Int
+	T                       *big.Int // derived after CS is absorbed
 }
 
 // WeightNormLinearArgumentProof contains the proof of knowledge of vectors L, N for corresponding commitment C (is not
@@ -89,8 +363,27 @@ type ArithmeticCircuitProof struct {
 type WeightNormLinearArgumentProof struct {
 	R, X []*bn256.G1
 	L, N []*big.Int
+
+	// Flattened layout only: the round challenges and the commitments folded by every round
+	Y      []*big.Int
+	Folded []*bn256.G1
 }
 
+// ProofLayout defines the layout of the WNLA proof generated by ProveWNLA.
+//
+// Recursive proof contains only the round X, R points and the final L, N vectors, so the verifier folds the commitment
+// round by round (VerifyWNLA also folds the generators). Flattened proof additionally contains the round challenges Y
+// and the folded commitments, so VerifyWNLAFlat checks the challenges with the transcript hashes only and all the
+// rounds with the single multi-scalar multiplication without recursion. It is larger by one scalar and one point
+// (96 bytes) per round, but the verification has no sequential scalar multiplications, that is preferable for
+// the on-chain verifiers.
+type ProofLayout int
+
+const (
+	Recursive ProofLayout = iota
+	Flattened
+)
+
 // WeightNormLinearPublic contains the public values to be used in weight norm linear argument proof.
 // The GVec and HVec sizes are recommended to be a powers of 2 and equal to the `n` and `l` private vector sizes.
 type WeightNormLinearPublic struct {
@@ -98,6 +391,46 @@ type WeightNormLinearPublic struct {
 	GVec, HVec []*bn256.G1
 	C          []*big.Int
 	Ro, Mu     *big.Int // mu = ro^2
+
+	Layout ProofLayout // Layout of the generated proofs, Recursive by default
+
+	// gTable is the precomputed multiplication table of G for the commitments
+	gTable precomputedG
+}
+
+// CompiledCircuit contains the challenge independent precomputation of the circuit verification: dimensions, constants,
+// generators and the M matrices. It is serialized with Marshal without the Wm, Wl matrices and the partition function,
+// that are used only to derive the M matrices. Create it with CompileCircuit.
+type CompiledCircuit struct {
+	scratch *VerifyScratch
+}
+
+// VerifyScratch contains the precomputed values of the circuit verification. Create it with NewVerifyScratch.
+type VerifyScratch struct {
+	public     *ArithmeticCircuitPublic
+	matrices   *circuitMatrices
+	gVec, hVec []*bn256.G1
+}
+
+// ProofComposer composes the range proof and the arithmetic circuit proof over the same committed value on one
+// transcript. Create it with NewProofComposer.
+type ProofComposer struct {
+	rangePublic   *ReciprocalPublic
+	circuitPublic *ArithmeticCircuitPublic
+	index         int
+}
+
+// ComposedProof contains the range proof and the circuit proof generated by ProofComposer on the shared transcript.
+type ComposedProof struct {
+	Range   *ReciprocalProof
+	Circuit *ArithmeticCircuitProof
+}
+
+// WNLAVerifyState contains the folded public values and commitment between the rounds of WNLA verification.
+type WNLAVerifyState struct {
+	Public *WeightNormLinearPublic
+	Com    *bn256.G1
+	FS     FiatShamirEngine
 }
 
 func NewWeightNormLinearPublic(lLen int, nLen int) *WeightNormLinearPublic {
@@ -127,3 +460,142 @@ func NewWeightNormLinearPublic(lLen int, nLen int) *WeightNormLinearPublic {
 		Mu:   mul(ro, ro),
 	}
 }
+
+// NewWeightNormLinearPublicFromSeed creates the public values deterministically derived from the seed
+// (see DeterministicPoints and DeterministicScalar).
+func NewWeightNormLinearPublicFromSeed(seed []byte, lLen int, nLen int) *WeightNormLinearPublic {
+	ro := DeterministicScalar(seed, "Ro")
+
+	c := make([]*big.Int, lLen)
+	for i := range c {
+		c[i] = DeterministicScalar(seed, fmt.Sprintf("C%d", i))
+	}
+
+	return &WeightNormLinearPublic{
+		G:    DeterministicPoints(seed, "G", 1)[0],
+		GVec: DeterministicPoints(seed, "GVec", nLen),
+		HVec: DeterministicPoints(seed, "HVec", lLen),
+		C:    c,
+		Ro:   ro,
+		Mu:   mul(ro, ro),
+	}
+}
+
+// GrowGenerators extends GVec up to nLen and HVec with C up to lLen points. New points are derived by hash to curve
+// with the G encoding as a seed: DeterministicPoints(G.Marshal(), "GVec", nLen)[len(GVec):] and the same for HVec,
+// so the growth is deterministic and the existing generators are not changed. New C values are derived with
+// DeterministicScalar. The extended vectors are replaced with the new slices, so the slices that share the backing
+// arrays with them (e.g. the other parameters built from the same generators) are not modified. Vectors that are
+// already long enough are not modified.
+func GrowGenerators(wnla *WeightNormLinearPublic, nLen, lLen int) {
+	seed := wnla.G.Marshal()
+
+	if n := len(wnla.GVec); n < nLen {
+		gVec := make([]*bn256.G1, nLen)
+		copy(gVec, wnla.GVec)
+
+		for i := n; i < nLen; i++ {
+			gVec[i] = deterministicPoint(seed, "GVec", i)
+		}
+
+		wnla.GVec = gVec
+	}
+
+	if n := len(wnla.HVec); n < lLen {
+		hVec := make([]*bn256.G1, lLen)
+		copy(hVec, wnla.HVec)
+
+		for i := n; i < lLen; i++ {
+			hVec[i] = deterministicPoint(seed, "HVec", i)
+		}
+
+		wnla.HVec = hVec
+	}
+
+	if n := len(wnla.C); n < lLen {
+		c := make([]*big.Int, lLen)
+		copy(c, wnla.C)
+
+		for i := n; i < lLen; i++ {
+			c[i] = DeterministicScalar(seed, fmt.Sprintf("C%d", i))
+		}
+
+		wnla.C = c
+	}
+}
+
+// MergeGenerators returns the generators set containing the GVec and HVec points of a followed by the points of b that
+// are not in a, so the sets built from the same seed or grown with GrowGenerators are aligned by the common prefix.
+// The C values follow the corresponding HVec points. Returns error if G, Ro or Mu differ, if the merged GVec and HVec
+// share a point, as the generators should be independent, or if the C values do not define the same WNLA statement:
+// only one set has C, C does not cover all HVec points, or the common HVec point has different C values.
+// The inputs are not modified.
+func MergeGenerators(a, b *WeightNormLinearPublic) (*WeightNormLinearPublic, error) {
+	if !pointsEqual(a.G, b.G) {
+		return nil, errors.New("invalid generators: G should be the same")
+	}
+
+	if a.Ro.Cmp(b.Ro) != 0 || a.Mu.Cmp(b.Mu) != 0 {
+		return nil, errors.New("invalid generators: Ro and Mu should be the same")
+	}
+
+	gVec, gIndex := mergePoints(a.GVec, b.GVec)
+	hVec, hIndex := mergePoints(a.HVec, b.HVec)
+
+	for key := range hIndex {
+		if _, ok := gIndex[key]; ok {
+			return nil, errors.New("invalid generators: GVec and HVec should not share points")
+		}
+	}
+
+	res := &WeightNormLinearPublic{
+		G:    a.G,
+		GVec: gVec,
+		HVec: hVec,
+		Ro:   a.Ro,
+		Mu:   a.Mu,
+	}
+
+	if len(a.C) == 0 && len(b.C) == 0 {
+		return res, nil
+	}
+
+	if len(a.C) != len(a.HVec) || len(b.C) != len(b.HVec) {
+		return nil, errors.New("invalid generators: both sets should have C for all HVec points or none")
+	}
+
+	res.C = make([]*big.Int, len(hVec))
+	for i, p := range a.HVec {
+		res.C[hIndex[string(p.Marshal())]] = a.C[i]
+	}
+
+	for i, p := range b.HVec {
+		j := hIndex[string(p.Marshal())]
+		if res.C[j] != nil && res.C[j].Cmp(b.C[i]) != 0 {
+			return nil, fmt.Errorf("invalid generators: HVec point %d of b has the other C value", i)
+		}
+
+		res.C[j] = b.C[i]
+	}
+
+	return res, nil
+}
+
+// mergePoints returns the distinct points of a followed by the points of b that are not in a, and the index of every
+// point encoding in the result.
+func mergePoints(a, b []*bn256.G1) ([]*bn256.G1, map[string]int) {
+	res := make([]*bn256.G1, 0, len(a)+len(b))
+	index := make(map[string]int)
+
+	for _, p := range append(append([]*bn256.G1{}, a...), b...) {
+		key := string(p.Marshal())
+		if _, ok := index[key]; ok {
+			continue
+		}
+
+		index[key] = len(res)
+		res = append(res, p)
+	}
+
+	return res, index
+}
@@ -0,0 +1,144 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/cloudflare/bn256"
+	"math/big"
+)
+
+// VectorGenerators returns the n generators of the vector commitments: the hash to curve of the G encoding,
+// DeterministicPoints(G.Marshal(), "vector", n). Their discrete logs relative to G and HVec[0] are unknown.
+func VectorGenerators(public *ReciprocalPublic, n int) []*bn256.G1 {
+	return DeterministicPoints(public.G.Marshal(), "vector", n)
+}
+
+// CommitVector creates a commitment for the vector of values and blinding s:
+// VCom = sum(values[i]*gs[i]) + s*HVec[0], where gs = VectorGenerators(public, len(values)).
+func CommitVector(public *ReciprocalPublic, values []*big.Int, s *big.Int) *bn256.G1 {
+	res := vectorPointScalarMul(VectorGenerators(public, len(values)), values)
+	res.Add(res, new(bn256.G1).ScalarMult(public.HVec[0], s))
+	return res
+}
+
+// ProveVectorRange commits the vector of values with CommitVector and generates the proof that every value lies in
+// [0, Np^Nd). The values are proven in range with the K = len(values) dimension circuit, so public should contain
+// generators for len(values)*Nd digits. Returns the proof and the vector commitment. Use empty FiatShamirEngine for call.
+func ProveVectorRange(public *ReciprocalPublic, fs FiatShamirEngine, values []*big.Int, s *big.Int) (*VectorRangeProof, *bn256.G1, error) {
+	if len(values) == 0 {
+		return nil, nil, errors.New("invalid values: should be non-empty")
+	}
+
+	n := len(values)
+
+	private := &ReciprocalPrivate{}
+	for _, x := range values {
+		digits, err := valueDigits(x, public.Np, public.Nd)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		private.Xs = append(private.Xs, x)
+		private.Ms = append(private.Ms, DigitsMapping(digits, public.Np))
+		private.DigitsList = append(private.DigitsList, digits)
+		private.Ss = append(private.Ss, MustRandScalar())
+	}
+
+	proof, err := ProveRange(public, fs, private)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gs := VectorGenerators(public, n)
+
+	VCom := vectorPointScalarMul(gs, values)
+	VCom.Add(VCom, new(bn256.G1).ScalarMult(public.HVec[0], s))
+
+	rebased := make([]*bn256.G1, n)
+	for k := range rebased {
+		rebased[k] = public.CommitValue(values[k], private.Ss[k])
+	}
+
+	kx, kr, ks := make([]*big.Int, n), make([]*big.Int, n), MustRandScalar()
+	Ts := make([]*bn256.G1, n)
+	for k := range Ts {
+		kx[k], kr[k] = MustRandScalar(), MustRandScalar()
+		Ts[k] = public.CommitValue(kx[k], kr[k])
+	}
+
+	T := vectorPointScalarMul(gs, kx)
+	T.Add(T, new(bn256.G1).ScalarMult(public.HVec[0], ks))
+
+	c := vectorRangeChallenge(fs, VCom, rebased, T, Ts)
+
+	return &VectorRangeProof{
+		ReciprocalProof: proof,
+		Rebased:         rebased,
+		T:               T,
+		Ts:              Ts,
+		Zx:              vectorAdd(kx, vectorMulOnScalar(values, c)),
+		Zr:              vectorAdd(kr, vectorMulOnScalar(private.Ss, c)),
+		Zs:              add(ks, mul(c, s)),
+	}, VCom, nil
+}
+
+// VerifyVectorRange verifies the proof generated by ProveVectorRange for the vector commitment VCom.
+// If err is nil then proof is valid. Use empty FiatShamirEngine for call.
+func VerifyVectorRange(public *ReciprocalPublic, VCom *bn256.G1, fs FiatShamirEngine, proof *VectorRangeProof) error {
+	if proof.ReciprocalProof == nil || proof.T == nil || proof.Zs == nil {
+		return errors.New("invalid proof: missing elements")
+	}
+
+	n := len(proof.Rebased)
+	if n == 0 || len(proof.Ts) != n || len(proof.Zx) != n || len(proof.Zr) != n {
+		return errors.New("invalid proof: rebased commitments, Ts and responses should have the same non-zero length")
+	}
+
+	if err := VerifyRangeMulti(public, proof.Rebased, fs, proof.ReciprocalProof); err != nil {
+		return err
+	}
+
+	c := vectorRangeChallenge(fs, VCom, proof.Rebased, proof.T, proof.Ts)
+
+	// Zx[k]*G + Zr[k]*HVec[0] = Ts[k] + c*Rebased[k]
+	for k := 0; k < n; k++ {
+		right := new(bn256.G1).ScalarMult(proof.Rebased[k], c)
+		right.Add(right, proof.Ts[k])
+
+		if !pointsEqual(public.CommitValue(proof.Zx[k], proof.Zr[k]), right) {
+			return errors.New("representation proof failed: rebased commitment does not match the vector element")
+		}
+	}
+
+	// <Zx, gs> + Zs*HVec[0] = T + c*VCom
+	left := vectorPointScalarMul(VectorGenerators(public, n), proof.Zx)
+	left.Add(left, new(bn256.G1).ScalarMult(public.HVec[0], proof.Zs))
+
+	right := new(bn256.G1).ScalarMult(VCom, c)
+	right.Add(right, proof.T)
+
+	if !pointsEqual(left, right) {
+		return errors.New("representation proof failed: vector commitment does not match the rebased ones")
+	}
+
+	return nil
+}
+
+func vectorRangeChallenge(fs FiatShamirEngine, VCom *bn256.G1, rebased []*bn256.G1, T *bn256.G1, Ts []*bn256.G1) *big.Int {
+	fs.AddNumber(bint(len(rebased)))
+	fs.AddPoint(VCom)
+
+	for _, V := range rebased {
+		fs.AddPoint(V)
+	}
+
+	fs.AddPoint(T)
+	for _, t := range Ts {
+		fs.AddPoint(t)
+	}
+
+	return fs.GetChallenge()
+}
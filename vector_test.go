@@ -0,0 +1,49 @@
+// Package bulletproofs
+// Copyright 2024 Distributed Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package bulletproofs
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestVectorRange(t *testing.T) {
+	Nd, Np, K := 16, 16, 4
+
+	public := newTestReciprocalPublicMulti(t, Nd, Np, K)
+
+	values := []*big.Int{bint(0), bint(1000), new(big.Int).SetUint64(0xab4f0540ab4f0540), new(big.Int).SetUint64(0xffffffffffffffff)}
+	s := MustRandScalar()
+
+	proof, VCom, err := ProveVectorRange(public, NewKeccakFS(), values, s)
+	if err != nil {
+		panic(err)
+	}
+
+	if !pointsEqual(VCom, CommitVector(public, values, s)) {
+		panic("test failed")
+	}
+
+	if err = VerifyVectorRange(public, VCom, NewKeccakFS(), proof); err != nil {
+		panic(err)
+	}
+
+	// 2^64 is out of the [0, 16^16) range
+	outOfRange := []*big.Int{bint(0), bint(1000), new(big.Int).Lsh(bint(1), 64), bint(1)}
+
+	if _, _, err = ProveVectorRange(public, NewKeccakFS(), outOfRange, s); err == nil {
+		panic("out of range element should be rejected")
+	}
+
+	if err = VerifyVectorRange(public, CommitVector(public, outOfRange, s), NewKeccakFS(), proof); err == nil {
+		panic("proof should be rejected for the vector with the out of range element")
+	}
+
+	// The vector commitment is bound to the elements order
+	swapped := []*big.Int{values[1], values[0], values[2], values[3]}
+	if err = VerifyVectorRange(public, CommitVector(public, swapped, s), NewKeccakFS(), proof); err == nil {
+		panic("proof should be rejected for the other vector")
+	}
+}
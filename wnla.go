@@ -5,7 +5,6 @@
 package bulletproofs
 
 import (
-	"bytes"
 	"errors"
 	"github.com/cloudflare/bn256"
 	"math/big"
@@ -16,12 +15,22 @@ import (
 // where v = <c, l> + |n^2|_mu
 func (p *WeightNormLinearPublic) CommitWNLA(l []*big.Int, n []*big.Int) *bn256.G1 {
 	v_ := add(vectorMul(p.C, l), weightVectorMul(n, n, p.Mu))
-	C := new(bn256.G1).ScalarMult(p.G, v_)
+	C := p.precomputeG().mul(v_)
 	C.Add(C, vectorPointScalarMul(p.HVec, l))
 	C.Add(C, vectorPointScalarMul(p.GVec, n))
 	return C
 }
 
+// CheckWNLARelation checks the scalar relation v = <c, l> + |n^2|_mu of the WNLA commitment for the given vectors.
+// Use it to debug a failing proof: unlike VerifyWNLA it does not depend on the generators folding.
+func CheckWNLARelation(public *WeightNormLinearPublic, l []*big.Int, n []*big.Int, expectedV *big.Int) error {
+	if v := add(vectorMul(public.C, l), weightVectorMul(n, n, public.Mu)); v.Cmp(new(big.Int).Mod(expectedV, fieldOrder)) != 0 {
+		return errors.New("failed to check relation: v != <c, l> + |n^2|_mu")
+	}
+
+	return nil
+}
+
 // VerifyWNLA verifies the weight norm linear argument proof. If err is nil then proof is valid.
 // Use empty FiatShamirEngine for call. Also, use the same commitment that has been used during proving.
 func VerifyWNLA(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentProof, Com *bn256.G1, fs FiatShamirEngine) error {
@@ -29,22 +38,128 @@ func VerifyWNLA(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentP
 		return errors.New("invalid length for R and X vectors: should be equal")
 	}
 
-	if len(proof.X) == 0 {
-		if !bytes.Equal(public.CommitWNLA(proof.L, proof.N).Marshal(), Com.Marshal()) {
-			return errors.New("failed to verify proof")
+	if proof.IsFlattened() {
+		return errFlattenedProof
+	}
+
+	state := NewWNLAVerifyState(public, Com, fs)
+
+	if proof.IsBaseCase() {
+		// Vectors were small enough to be sent without reduction
+		return WNLAFinalize(state, proof.L, proof.N)
+	}
+
+	for i := range proof.X {
+		var err error
+		if state, err = WNLAStep(state, proof.X[i], proof.R[i]); err != nil {
+			return err
 		}
+	}
 
-		return nil
+	return WNLAFinalize(state, proof.L, proof.N)
+}
+
+// IsBaseCase returns true if the proof has no reduction rounds, so it contains only the final l, n vectors.
+// Such proofs are generated for the vectors with len(l)+len(n) < 6.
+func (p *WeightNormLinearArgumentProof) IsBaseCase() bool {
+	return len(p.X) == 0
+}
+
+// IsFlattened returns true if the proof has been generated with the Flattened layout and contains the round challenges
+// and the folded commitments. Such proofs are verified only with VerifyWNLAFlat.
+func (p *WeightNormLinearArgumentProof) IsFlattened() bool {
+	return len(p.Y) != 0 || len(p.Folded) != 0
+}
+
+// errFlattenedProof is returned by the recursive verifiers for the proof of the Flattened layout.
+var errFlattenedProof = errors.New("invalid proof: flattened proof should be verified with VerifyWNLAFlat")
+
+// VerifyWNLAFlat verifies the weight norm linear argument proof generated with the Flattened layout without recursion.
+// The stored round challenges are compared to the transcript ones, that are derived from the stored folded commitments
+// with hashing only. Then the folding of every round and the final relation of VerifyWNLAFast are checked with the
+// single multi-scalar multiplication of their random linear combination:
+// sum(w_i*(Com_i + y_i*X_i + (y_i^2-1)*R_i - Com_i+1)) + Com_k - v*G - <l*hCoef, HVec> - <n*gCoef, GVec> = 0
+// The proofs of the Recursive layout with at least one round are rejected. If err is nil then proof is valid.
+// Use empty FiatShamirEngine for call. Also, use the same commitment that has been used during proving.
+func VerifyWNLAFlat(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentProof, Com *bn256.G1, fs FiatShamirEngine) error {
+	rounds := len(proof.X)
+	if len(proof.R) != rounds || len(proof.Y) != rounds || len(proof.Folded) != rounds {
+		return errors.New("invalid flattened proof: R, X, Y and folded commitments should have the same length")
 	}
 
-	fs.AddPoint(Com)
-	fs.AddPoint(proof.X[0])
-	fs.AddPoint(proof.R[0])
-	fs.AddNumber(bint(len(public.HVec)))
-	fs.AddNumber(bint(len(public.GVec)))
+	lLen, nLen := len(public.HVec), len(public.GVec)
+	prev := Com
 
-	// Challenge using Fiat-Shamir heuristic
-	y := fs.GetChallenge()
+	for i := 0; i < rounds; i++ {
+		if proof.X[i] == nil || proof.R[i] == nil || proof.Folded[i] == nil || proof.Y[i] == nil {
+			return errors.New("invalid round: X, R, Y and folded commitment should be defined")
+		}
+
+		if wnlaRoundChallenge(fs, prev, proof.X[i], proof.R[i], lLen, nLen).Cmp(proof.Y[i]) != 0 {
+			return errors.New("failed to verify proof: round challenge mismatch")
+		}
+
+		prev = proof.Folded[i]
+		lLen, nLen = (lLen+1)/2, (nLen+1)/2
+	}
+
+	gScalar, hScalars, gScalars := wnlaFinalScalars(public, proof, proof.Y)
+
+	points := make([]*bn256.G1, 0, 1+4*rounds+1+len(hScalars)+len(gScalars))
+	scalars := make([]*big.Int, 0, cap(points))
+
+	prev = Com
+	for i := 0; i < rounds; i++ {
+		w := MustRandScalar()
+		y := proof.Y[i]
+
+		points = append(points, prev, proof.X[i], proof.R[i], proof.Folded[i])
+		scalars = append(scalars, w, mul(w, y), mul(w, sub(mul(y, y), bint(1))), minus(w))
+		prev = proof.Folded[i]
+	}
+
+	points = append(points, prev, public.G)
+	scalars = append(scalars, bint(1), minus(gScalar))
+
+	points = append(points, public.HVec...)
+	points = append(points, public.GVec...)
+
+	for i := range hScalars {
+		scalars = append(scalars, minus(hScalars[i]))
+	}
+
+	for i := range gScalars {
+		scalars = append(scalars, minus(gScalars[i]))
+	}
+
+	if !pointsEqual(multiScalarMul(points, scalars), new(bn256.G1).ScalarBaseMult(bint(0))) {
+		return errors.New("failed to verify proof")
+	}
+
+	return nil
+}
+
+// NewWNLAVerifyState creates the initial state for the round-by-round WNLA verification.
+// Use empty FiatShamirEngine for call. Also, use the same commitment that has been used during proving.
+func NewWNLAVerifyState(public *WeightNormLinearPublic, Com *bn256.G1, fs FiatShamirEngine) *WNLAVerifyState {
+	return &WNLAVerifyState{
+		Public: public,
+		Com:    Com,
+		FS:     fs,
+	}
+}
+
+// WNLAStep verifies one round of the WNLA protocol: absorbs the round X, R points, generates the round challenge
+// and folds the public values and commitment. Returns the state for the next round.
+func WNLAStep(state *WNLAVerifyState, roundX, roundR *bn256.G1) (*WNLAVerifyState, error) {
+	if roundX == nil || roundR == nil {
+		return nil, errors.New("invalid round: X and R should be defined")
+	}
+
+	public := state.Public
+	fs := state.FS
+
+	y := wnlaRoundChallenge(fs, state.Com, roundX, roundR, len(public.HVec), len(public.GVec))
 
 	c0, c1 := reduceVector(public.C)
 	G0, G1 := reducePoints(public.GVec)
@@ -55,29 +170,34 @@ func VerifyWNLA(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentP
 	G_ := vectorPointsAdd(vectorPointMulOnScalar(G0, public.Ro), vectorPointMulOnScalar(G1, y))
 	c_ := vectorAdd(c0, vectorMulOnScalar(c1, y))
 
-	Com_ := new(bn256.G1).Set(Com)
-	Com_.Add(Com_, new(bn256.G1).ScalarMult(proof.X[0], y))
-	Com_.Add(Com_, new(bn256.G1).ScalarMult(proof.R[0], sub(mul(y, y), bint(1))))
+	Com_ := wnlaFoldCommitment(state.Com, roundX, roundR, y)
 
-	// Recursive run
-	return VerifyWNLA(
-		&WeightNormLinearPublic{
-			G:    public.G,
-			GVec: G_,
-			HVec: H_,
-			C:    c_,
-			Ro:   public.Mu,
-			Mu:   mul(public.Mu, public.Mu),
-		},
-		&WeightNormLinearArgumentProof{
-			R: proof.R[1:],
-			X: proof.X[1:],
-			L: proof.L,
-			N: proof.N,
-		},
-		Com_,
-		fs,
-	)
+	public_ := &WeightNormLinearPublic{
+		G:    public.G,
+		GVec: G_,
+		HVec: H_,
+		C:    c_,
+		Ro:   public.Mu,
+		Mu:   mul(public.Mu, public.Mu),
+	}
+
+	public_.gTable.inherit(&public.gTable)
+
+	return &WNLAVerifyState{
+		Public: public_,
+		Com:    Com_,
+		FS:     fs,
+	}, nil
+}
+
+// WNLAFinalize verifies the final WNLA relation for the prover's l, n vectors after all rounds have been processed.
+// If err is nil then proof is valid.
+func WNLAFinalize(state *WNLAVerifyState, l, n []*big.Int) error {
+	if !pointsEqual(state.Public.CommitWNLA(l, n), state.Com) {
+		return errors.New("failed to verify proof")
+	}
+
+	return nil
 }
 
 // ProveWNLA generates zero knowledge proof of knowledge of two vectors l and n that
@@ -122,14 +242,7 @@ func ProveWNLA(public *WeightNormLinearPublic, Com *bn256.G1, fs FiatShamirEngin
 	R.Add(R, vectorPointScalarMul(H1, l1))
 	R.Add(R, vectorPointScalarMul(G1, n1))
 
-	fs.AddPoint(Com)
-	fs.AddPoint(X)
-	fs.AddPoint(R)
-	fs.AddNumber(bint(len(public.HVec)))
-	fs.AddNumber(bint(len(public.GVec)))
-
-	// Challenge using Fiat-Shamir heuristic
-	y := fs.GetChallenge()
+	y := wnlaRoundChallenge(fs, Com, X, R, len(public.HVec), len(public.GVec))
 
 	// Both calculates new vector points and new commitment
 	H_ := vectorPointsAdd(H0, vectorPointMulOnScalar(H1, y))
@@ -141,29 +254,247 @@ func ProveWNLA(public *WeightNormLinearPublic, Com *bn256.G1, fs FiatShamirEngin
 	n_ := vectorAdd(vectorMulOnScalar(n0, roinv), vectorMulOnScalar(n1, y))
 
 	public_ := &WeightNormLinearPublic{
-		G:    public.G,
-		GVec: G_,
-		HVec: H_,
-		C:    c_,
-		Ro:   public.Mu,
-		Mu:   mu2,
+		G:      public.G,
+		GVec:   G_,
+		HVec:   H_,
+		C:      c_,
+		Ro:     public.Mu,
+		Mu:     mu2,
+		Layout: public.Layout,
 	}
 
+	public_.gTable.inherit(&public.gTable)
+	Com_ := public_.CommitWNLA(l_, n_)
+
 	// Recursive run
 	res := ProveWNLA(
 		public_,
-		public_.CommitWNLA(l_, n_),
+		Com_,
 		fs,
 		l_,
 		n_,
 	)
 
-	return &WeightNormLinearArgumentProof{
+	proof := &WeightNormLinearArgumentProof{
 		R: append([]*bn256.G1{R}, res.R...),
 		X: append([]*bn256.G1{X}, res.X...),
 		L: res.L,
 		N: res.N,
 	}
+
+	if public.Layout == Flattened {
+		proof.Y = append([]*big.Int{y}, res.Y...)
+		proof.Folded = append([]*bn256.G1{Com_}, res.Folded...)
+	}
+
+	return proof
+}
+
+// DeriveWNLAChallenges returns the round challenges y of the WNLA proof in the order of rounds. Only the generators
+// vectors lengths are used from public. Use empty FiatShamirEngine for call. Also, use the same commitment that has
+// been used during proving.
+func DeriveWNLAChallenges(public *WeightNormLinearPublic, Com *bn256.G1, fs FiatShamirEngine, proof *WeightNormLinearArgumentProof) ([]*big.Int, error) {
+	res, _, err := deriveWNLAChallenges(public, Com, fs, proof)
+	return res, err
+}
+
+// VerifyWNLAFast verifies the weight norm linear argument proof with the same result as VerifyWNLA, but without
+// folding the generators in every round. The final generators are linear combinations of the initial ones, so the
+// verifier computes their coefficients from the round challenges and checks the single equation:
+// Com + sum(y_i*X_i + (y_i^2-1)*R_i) = v*G + <l*hCoef, HVec> + <n*gCoef, GVec>
+// where v = <c', l> + |n^2|_mu' for the folded c' and mu'. Use empty FiatShamirEngine for call. Also, use the same
+// commitment that has been used during proving.
+func VerifyWNLAFast(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentProof, Com *bn256.G1, fs FiatShamirEngine) error {
+	points, scalars, ComFinal, err := wnlaFinalEquation(public, proof, Com, fs)
+	if err != nil {
+		return err
+	}
+
+	if !pointsEqual(multiScalarMul(points, scalars), ComFinal) {
+		return errors.New("failed to verify proof")
+	}
+
+	return nil
+}
+
+// wnlaFinalEquation derives the round challenges and returns the final equation of VerifyWNLAFast:
+// sum(scalars[i]*points[i]) = ComFinal, where points are G||HVec||GVec.
+func wnlaFinalEquation(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentProof, Com *bn256.G1, fs FiatShamirEngine) ([]*bn256.G1, []*big.Int, *bn256.G1, error) {
+	y, ComFinal, err := deriveWNLAChallenges(public, Com, fs, proof)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	gScalar, hScalars, gScalars := wnlaFinalScalars(public, proof, y)
+
+	points := make([]*bn256.G1, 0, 1+len(hScalars)+len(gScalars))
+	points = append(points, public.G)
+	points = append(points, public.HVec...)
+	points = append(points, public.GVec...)
+
+	scalars := make([]*big.Int, 0, cap(points))
+	scalars = append(scalars, gScalar)
+	scalars = append(scalars, hScalars...)
+	scalars = append(scalars, gScalars...)
+
+	return points, scalars, ComFinal, nil
+}
+
+// wnlaFinalScalars returns the scalars of G, HVec and GVec in the final equation of VerifyWNLAFast
+// for the round challenges y.
+func wnlaFinalScalars(public *WeightNormLinearPublic, proof *WeightNormLinearArgumentProof, y []*big.Int) (*big.Int, []*big.Int, []*big.Int) {
+	// Original generator i is placed at the position i >> j after j rounds,
+	// so its j-th bit defines whether it was in the odd or even half during the j-th round.
+	hCoef := oneVector(len(public.HVec))
+	gCoef := oneVector(len(public.GVec))
+
+	c, ro, mu := public.C, public.Ro, public.Mu
+
+	for j := range y {
+		for i := range hCoef {
+			if (i>>j)&1 == 1 {
+				hCoef[i] = mul(hCoef[i], y[j])
+			}
+		}
+
+		for i := range gCoef {
+			if (i>>j)&1 == 1 {
+				gCoef[i] = mul(gCoef[i], y[j])
+			} else {
+				gCoef[i] = mul(gCoef[i], ro)
+			}
+		}
+
+		c0, c1 := reduceVector(c)
+		c = vectorAdd(c0, vectorMulOnScalar(c1, y[j]))
+		ro, mu = mu, mul(mu, mu)
+	}
+
+	for i := range hCoef {
+		hCoef[i] = mul(hCoef[i], scalarAt(proof.L, i>>len(y)))
+	}
+
+	for i := range gCoef {
+		gCoef[i] = mul(gCoef[i], scalarAt(proof.N, i>>len(y)))
+	}
+
+	return add(vectorMul(c, proof.L), weightVectorMul(proof.N, proof.N, mu)), hCoef, gCoef
+}
+
+// wnlaKeyWindow is the FixedBaseMul window of the WNLAVerifyKey tables.
+const wnlaKeyWindow = 6
+
+// WNLAVerifyKey caches the fixed-base multiplication tables of the WNLA generators to verify many proofs under the
+// same public parameters. The round challenges differ between the proofs, so the folded generators can not be reused,
+// but the final equation of VerifyWNLAFast is a linear combination of the initial generators, that is computed with
+// the tables without doublings. Tables take 43*64 = 2752 points (~400KB) per generator. Create it with NewWNLAVerifyKey.
+type WNLAVerifyKey struct {
+	public     *WeightNormLinearPublic
+	g          *FixedBaseMul
+	gVec, hVec []*FixedBaseMul
+}
+
+// NewWNLAVerifyKey precomputes the multiplication tables for G, GVec and HVec of public. Public should not be modified
+// after the key creation. Key is not modified during verification, so it can be shared between goroutines.
+func NewWNLAVerifyKey(public *WeightNormLinearPublic) *WNLAVerifyKey {
+	table := func(points []*bn256.G1) []*FixedBaseMul {
+		res := make([]*FixedBaseMul, len(points))
+		for i := range points {
+			res[i] = NewFixedBaseMul(points[i], wnlaKeyWindow)
+		}
+		return res
+	}
+
+	return &WNLAVerifyKey{
+		public: public,
+		g:      NewFixedBaseMul(public.G, wnlaKeyWindow),
+		gVec:   table(public.GVec),
+		hVec:   table(public.HVec),
+	}
+}
+
+// Verify verifies the weight norm linear argument proof with the same result as VerifyWNLA. If err is nil then proof
+// is valid. Use empty FiatShamirEngine for call. Also, use the same commitment that has been used during proving.
+func (k *WNLAVerifyKey) Verify(proof *WeightNormLinearArgumentProof, Com *bn256.G1, fs FiatShamirEngine) error {
+	y, ComFinal, err := deriveWNLAChallenges(k.public, Com, fs, proof)
+	if err != nil {
+		return err
+	}
+
+	gScalar, hScalars, gScalars := wnlaFinalScalars(k.public, proof, y)
+
+	res := k.g.Mul(gScalar)
+	for i := range k.hVec {
+		res.Add(res, k.hVec[i].Mul(hScalars[i]))
+	}
+
+	for i := range k.gVec {
+		res.Add(res, k.gVec[i].Mul(gScalars[i]))
+	}
+
+	if !pointsEqual(res, ComFinal) {
+		return errors.New("failed to verify proof")
+	}
+
+	return nil
+}
+
+// deriveWNLAChallenges returns the round challenges and the commitment folded by all rounds.
+func deriveWNLAChallenges(public *WeightNormLinearPublic, Com *bn256.G1, fs FiatShamirEngine, proof *WeightNormLinearArgumentProof) ([]*big.Int, *bn256.G1, error) {
+	if len(proof.X) != len(proof.R) {
+		return nil, nil, errors.New("invalid length for R and X vectors: should be equal")
+	}
+
+	if proof.IsFlattened() {
+		return nil, nil, errFlattenedProof
+	}
+
+	lLen, nLen := len(public.HVec), len(public.GVec)
+	res := make([]*big.Int, len(proof.X))
+
+	for i := range proof.X {
+		if proof.X[i] == nil || proof.R[i] == nil {
+			return nil, nil, errors.New("invalid round: X and R should be defined")
+		}
+
+		res[i] = wnlaRoundChallenge(fs, Com, proof.X[i], proof.R[i], lLen, nLen)
+		Com = wnlaFoldCommitment(Com, proof.X[i], proof.R[i], res[i])
+		lLen, nLen = (lLen+1)/2, (nLen+1)/2
+	}
+
+	return res, Com, nil
+}
+
+// wnlaRoundChallenge absorbs the round values and returns the round challenge.
+func wnlaRoundChallenge(fs FiatShamirEngine, Com, X, R *bn256.G1, lLen, nLen int) *big.Int {
+	fs.AddPoint(Com)
+	fs.AddPoint(X)
+	fs.AddPoint(R)
+	fs.AddNumber(bint(lLen))
+	fs.AddNumber(bint(nLen))
+
+	// Challenge using Fiat-Shamir heuristic
+	return fs.GetChallenge()
+}
+
+// wnlaFoldCommitment returns the commitment for the next round: Com + y*X + (y^2-1)*R.
+func wnlaFoldCommitment(Com, X, R *bn256.G1, y *big.Int) *bn256.G1 {
+	res := new(bn256.G1).Set(Com)
+	res.Add(res, new(bn256.G1).ScalarMult(X, y))
+	res.Add(res, new(bn256.G1).ScalarMult(R, sub(mul(y, y), bint(1))))
+	return res
+}
+
+// wnlaShape returns the rounds count and the final l and n vectors lengths of the WNLA proof
+// for the initial l and n vectors lengths.
+func wnlaShape(lLen, nLen int) (rounds, finalL, finalN int) {
+	for lLen+nLen >= 6 {
+		lLen = (lLen + 1) / 2
+		nLen = (nLen + 1) / 2
+		rounds++
+	}
+
+	return rounds, lLen, nLen
 }
 
 func reduceVector(v []*big.Int) ([]*big.Int, []*big.Int) {
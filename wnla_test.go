@@ -5,6 +5,9 @@
 package bulletproofs
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/cloudflare/bn256"
 	"github.com/davecgh/go-spew/spew"
 	"math/big"
 	"testing"
@@ -25,3 +28,320 @@ func TestWNLA(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestWNLAStep(t *testing.T) {
+	public := NewWeightNormLinearPublic(8, 4)
+
+	l := []*big.Int{bint(4), bint(5), bint(10), bint(1), bint(99), bint(35), bint(1), bint(15)}
+	n := []*big.Int{bint(1), bint(3), bint(42), bint(14)}
+
+	proof := ProveWNLA(public, public.CommitWNLA(l, n), NewKeccakFS(), l, n)
+
+	verifyByStep := func(proof *WeightNormLinearArgumentProof) error {
+		state := NewWNLAVerifyState(public, public.CommitWNLA(l, n), NewKeccakFS())
+
+		for i := range proof.X {
+			var err error
+			if state, err = WNLAStep(state, proof.X[i], proof.R[i]); err != nil {
+				return err
+			}
+		}
+
+		return WNLAFinalize(state, proof.L, proof.N)
+	}
+
+	if err := verifyByStep(proof); err != nil {
+		panic(err)
+	}
+
+	if err := VerifyWNLA(public, proof, public.CommitWNLA(l, n), NewKeccakFS()); err != nil {
+		panic(err)
+	}
+
+	proof.L[0] = add(proof.L[0], bint(1))
+
+	if verifyByStep(proof) == nil {
+		panic("step verification should fail")
+	}
+
+	if VerifyWNLA(public, proof, public.CommitWNLA(l, n), NewKeccakFS()) == nil {
+		panic("verification should fail")
+	}
+}
+
+func TestCheckWNLARelation(t *testing.T) {
+	public := NewWeightNormLinearPublic(4, 2)
+
+	l := []*big.Int{bint(4), bint(5), bint(10), bint(1)}
+	n := []*big.Int{bint(2), bint(1)}
+
+	// v = <c, l> + n[0]^2*mu + n[1]^2*mu^2
+	v := add(vectorMul(public.C, l), add(mul(bint(4), public.Mu), mul(bint(1), mul(public.Mu, public.Mu))))
+
+	if err := CheckWNLARelation(public, l, n, v); err != nil {
+		panic(err)
+	}
+
+	// Corrupted residual
+	if err := CheckWNLARelation(public, l, []*big.Int{bint(2), bint(2)}, v); err == nil {
+		panic("relation check should fail for corrupted n")
+	}
+}
+
+func TestDeriveWNLAChallenges(t *testing.T) {
+	seed := []byte("bulletproofs challenges test")
+	points := DeterministicPoints(seed, "wnla", 5)
+
+	public := &WeightNormLinearPublic{GVec: make([]*bn256.G1, 4), HVec: make([]*bn256.G1, 8)}
+	proof := &WeightNormLinearArgumentProof{X: points[:2], R: points[2:4]}
+
+	expected := []string{
+		"50479d028dd8106808b83228dda5c54b80c4fc7484b89c21abc65ecb3c4182f4",
+		"69175140c01c1e5884e37e79e118573ae45a0eff59eb4d903092e77681326b39",
+	}
+
+	y, err := DeriveWNLAChallenges(public, points[4], NewKeccakFS(), proof)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(y) != len(expected) {
+		panic("test failed")
+	}
+
+	for i := range y {
+		if y[i].Text(16) != expected[i] {
+			panic(fmt.Sprintf("challenge %d: got %x, expected %s", i, y[i], expected[i]))
+		}
+	}
+}
+
+func TestWNLAIsBaseCase(t *testing.T) {
+	// len(l)+len(n) < 6: vectors are sent without reduction
+	public := NewWeightNormLinearPublic(2, 2)
+	l := []*big.Int{bint(4), bint(5)}
+	n := []*big.Int{bint(1), bint(3)}
+
+	proof := ProveWNLA(public, public.CommitWNLA(l, n), NewKeccakFS(), l, n)
+	if !proof.IsBaseCase() {
+		panic("test failed")
+	}
+
+	if err := VerifyWNLA(public, proof, public.CommitWNLA(l, n), NewKeccakFS()); err != nil {
+		panic(err)
+	}
+
+	public = NewWeightNormLinearPublic(8, 4)
+	l = []*big.Int{bint(4), bint(5), bint(10), bint(1), bint(99), bint(35), bint(1), bint(15)}
+	n = []*big.Int{bint(1), bint(3), bint(42), bint(14)}
+
+	proof = ProveWNLA(public, public.CommitWNLA(l, n), NewKeccakFS(), l, n)
+	if proof.IsBaseCase() {
+		panic("test failed")
+	}
+
+	if err := VerifyWNLA(public, proof, public.CommitWNLA(l, n), NewKeccakFS()); err != nil {
+		panic(err)
+	}
+}
+
+func TestPointsEqual(t *testing.T) {
+	a := MustRandPoint()
+	b := MustRandPoint()
+	identity := new(bn256.G1).ScalarBaseMult(bint(0))
+
+	// The same point in the different projective representations
+	a2 := new(bn256.G1).Add(a, b)
+	a2.Add(a2, new(bn256.G1).Neg(b))
+
+	cases := [][2]*bn256.G1{{a, a}, {a, a2}, {a, b}, {a, identity}, {identity, a}, {identity, identity}}
+
+	for _, c := range cases {
+		if pointsEqual(c[0], c[1]) != bytes.Equal(c[0].Marshal(), c[1].Marshal()) {
+			panic("test failed")
+		}
+	}
+
+	if !pointsEqual(a, a2) || pointsEqual(a, b) || !pointsEqual(identity, identity) {
+		panic("test failed")
+	}
+}
+
+func BenchmarkPointsEqual(b *testing.B) {
+	p := MustRandPoint()
+	q := MustRandPoint()
+
+	// Points are in the projective representation, as after the commitment computations
+	p1 := new(bn256.G1).Add(p, q)
+	p2 := new(bn256.G1).Add(q, p)
+
+	b.Run("marshal", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			x, y := new(bn256.G1).Set(p1), new(bn256.G1).Set(p2)
+			bytes.Equal(x.Marshal(), y.Marshal())
+		}
+	})
+
+	b.Run("pointsEqual", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			x, y := new(bn256.G1).Set(p1), new(bn256.G1).Set(p2)
+			pointsEqual(x, y)
+		}
+	})
+}
+
+func TestVerifyWNLAFast(t *testing.T) {
+	for _, size := range [][2]int{{2, 2}, {8, 4}, {7, 5}, {64, 32}} {
+		public := NewWeightNormLinearPublic(size[0], size[1])
+
+		l := make([]*big.Int, size[0])
+		for i := range l {
+			l[i] = MustRandScalar()
+		}
+
+		n := make([]*big.Int, size[1])
+		for i := range n {
+			n[i] = MustRandScalar()
+		}
+
+		Com := public.CommitWNLA(l, n)
+		proof := ProveWNLA(public, Com, NewKeccakFS(), l, n)
+		key := NewWNLAVerifyKey(public)
+
+		check := func(proof *WeightNormLinearArgumentProof, Com *bn256.G1, valid bool) {
+			errSlow := VerifyWNLA(public, proof, Com, NewKeccakFS())
+			errFast := VerifyWNLAFast(public, proof, Com, NewKeccakFS())
+			errKey := key.Verify(proof, Com, NewKeccakFS())
+
+			if (errSlow == nil) != valid || (errFast == nil) != valid || (errKey == nil) != valid {
+				panic(fmt.Sprintf("size %v: slow error %v, fast error %v, key error %v, expected valid %v", size, errSlow, errFast, errKey, valid))
+			}
+		}
+
+		check(proof, Com, true)
+		check(proof, new(bn256.G1).Add(Com, public.G), false)
+
+		corrupted := *proof
+		corrupted.L = append([]*big.Int{add(proof.L[0], bint(1))}, proof.L[1:]...)
+		check(&corrupted, Com, false)
+
+		corrupted = *proof
+		corrupted.N = append([]*big.Int{add(proof.N[0], bint(1))}, proof.N[1:]...)
+		check(&corrupted, Com, false)
+
+		if !proof.IsBaseCase() {
+			corrupted = *proof
+			corrupted.X = append([]*bn256.G1{new(bn256.G1).Add(proof.X[0], public.G)}, proof.X[1:]...)
+			check(&corrupted, Com, false)
+
+			corrupted = *proof
+			corrupted.R = append([]*bn256.G1{new(bn256.G1).Add(proof.R[0], public.G)}, proof.R[1:]...)
+			check(&corrupted, Com, false)
+		}
+	}
+}
+
+func BenchmarkVerifyWNLA(b *testing.B) {
+	public := NewWeightNormLinearPublic(256, 128)
+
+	l := make([]*big.Int, 256)
+	for i := range l {
+		l[i] = MustRandScalar()
+	}
+
+	n := make([]*big.Int, 128)
+	for i := range n {
+		n[i] = MustRandScalar()
+	}
+
+	Com := public.CommitWNLA(l, n)
+	proof := ProveWNLA(public, Com, NewKeccakFS(), l, n)
+
+	b.Run("recursive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := VerifyWNLA(public, proof, Com, NewKeccakFS()); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	b.Run("fast", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := VerifyWNLAFast(public, proof, Com, NewKeccakFS()); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+func BenchmarkWNLAVerifyKey(b *testing.B) {
+	public := NewWeightNormLinearPublic(32, 16)
+
+	proofs := make([]*WeightNormLinearArgumentProof, 1000)
+	coms := make([]*bn256.G1, len(proofs))
+
+	for j := range proofs {
+		l := make([]*big.Int, 32)
+		for i := range l {
+			l[i] = MustRandScalar()
+		}
+
+		n := make([]*big.Int, 16)
+		for i := range n {
+			n[i] = MustRandScalar()
+		}
+
+		coms[j] = public.CommitWNLA(l, n)
+		proofs[j] = ProveWNLA(public, coms[j], NewKeccakFS(), l, n)
+	}
+
+	key := NewWNLAVerifyKey(public)
+
+	for _, bench := range []struct {
+		name   string
+		verify func(proof *WeightNormLinearArgumentProof, Com *bn256.G1) error
+	}{
+		{"recursive", func(proof *WeightNormLinearArgumentProof, Com *bn256.G1) error {
+			return VerifyWNLA(public, proof, Com, NewKeccakFS())
+		}},
+		{"fast", func(proof *WeightNormLinearArgumentProof, Com *bn256.G1) error {
+			return VerifyWNLAFast(public, proof, Com, NewKeccakFS())
+		}},
+		{"key", func(proof *WeightNormLinearArgumentProof, Com *bn256.G1) error {
+			return key.Verify(proof, Com, NewKeccakFS())
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := range proofs {
+					if err := bench.verify(proofs[j], coms[j]); err != nil {
+						panic(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMultiScalarMul(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 5, 17, 100} {
+		points := make([]*bn256.G1, size)
+		scalars := make([]*big.Int, size)
+
+		for i := range points {
+			points[i] = MustRandPoint()
+			scalars[i] = MustRandScalar()
+		}
+
+		if size > 2 {
+			// Zero scalar, repeated point and scalar larger than the group order
+			scalars[0] = bint(0)
+			points[1] = points[2]
+			scalars[2] = new(big.Int).Add(scalars[2], bn256.Order)
+		}
+
+		if !pointsEqual(multiScalarMul(points, scalars), vectorPointScalarMul(points, scalars)) {
+			panic(fmt.Sprintf("test failed for size %d", size))
+		}
+	}
+}